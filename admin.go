@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"html"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
@@ -31,12 +33,19 @@ func adminRoutes() *http.ServeMux {
 	mux.HandleFunc("/admin/potential", handlePotentialFileIndex)
 	mux.HandleFunc("/admin/needfix", handleNeedFixFileIndex)
 	mux.HandleFunc("/admin/file/", handleFile)
+	mux.HandleFunc("/admin/courses", handleCourseIndex)
+	mux.HandleFunc("/admin/course/", handleCourse)
+	mux.HandleFunc("/admin/coverage", handleCoverage)
+	mux.HandleFunc("/admin/coverage.csv", handleCoverageCSV)
+	mux.HandleFunc("/admin/coverage.json", handleCoverageJSON)
 
 	mux.HandleFunc("/admin/generate", generators.PrettyJob(handleGenerate))
 	mux.HandleFunc("/admin/remove404", generators.PrettyJob(handleAdminRemove404))
 	mux.HandleFunc("/admin/duplicates", generators.PrettyJob(handleListDuplicates))
 	mux.HandleFunc("/admin/removeDuplicates", generators.PrettyJob(handleRemoveDuplicates))
 	mux.HandleFunc("/admin/incorrectlocations", generators.PrettyJob(handleListIncorrectLocations))
+	mux.HandleFunc("/admin/fsck", generators.PrettyJob(handleFsck))
+	mux.HandleFunc("/admin/fsck.json", handleFsckJSON)
 
 	// Machine Learning Endpoints
 	mux.HandleFunc("/admin/ml/bayesian/train", generators.PrettyJob(handleMLRetrain))
@@ -71,16 +80,30 @@ func handlePotentialFileIndex(w http.ResponseWriter, r *http.Request) {
 		<a href="/admin/potential?invalid">Not Exams/Invalid</a>`))
 
 	_, showInvalid := r.URL.Query()["invalid"]
+	dept := r.URL.Query().Get("dept")
+	base := "/admin/potential"
+	if showInvalid {
+		base += "?invalid&"
+	} else {
+		base += "?"
+	}
+	renderDepartmentFilter(w, base, dept)
 
 	if !showInvalid {
 		fmt.Fprint(w, "<h1>Unprocessed</h1><ul>")
 		for _, file := range db.UnprocessedFiles() {
+			if !(examdb.Course{Code: generator.FileCourse(file)}).InDepartment(cfg, dept) {
+				continue
+			}
 			fmt.Fprintf(w, `<li><a href="/admin/file/%s">%s %s</a> %.0f</li>`, file.Hash, file.Source, file.Path, file.Score)
 		}
 		fmt.Fprint(w, "</ul>")
 	} else {
 		fmt.Fprint(w, "<h1>Not Exams/Invalid</h1><ul>")
 		for _, file := range db.NotAnExamFiles() {
+			if !(examdb.Course{Code: generator.FileCourse(file)}).InDepartment(cfg, dept) {
+				continue
+			}
 			fmt.Fprintf(w, `<li><a href="/admin/file/%s">%s %s</a></li>`, file.Hash, file.Source, file.Path)
 		}
 		fmt.Fprint(w, "</ul>")
@@ -92,17 +115,18 @@ func handleFilePost(w http.ResponseWriter, r *http.Request, file *examdb.File) {
 		http.Error(w, err.Error(), 400)
 		return
 	}
+	prevCourse := generator.FileCourse(file)
 	if len(r.FormValue("invalid")) > 0 {
 		file.NotAnExam = true
-		file.HandClassified = true
+		file.MarkClassified()
 		http.Redirect(w, r, "/admin/potential", 302)
-		if err := saveDatabase(); err != nil {
+		if err := saveAndGenerateCourses(prevCourse); err != nil {
 			handleErr(w, err)
 			return
 		}
 		return
 	}
-	course := r.FormValue("course")
+	course, _ := db.ResolveCourse(r.FormValue("course"))
 	if len(course) == 0 {
 		http.Error(w, "must specify course", 400)
 		return
@@ -118,17 +142,36 @@ func handleFilePost(w http.ResponseWriter, r *http.Request, file *examdb.File) {
 		http.Error(w, "must specify name", 400)
 		return
 	}
+	label, err := examdb.ParseLabel(name)
+	if err == nil {
+		err = label.Validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
 	term := r.FormValue("term")
+	if len(term) > 0 {
+		var ok bool
+		if term, ok = examdb.NormalizeTerm(term); !ok {
+			http.Error(w, "invalid term", 400)
+			return
+		}
+	}
 	year, err := strconv.Atoi(r.FormValue("year"))
 	if err != nil {
 		http.Error(w, err.Error(), 400)
 		return
 	}
+	// Files can be classified under courses that aren't in the calendar yet.
+	if _, ok := db.ResolveCourse(course); !ok {
+		db.AddCourse(ioutil.Discard, course, "")
+	}
 	file.Year = year
 	file.Course = course
 	file.Term = term
-	file.Name = name
-	file.HandClassified = true
+	file.SetLabel(label)
+	file.MarkClassified()
 	if err := db.RemoveFile(file); err != nil {
 		http.Error(w, err.Error(), 500)
 		return
@@ -143,7 +186,7 @@ func handleFilePost(w http.ResponseWriter, r *http.Request, file *examdb.File) {
 		http.Redirect(w, r, "/admin/potential", 302)
 	}
 
-	if err := saveDatabase(); err != nil {
+	if err := saveAndGenerateCourses(prevCourse, file.Course); err != nil {
 		handleErr(w, err)
 		return
 	}
@@ -203,7 +246,7 @@ func handleFile(w http.ResponseWriter, r *http.Request) {
 		meta.Course = ml.ExtractCourse(&db, file)
 	}
 
-	year, _ := ml.ExtractYear(file)
+	year, _ := ml.ExtractYear(cfg, file)
 	meta.Year = strconv.Itoa(year)
 
 	if ml.DefaultGoogleClassifier != nil {
@@ -220,7 +263,7 @@ func handleFile(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := templates.ExecuteTemplate(w, "file.html", meta); err != nil {
+	if err := generators.Templates.ExecuteTemplate(w, "file.html", meta); err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
@@ -251,14 +294,14 @@ func handleAdminRemove404(w http.ResponseWriter, r *http.Request) {
 				if len(f.Path) > 0 {
 					continue
 				}
-				isExamsCGI := strings.HasPrefix(f.Source, "https://www.ugrad.cs.ubc.ca/~q7w9a/exams.cgi/exams.cgi")
+				isExamsCGI := strings.HasPrefix(f.Source, cfg.Ugrad.ExamsCGIURL+"exams.cgi")
 				if f.LastResponseCode == 403 && isExamsCGI {
 					// Remove 403ed exams.cgi endpoints.
 				} else if !(f.LastResponseCode == 404 || f.LastResponseCode == 0) {
 					continue
 				}
 
-				reader, err := f.Reader()
+				reader, err := f.Reader(cfg)
 				if err != nil {
 					is404 := strings.Contains(err.Error(), "got 404")
 					is403 := strings.Contains(err.Error(), "got 403")
@@ -296,6 +339,7 @@ func labelsToName(typ, samp, sol string) string {
 
 func handleGenerate(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
+	generator.Invalidate()
 	if err := saveAndGenerate(); err != nil {
 		handleErr(w, err)
 		return
@@ -306,7 +350,12 @@ func handleGenerate(w http.ResponseWriter, r *http.Request) {
 func handleAdminIndex(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
 	renderAdminHeader(w)
-	if err := generators.ExecuteTemplate(w, "admin.md", nil); err != nil {
+	data := struct {
+		Departments []config.Department
+	}{
+		Departments: cfg.Departments,
+	}
+	if err := generators.ExecuteTemplate(w, "admin.md", data); err != nil {
 		handleErr(w, err)
 		return
 	}
@@ -316,7 +365,19 @@ func handleNeedFixFileIndex(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
 	renderAdminHeader(w)
 
-	reasons := db.NeedFix()
+	dept := r.URL.Query().Get("dept")
+	renderDepartmentFilter(w, "/admin/needfix?", dept)
+
+	var reasons []examdb.NeedFixReasons
+	for _, reason := range db.NeedFix() {
+		course := reason.File.Course
+		if len(course) == 0 && reason.File.Inferred != nil {
+			course = reason.File.Inferred.Course
+		}
+		if (examdb.Course{Code: course}).InDepartment(cfg, dept) {
+			reasons = append(reasons, reason)
+		}
+	}
 	fmt.Fprintf(w, `<h1>Files that Potentially Need to be Fixed (%d)</h1>
 	<table>
 	<thead>
@@ -334,18 +395,38 @@ func handleNeedFixFileIndex(w http.ResponseWriter, r *http.Request) {
 		if file.NotAnExam {
 			continue
 		}
+		name := file.Name
+		if len(name) == 0 {
+			name = path.Base(file.Source)
+		}
 		fmt.Fprintf(w, `<tr>
 		<td><a href="/admin/file/%s?redirect=/admin/needfix">%s</a></td>
 		<td>%s</td>
 		<td>%s</td>
 		<td>%s</td>
-		</tr>`, file.Hash, file.Name, strings.Join(reason.Reasons, ", "), file.Path, file.Source)
+		</tr>`, file.Hash, name, strings.Join(reason.Reasons, ", "), file.Path, file.Source)
 	}
 	fmt.Fprint(w, `</tbody></table>`)
 }
 
+// renderDepartmentFilter renders links that filter the page at base by
+// department.
+func renderDepartmentFilter(w http.ResponseWriter, base, selected string) {
+	fmt.Fprint(w, "<p>Department: ")
+	links := []string{fmt.Sprintf(`<a href="%s">All</a>`, strings.TrimRight(base, "?&"))}
+	for _, d := range cfg.Departments {
+		link := fmt.Sprintf(`<a href="%sdept=%s">%s</a>`, base, url.QueryEscape(d.Code), html.EscapeString(d.Code))
+		if strings.EqualFold(d.Code, selected) {
+			link = "<b>" + link + "</b>"
+		}
+		links = append(links, link)
+	}
+	fmt.Fprint(w, strings.Join(links, " | "))
+	fmt.Fprint(w, "</p>")
+}
+
 func handleMLRetrain(w http.ResponseWriter, r *http.Request) {
-	if err := ml.RetrainClassifier(&db, config.ClassifierDir); err != nil {
+	if err := ml.RetrainClassifier(&db, cfg); err != nil {
 		handleErr(w, err)
 		return
 	}
@@ -405,15 +486,23 @@ func handleMLGoogleInferPotential(w http.ResponseWriter, r *http.Request) {
 					continue
 				}
 
-				year, _ := ml.ExtractYear(f)
+				year, _ := ml.ExtractYear(cfg, f)
+				name := labelsToName(classes["type"], classes["sample"], classes["solution"])
 				inferred := &examdb.File{
-					Name:      labelsToName(classes["type"], classes["sample"], classes["solution"]),
+					Name:      name,
 					Term:      classes["term"],
 					NotAnExam: classes["isexam"] == ml.IsNotExam,
 					Year:      year,
 					Course:    ml.ExtractCourse(&db, f),
 					Updated:   time.Now(),
 				}
+				if label, err := examdb.ParseLabel(name); err == nil {
+					inferred.SetLabel(label)
+				}
+				if err := ml.CheckCourseYear(&db, inferred.Course, inferred.Year); err != nil {
+					fmt.Fprintf(w, "%s: needs review: %s\n", f, err)
+					inferred.Review = err.Error()
+				}
 
 				fmt.Fprintf(w, "%d. inferred %#v\n", i, inferred)
 
@@ -435,7 +524,7 @@ func handleMLGoogleInferPotential(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleMLRetrainGoogle(w http.ResponseWriter, r *http.Request) {
-	model, err := ml.MakeGoogleClassifier()
+	model, err := ml.MakeGoogleClassifier(cfg)
 	if err != nil {
 		handleErr(w, err)
 		return
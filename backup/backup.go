@@ -0,0 +1,357 @@
+// Package backup creates verifiable, optionally incremental tar archives of the
+// database, classifiers and exam files and restores them.
+package backup
+
+import (
+	"archive/tar"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// manifestName is the name of the manifest inside of each archive.
+	manifestName = "manifest.json"
+	// timeFormat is used for the names of backups so they sort by time.
+	timeFormat = "20060102T150405.000Z"
+)
+
+// Source is a file on disk to back up.
+type Source struct {
+	// Path is the path of the file inside of the backup.
+	Path string
+	// Disk is the path of the file on disk.
+	Disk string
+}
+
+// Entry is a single file in a backup.
+type Entry struct {
+	Path string
+	Hash string
+	Size int64
+	// Archive is the name of the backup that contains the contents of the file
+	// and Member is the path within it. For incremental backups this can be an
+	// earlier backup.
+	Archive string
+	Member  string
+}
+
+// Manifest describes the complete state captured by a backup.
+type Manifest struct {
+	Name        string
+	Created     time.Time
+	Incremental bool
+	Entries     []Entry
+}
+
+// Stored returns the number of entries whose contents are in this archive.
+func (m Manifest) Stored() int {
+	n := 0
+	for _, e := range m.Entries {
+		if e.Archive == m.Name {
+			n++
+		}
+	}
+	return n
+}
+
+// ArchivePath returns the path of the tar file for the named backup.
+func ArchivePath(dir, name string) string {
+	return path.Join(dir, name+".tar")
+}
+
+func manifestPath(dir, name string) string {
+	return path.Join(dir, name+".manifest.json")
+}
+
+// Create backs up the sources into a new timestamped archive in dir. If
+// incremental is set, only files whose hashes aren't in the latest backup are
+// added to the archive and the rest reference the earlier archives.
+func Create(dir string, sources []Source, incremental bool) (*Manifest, error) {
+	m := &Manifest{
+		Incremental: incremental,
+	}
+
+	previous := map[string]Entry{}
+	if incremental {
+		manifests, err := List(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(manifests) > 0 {
+			for _, e := range manifests[len(manifests)-1].Entries {
+				previous[e.Hash] = e
+			}
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// Names have millisecond precision so wait for a free one.
+	var archivePath string
+	for {
+		m.Created = time.Now()
+		m.Name = "backup-" + m.Created.UTC().Format(timeFormat)
+		archivePath = ArchivePath(dir, m.Name)
+		if _, err := os.Stat(manifestPath(dir, m.Name)); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	out, err := os.Create(archivePath + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archivePath + ".tmp")
+	defer out.Close()
+	tw := tar.NewWriter(out)
+
+	stored := map[string]Entry{}
+	for _, s := range sources {
+		hash, size, err := hashFile(s.Disk)
+		if err != nil {
+			return nil, errors.Wrapf(err, "hashing %q", s.Disk)
+		}
+		e := Entry{Path: s.Path, Hash: hash, Size: size}
+		if prev, ok := previous[hash]; ok {
+			e.Archive, e.Member = prev.Archive, prev.Member
+		} else if prev, ok := stored[hash]; ok {
+			e.Archive, e.Member = prev.Archive, prev.Member
+		} else {
+			e.Archive, e.Member = m.Name, s.Path
+			if err := addFile(tw, s.Disk, s.Path, size); err != nil {
+				return nil, errors.Wrapf(err, "archiving %q", s.Disk)
+			}
+			stored[hash] = e
+		}
+		m.Entries = append(m.Entries, e)
+	}
+
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    manifestName,
+		Mode:    0644,
+		Size:    int64(len(raw)),
+		ModTime: m.Created,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(manifestPath(dir, m.Name), raw, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(archivePath+".tmp", archivePath); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func addFile(tw *tar.Writer, disk, name string, size int64) error {
+	f, err := os.Open(disk)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, size)
+	return err
+}
+
+func hashFile(fp string) (string, int64, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	hasher := sha1.New()
+	n, err := io.Copy(hasher, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), n, nil
+}
+
+// List returns the manifests of all backups in dir ordered from oldest to
+// newest.
+func List(dir string) ([]*Manifest, error) {
+	paths, err := filepath.Glob(path.Join(dir, "backup-*.manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var manifests []*Manifest
+	for _, p := range paths {
+		m, err := readManifest(p)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+func readManifest(fp string) (*Manifest, error) {
+	raw, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, errors.Wrapf(err, "parsing %q", fp)
+	}
+	return &m, nil
+}
+
+// Load returns the manifest of the named backup.
+func Load(dir, name string) (*Manifest, error) {
+	return readManifest(manifestPath(dir, strings.TrimSuffix(name, ".tar")))
+}
+
+// Extract extracts every entry of the backup into staging and verifies that
+// the hashes match the manifest. Nothing outside of staging is modified.
+func Extract(dir string, m *Manifest, staging string) error {
+	byArchive := map[string][]Entry{}
+	for _, e := range m.Entries {
+		byArchive[e.Archive] = append(byArchive[e.Archive], e)
+	}
+
+	for archive, entries := range byArchive {
+		members := map[string][]Entry{}
+		for _, e := range entries {
+			members[e.Member] = append(members[e.Member], e)
+		}
+		if err := extractArchive(ArchivePath(dir, archive), members, staging); err != nil {
+			return errors.Wrapf(err, "backup %q", archive)
+		}
+		for member, missing := range members {
+			if len(missing) > 0 {
+				return errors.Errorf("backup %q is missing %q", archive, member)
+			}
+		}
+	}
+	return nil
+}
+
+// extractArchive writes the members of the archive to their entry paths in
+// staging. Members are removed from the map once extracted.
+func extractArchive(fp string, members map[string][]Entry, staging string) error {
+	f, err := os.Open(fp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		entries, ok := members[hdr.Name]
+		if !ok || len(entries) == 0 {
+			continue
+		}
+		raw, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		sum := sha1.Sum(raw)
+		if hash := hex.EncodeToString(sum[:]); hash != entries[0].Hash {
+			return errors.Errorf("%q: hash mismatch: expected %s; got %s", hdr.Name, entries[0].Hash, hash)
+		}
+		for _, e := range entries {
+			dst, err := stagingPath(staging, e.Path)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(dst, raw, 0644); err != nil {
+				return err
+			}
+		}
+		delete(members, hdr.Name)
+	}
+}
+
+// stagingPath returns where the entry is extracted to and makes sure it can't
+// escape the staging directory.
+func stagingPath(staging, p string) (string, error) {
+	clean := path.Clean("/" + p)
+	if clean == "/" || clean != "/"+p {
+		return "", errors.Errorf("invalid path in backup %q", p)
+	}
+	return path.Join(staging, clean), nil
+}
+
+// Rotate deletes all but the newest keep backups. Archives that are still
+// referenced by a kept incremental backup aren't deleted. It returns the names
+// of the deleted backups.
+func Rotate(dir string, keep int) ([]string, error) {
+	if keep < 1 {
+		return nil, errors.Errorf("must keep at least one backup; got %d", keep)
+	}
+	manifests, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) <= keep {
+		return nil, nil
+	}
+
+	referenced := map[string]bool{}
+	for _, m := range manifests[len(manifests)-keep:] {
+		for _, e := range m.Entries {
+			referenced[e.Archive] = true
+		}
+	}
+
+	var deleted []string
+	for _, m := range manifests[:len(manifests)-keep] {
+		if referenced[m.Name] {
+			continue
+		}
+		if err := os.Remove(ArchivePath(dir, m.Name)); err != nil && !os.IsNotExist(err) {
+			return deleted, err
+		}
+		if err := os.Remove(manifestPath(dir, m.Name)); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, m.Name)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, fp, content string) {
+	if err := os.MkdirAll(path.Dir(fp), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, fp string) string {
+	raw, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestCreateIncrementalExtract(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	src := path.Join(dir, "src")
+	backups := path.Join(dir, "backups")
+
+	writeFile(t, path.Join(src, "db.json"), "v1")
+	writeFile(t, path.Join(src, "a.pdf"), "a")
+	writeFile(t, path.Join(src, "b.pdf"), "b")
+	sources := []Source{
+		{Path: "exams.json", Disk: path.Join(src, "db.json")},
+		{Path: "exams/a.pdf", Disk: path.Join(src, "a.pdf")},
+		{Path: "exams/b.pdf", Disk: path.Join(src, "b.pdf")},
+	}
+
+	full, err := Create(backups, sources, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Stored() != 3 {
+		t.Errorf("full backup should store every file; stored %d", full.Stored())
+	}
+
+	writeFile(t, path.Join(src, "db.json"), "v2")
+	writeFile(t, path.Join(src, "c.pdf"), "c")
+	sources = append(sources, Source{Path: "exams/c.pdf", Disk: path.Join(src, "c.pdf")})
+
+	inc, err := Create(backups, sources, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inc.Name == full.Name {
+		t.Fatalf("backups should have unique names")
+	}
+	if inc.Stored() != 2 {
+		t.Errorf("incremental backup should only store new hashes; stored %d: %+v", inc.Stored(), inc.Entries)
+	}
+
+	manifests, err := List(backups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 2 || manifests[1].Name != inc.Name {
+		t.Fatalf("List() = %+v", manifests)
+	}
+
+	staging := path.Join(dir, "staging")
+	if err := Extract(backups, inc, staging); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"exams.json":  "v2",
+		"exams/a.pdf": "a",
+		"exams/b.pdf": "b",
+		"exams/c.pdf": "c",
+	}
+	for p, content := range want {
+		if got := readFile(t, path.Join(staging, p)); got != content {
+			t.Errorf("%s = %q; not %q", p, got, content)
+		}
+	}
+}
+
+func TestExtractVerifiesHashes(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	backups := path.Join(dir, "backups")
+
+	writeFile(t, path.Join(dir, "a.pdf"), "a")
+	m, err := Create(backups, []Source{{Path: "exams/a.pdf", Disk: path.Join(dir, "a.pdf")}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Entries[0].Hash = "0000000000000000000000000000000000000000"
+
+	staging := path.Join(dir, "staging")
+	err = Extract(backups, m, staging)
+	if err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Fatalf("expected hash mismatch error; got %v", err)
+	}
+}
+
+func TestExtractRejectsEscapingPaths(t *testing.T) {
+	if _, err := stagingPath("/tmp/staging", "../etc/passwd"); err == nil {
+		t.Errorf("expected paths escaping staging to be rejected")
+	}
+	if fp, err := stagingPath("/tmp/staging", "exams/a.pdf"); err != nil || fp != "/tmp/staging/exams/a.pdf" {
+		t.Errorf("stagingPath() = %q, %v", fp, err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	backups := path.Join(dir, "backups")
+
+	writeFile(t, path.Join(dir, "a.pdf"), "a")
+	sources := []Source{{Path: "exams/a.pdf", Disk: path.Join(dir, "a.pdf")}}
+
+	first, err := Create(backups, sources, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Create(backups, sources, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// third references the contents stored in second.
+	if _, err := Create(backups, sources, true); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := Rotate(backups, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != first.Name {
+		t.Errorf("Rotate() deleted %v; expected only %q", deleted, first.Name)
+	}
+	if _, err := os.Stat(ArchivePath(backups, second.Name)); err != nil {
+		t.Errorf("archive referenced by a kept backup was deleted: %v", err)
+	}
+
+	if _, err := Rotate(backups, 0); err == nil {
+		t.Errorf("expected error keeping no backups")
+	}
+}
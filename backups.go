@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/ubccsss/exams/backup"
+	"github.com/urfave/cli"
+)
+
+// Prefixes of the paths of files inside of backups.
+const (
+	backupDBPath         = "exams.json"
+	backupClassifiersDir = "classifiers"
+	backupExamsDir       = "exams"
+)
+
+func setupBackupCommands() []cli.Command {
+	dirFlag := cli.StringFlag{
+		Name:  "dir",
+		Usage: "Directory the backups are stored in. Defaults to backup_dir from the config.",
+	}
+	return []cli.Command{
+		{
+			Name:   "backup",
+			Usage:  "back up the database, classifiers and exam files",
+			Action: backupSite,
+			Flags: []cli.Flag{
+				dirFlag,
+				cli.BoolFlag{
+					Name:  "incremental, i",
+					Usage: "Only archive files that aren't in the latest backup.",
+				},
+				cli.IntFlag{
+					Name:  "keep",
+					Usage: "Number of backups to keep. Older backups are deleted unless a kept incremental backup needs them. Defaults to backup_keep from the config.",
+				},
+			},
+		},
+		{
+			Name:      "restore",
+			Usage:     "verify and restore a backup",
+			ArgsUsage: "[BACKUP]",
+			Action:    restoreSite,
+			Flags: []cli.Flag{
+				dirFlag,
+				cli.BoolFlag{
+					Name:  "list, l",
+					Usage: "List the available backups.",
+				},
+			},
+		},
+	}
+}
+
+// backupSources returns all of the files that are backed up.
+func backupSources() ([]backup.Source, error) {
+	sources := []backup.Source{{Path: backupDBPath, Disk: cfg.DBFile}}
+
+	err := filepath.Walk(cfg.ClassifierDir, func(fp string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(cfg.ClassifierDir, fp)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, backup.Source{
+			Path: path.Join(backupClassifiersDir, filepath.ToSlash(rel)),
+			Disk: fp,
+		})
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	db.Mu.RLock()
+	defer db.Mu.RUnlock()
+
+	for _, f := range db.Files {
+		if len(f.Path) == 0 {
+			continue
+		}
+		if _, err := os.Stat(f.PathOnDisk(cfg)); os.IsNotExist(err) {
+			log.Printf("Skipping missing file %s", f)
+			continue
+		}
+		sources = append(sources, backup.Source{
+			Path: path.Join(backupExamsDir, f.Path),
+			Disk: f.PathOnDisk(cfg),
+		})
+	}
+	return sources, nil
+}
+
+// backupDest returns where a file in a backup is restored to.
+func backupDest(p string) (string, error) {
+	switch {
+	case p == backupDBPath:
+		return cfg.DBFile, nil
+	case strings.HasPrefix(p, backupClassifiersDir+"/"):
+		return path.Join(cfg.ClassifierDir, strings.TrimPrefix(p, backupClassifiersDir+"/")), nil
+	case strings.HasPrefix(p, backupExamsDir+"/"):
+		return path.Join(cfg.ExamsDir, strings.TrimPrefix(p, backupExamsDir+"/")), nil
+	}
+	return "", errors.Errorf("unknown path in backup %q", p)
+}
+
+// backupFlags overrides the backup configuration with the command's flags.
+func backupFlags(c *cli.Context) {
+	if c.IsSet("dir") {
+		cfg.BackupDir = c.String("dir")
+	}
+	if c.IsSet("keep") {
+		cfg.BackupKeep = c.Int("keep")
+	}
+}
+
+func backupSite(c *cli.Context) error {
+	backupFlags(c)
+	sources, err := backupSources()
+	if err != nil {
+		return err
+	}
+	m, err := backup.Create(cfg.BackupDir, sources, c.Bool("incremental"))
+	if err != nil {
+		return err
+	}
+	log.Printf("Created backup %q: %d files, %d archived.", m.Name, len(m.Entries), m.Stored())
+
+	deleted, err := backup.Rotate(cfg.BackupDir, cfg.BackupKeep)
+	if err != nil {
+		return err
+	}
+	for _, name := range deleted {
+		log.Printf("Deleted old backup %q.", name)
+	}
+	return nil
+}
+
+func restoreSite(c *cli.Context) error {
+	backupFlags(c)
+	manifests, err := backup.List(cfg.BackupDir)
+	if err != nil {
+		return err
+	}
+	if c.Bool("list") {
+		for _, m := range manifests {
+			fmt.Printf("%s\t%s\t%d files\t%d archived\n", m.Name, m.Created.Format("2006-01-02 15:04:05"), len(m.Entries), m.Stored())
+		}
+		return nil
+	}
+
+	name := c.Args().First()
+	if len(name) == 0 {
+		return errors.New("must specify a backup to restore, see --list")
+	}
+	m, err := backup.Load(cfg.BackupDir, path.Base(name))
+	if err != nil {
+		return err
+	}
+
+	// Extract and verify everything before touching any of the live files.
+	staging, err := ioutil.TempDir(cfg.BackupDir, "restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	if err := backup.Extract(cfg.BackupDir, m, staging); err != nil {
+		return errors.Wrap(err, "verifying backup")
+	}
+	log.Printf("Verified %d files in backup %q.", len(m.Entries), m.Name)
+
+	if raw, err := ioutil.ReadFile(cfg.DBFile); err == nil {
+		fp, err := backupDatabaseFile(raw, db.SchemaVersion)
+		if err != nil {
+			return err
+		}
+		log.Printf("Backed up current database to %q.", fp)
+	}
+
+	for _, e := range m.Entries {
+		dest, err := backupDest(e.Path)
+		if err != nil {
+			return err
+		}
+		if err := moveFile(path.Join(staging, e.Path), dest); err != nil {
+			return errors.Wrapf(err, "restoring %q", dest)
+		}
+	}
+	log.Printf("Restored backup %q.", m.Name)
+	return nil
+}
+
+// moveFile moves src to dst, copying it if they're on different file systems.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,60 @@
+// Package catalog parses the course listings of the UBC calendar.
+package catalog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/ubccsss/exams/examdb"
+)
+
+// Listing is a course in a calendar page.
+type Listing struct {
+	// Number is the course number, e.g. "110" or "449d".
+	Number string
+	examdb.CatalogEntry
+}
+
+var (
+	spaceRegexp        = regexp.MustCompile(`\s+`)
+	hoursRegexp        = regexp.MustCompile(`\s*\[[\d\-*]+\]`)
+	prerequisiteRegexp = regexp.MustCompile(`(?i)\bpre-?requisites?:\s*(.*?)\s*(?:\b(?:co-?requisites?|equivalency|equivalencies):|$)`)
+	sectionRegexp      = regexp.MustCompile(`(?i)\s*\b(?:pre-?requisites?|co-?requisites?|equivalency|equivalencies):.*$`)
+)
+
+// ParseCalendar returns the courses of the department listed in a calendar
+// courses page. The year of the listings isn't set.
+func ParseCalendar(doc *goquery.Document, dept string) ([]Listing, error) {
+	// The letter after the credits is kept as a suffix of the number since
+	// existing courses are keyed by it.
+	titleRegexp, err := regexp.Compile(fmt.Sprintf(`^(?i:%s)\s+(\d{3}[A-Za-z]?)\s+\(([^)]*)\)\s+(?:([a-z])\s+)?(.+)$`, regexp.QuoteMeta(dept)))
+	if err != nil {
+		return nil, err
+	}
+
+	var listings []Listing
+	doc.Find("dl > dt").Each(func(_ int, s *goquery.Selection) {
+		matches := titleRegexp.FindStringSubmatch(normalizeSpace(s.Text()))
+		if matches == nil {
+			return
+		}
+		l := Listing{Number: strings.ToLower(matches[1] + matches[3])}
+		l.Credits = matches[2]
+		l.Title = matches[4]
+
+		dd := s.NextFiltered("dd")
+		text := hoursRegexp.ReplaceAllString(normalizeSpace(dd.Text()), "")
+		if m := prerequisiteRegexp.FindStringSubmatch(text); m != nil {
+			l.Prerequisites = strings.TrimSpace(m[1])
+		}
+		l.Desc = strings.TrimSpace(sectionRegexp.ReplaceAllString(text, ""))
+		listings = append(listings, l)
+	})
+	return listings, nil
+}
+
+func normalizeSpace(s string) string {
+	return strings.TrimSpace(spaceRegexp.ReplaceAllString(s, " "))
+}
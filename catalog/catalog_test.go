@@ -0,0 +1,90 @@
+package catalog
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/ubccsss/exams/examdb"
+)
+
+func parseFile(t *testing.T, fp, dept string) []Listing {
+	f, err := os.Open(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listings, err := ParseCalendar(doc, dept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listings
+}
+
+func TestParseCalendar(t *testing.T) {
+	cases := []struct {
+		fp, dept string
+		want     []Listing
+	}{
+		{
+			"testdata/cpsc-0506.html", "CPSC",
+			[]Listing{
+				{"101", examdb.CatalogEntry{
+					Title:   "Connecting with Computer Science",
+					Desc:    "Key concepts and innovations in computer science. No programming experience required. Not for credit for students who have credit for, or are concurrently taking CPSC 111.",
+					Credits: "4",
+				}},
+				{"111", examdb.CatalogEntry{
+					Title:         "Introduction to Computation",
+					Desc:          "Fundamental concepts of computing, structured problem solving, object-oriented design and implementation.",
+					Credits:       "4",
+					Prerequisites: "Principles of Mathematics 12.",
+				}},
+				{"260", examdb.CatalogEntry{
+					Title:         "Object-Oriented Program Design",
+					Desc:          "Advanced object-oriented programming and design.",
+					Credits:       "4",
+					Prerequisites: "One of CPSC 111, EECE 256.",
+				}},
+				{"449d", examdb.CatalogEntry{
+					Title:   "Honours Thesis",
+					Desc:    "Research project under the supervision of a faculty member. Restricted to Honours students.",
+					Credits: "6",
+				}},
+				{"501c", examdb.CatalogEntry{
+					Title:   "Topics in Computer Science",
+					Credits: "3-6",
+				}},
+			},
+		},
+		{
+			"testdata/math-1617.html", "math",
+			[]Listing{
+				{"100", examdb.CatalogEntry{
+					Title:         "Differential Calculus with Applications to Physical Sciences and Engineering",
+					Desc:          "Derivatives of elementary functions. Applications and modelling.",
+					Credits:       "3",
+					Prerequisites: "High-school calculus and one of (a), (b): (a) a score of 80% or higher in Principles of Mathematics 12; (b) a satisfactory score in the BCHS Mathematics Diagnostic Test.",
+				}},
+				{"307", examdb.CatalogEntry{
+					Title:         "Applied Linear Algebra",
+					Desc:          "Applications of linear algebra to science and engineering.",
+					Credits:       "3",
+					Prerequisites: "One of MATH 152, MATH 221, MATH 223 and one of MATH 215, MATH 255, MATH 256.",
+				}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		out := parseFile(t, c.fp, c.dept)
+		if !reflect.DeepEqual(out, c.want) {
+			t.Errorf("ParseCalendar(%q) = %+v; not %+v", c.fp, out, c.want)
+		}
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
-import "github.com/urfave/cli"
+import (
+	"github.com/urfave/cli"
+)
 
 func setupCommands() *cli.App {
 	app := cli.NewApp()
 
 	app.HelpName = "The UBCCSSS Exam App"
 
+	app.Flags = configFlags()
+	app.Before = setupSite
+
 	app.Commands = []cli.Command{
 		{
 			Name:    "serve",
@@ -31,6 +36,38 @@ func setupCommands() *cli.App {
 				},
 			},
 		},
+		{
+			Name:    "generate",
+			Aliases: []string{"g"},
+			Usage:   "generate the static HTML files for the site",
+			Action:  generateSite,
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "course",
+					Usage: "Only generate the index and the page for `COURSE`. Can be repeated.",
+				},
+			},
+		},
+		{
+			Name:   "export",
+			Usage:  "export the public site as static files that can be hosted without the server",
+			Action: exportSite,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "out, o",
+					Value: "public",
+					Usage: "Directory to export the site to.",
+				},
+				cli.StringFlag{
+					Name:  "base-url",
+					Usage: "URL the exported site will be hosted at, used for sitemap.xml and feeds. Defaults to site_url from the config.",
+				},
+				cli.BoolFlag{
+					Name:  "exclude-potential",
+					Usage: "Exclude potential and machine learning inferred files.",
+				},
+			},
+		},
 		{
 			Name:   "indexugrad",
 			Usage:  "saves all top level HTML files to archive.org",
@@ -38,19 +75,20 @@ func setupCommands() *cli.App {
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:  "user",
-					Value: "q7w9a",
-					Usage: "ssh username",
+					Usage: "ssh username, defaults to ugrad.user from the config",
 				},
 				cli.StringFlag{
 					Name:  "server",
-					Value: "annacis.ugrad.cs.ubc.ca",
-					Usage: "ssh server",
+					Usage: "ssh server, defaults to ugrad.index_host from the config",
 				},
 			},
 		},
+		setupConfigCommands(),
+		setupDatabaseCommands(),
 		setupEgressCommands(),
 		setupIngressCommands(),
 	}
+	app.Commands = append(app.Commands, setupBackupCommands()...)
 
 	return app
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+
+	"github.com/ubccsss/exams/config"
+	"github.com/urfave/cli"
+)
+
+// configFlags are the global flags that override the configuration.
+func configFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "config, c",
+			Usage:  "Load the configuration from a TOML or YAML `FILE`. Values can be overridden with EXAMS_* environment variables.",
+			EnvVar: "EXAMS_CONFIG",
+		},
+		cli.StringFlag{
+			Name:  "db-file",
+			Usage: "Path to the database, overrides db_file.",
+		},
+		cli.StringFlag{
+			Name:  "static-dir",
+			Usage: "Directory the site is served from, overrides static_dir.",
+		},
+		cli.StringFlag{
+			Name:  "exams-dir",
+			Usage: "Directory the exams and generated pages are written to, overrides exams_dir.",
+		},
+		cli.StringFlag{
+			Name:  "site-url",
+			Usage: "Public URL of the site, overrides site_url.",
+		},
+		cli.BoolFlag{
+			Name:  "remote-layout",
+			Usage: "Scrape the page layout from the live site instead of using the local layout template.",
+		},
+	}
+}
+
+func setupConfigCommands() cli.Command {
+	return cli.Command{
+		Name:  "config",
+		Usage: "inspect the configuration",
+		Subcommands: []cli.Command{
+			{
+				Name:   "show",
+				Usage:  "print the effective configuration as TOML with secrets redacted",
+				Action: showConfig,
+			},
+		},
+	}
+}
+
+// loadConfig loads the configuration file, applies the environment and global
+// flag overrides and validates the result.
+func loadConfig(c *cli.Context) (*config.Config, error) {
+	cfg, err := config.Load(c.String("config"))
+	if err != nil {
+		return nil, err
+	}
+	for flag, field := range map[string]*string{
+		"db-file":    &cfg.DBFile,
+		"static-dir": &cfg.StaticDir,
+		"exams-dir":  &cfg.ExamsDir,
+		"site-url":   &cfg.SiteURL,
+	} {
+		if c.IsSet(flag) {
+			*field = c.String(flag)
+		}
+	}
+	if c.Bool("remote-layout") {
+		cfg.RemoteLayout = true
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// showConfig prints the configuration without its secrets.
+func showConfig(c *cli.Context) error {
+	return cfg.Redacted().Write(os.Stdout)
+}
@@ -1,49 +1,212 @@
 package config
 
 import (
+	"path"
 	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/alecthomas/units"
 )
 
-// Global configuration options.
-var (
-	StaticDir        = "static"
-	ExamsDir         = StaticDir
-	UploadedExamsDir = "uploaded"
-	DBFile           = "data/exams.json"
-	TemplateDir      = "templates"
-	TemplateGlob     = TemplateDir + "/*"
-	ClassifierDir    = "data/classifiers"
-
-	// MaxFileSize is the max size of a file that we'll handle.
-	MaxFileSize = int64(10 * units.MB)
-
-	// Departments are the codes for the departments we support.
-	Departments = []string{ComputerScience, Math, Law}
-
-	// DisplayDepartment is a map for whether or not a department should be
-	// displayed.
-	DisplayDepartment = map[string]bool{
-		ComputerScience: true,
-	}
+// Config is the configuration for the exams app. It's loaded from a TOML or
+// YAML file, environment variables and command line flags, see Load.
+type Config struct {
+	StaticDir        string `toml:"static_dir" yaml:"static_dir"`
+	ExamsDir         string `toml:"exams_dir" yaml:"exams_dir"`
+	UploadedExamsDir string `toml:"uploaded_exams_dir" yaml:"uploaded_exams_dir"`
+	DBFile           string `toml:"db_file" yaml:"db_file"`
+	DBBackupDir      string `toml:"db_backup_dir" yaml:"db_backup_dir"`
+	TemplateDir      string `toml:"template_dir" yaml:"template_dir"`
+	ClassifierDir    string `toml:"classifier_dir" yaml:"classifier_dir"`
+
+	// BackupDir is where backup archives are written to.
+	BackupDir string `toml:"backup_dir" yaml:"backup_dir"`
+	// BackupKeep is the number of backups that are kept when rotating.
+	BackupKeep int `toml:"backup_keep" yaml:"backup_keep"`
+
+	// LayoutFile is the local layout that wraps every generated page. It's a
+	// format string with two %s verbs for the title and the content.
+	LayoutFile string `toml:"layout_file" yaml:"layout_file"`
+	// RemoteLayout makes the generator scrape the layout from LayoutURL instead
+	// of using LayoutFile.
+	RemoteLayout bool `toml:"remote_layout" yaml:"remote_layout"`
+	// LayoutURL is the page that the remote layout is scraped from.
+	LayoutURL string `toml:"layout_url" yaml:"layout_url"`
+	// LayoutCacheFile is where the last good remote layout is cached in case
+	// LayoutURL can't be fetched.
+	LayoutCacheFile string `toml:"layout_cache_file" yaml:"layout_cache_file"`
+
+	// SiteURL is the public URL of the site. It's used for absolute links in
+	// the Atom feeds.
+	SiteURL string `toml:"site_url" yaml:"site_url"`
 
-	// PDFRegexp is the regexp used to detect if a URL is a PDF.
-	PDFRegexp = regexp.MustCompile(`\.pdf(\?.*)?$`)
+	// MaxFileSize is the max size in bytes of a file that we'll handle.
+	MaxFileSize int64 `toml:"max_file_size" yaml:"max_file_size"`
+
+	// Departments are the departments we support.
+	Departments []Department `toml:"departments" yaml:"departments"`
 
 	// ExamFirstPass is terms that indicate a PDF file is an exam.
-	ExamFirstPass = []string{
-		`final`,
-		`midterm`,
-		`sample`,
-		`mt`,
-		`practice`,
-		`exam`,
-		`m\ds\.pdf`,
-		`\Wm\.pdf`,
-		`\Wms\.pdf`,
+	ExamFirstPass []string `toml:"exam_first_pass" yaml:"exam_first_pass"`
+
+	// CrawlProfiles are what exambot crawls, see CrawlProfile.
+	CrawlProfiles []CrawlProfile `toml:"crawl_profiles" yaml:"crawl_profiles"`
+	// Sources are the course platforms exambot has accounts on, see Sources.
+	Sources Sources `toml:"sources" yaml:"sources"`
+
+	Ugrad Ugrad `toml:"ugrad" yaml:"ugrad"`
+}
+
+// Ugrad is the configuration for talking to the department's undergrad
+// servers.
+type Ugrad struct {
+	// User is the SSH user and the owner of the exams.cgi install.
+	User string `toml:"user" yaml:"user"`
+	// SSHHost is the server that course directories are listed on.
+	SSHHost string `toml:"ssh_host" yaml:"ssh_host"`
+	// IndexHost is the server that indexugrad searches for HTML files on.
+	IndexHost string `toml:"index_host" yaml:"index_host"`
+	// ExamsCGIURL is the URL of the exams.cgi binary that lists the files on
+	// the ugrad servers.
+	ExamsCGIURL string `toml:"exams_cgi_url" yaml:"exams_cgi_url"`
+}
+
+// Default returns the default configuration.
+func Default() *Config {
+	return &Config{
+		StaticDir:        "static",
+		ExamsDir:         "static",
+		UploadedExamsDir: "uploaded",
+		DBFile:           "data/exams.json",
+		DBBackupDir:      "data/backups",
+		TemplateDir:      "templates",
+		ClassifierDir:    "data/classifiers",
+
+		BackupDir:  "backups",
+		BackupKeep: 7,
+
+		LayoutFile:      "templates/layout.html",
+		LayoutURL:       "https://ubccsss.org/services/",
+		LayoutCacheFile: "data/layout.html",
+
+		SiteURL: "https://exams.ubccsss.org/",
+
+		MaxFileSize: int64(10 * units.MB),
+
+		Departments: []Department{
+			{
+				Code:    ComputerScience,
+				Name:    "Computer Science",
+				Aliases: []string{"cs"},
+				CourseFormats: []string{
+					"{dept}{number}",
+					"{dept}-{number}",
+					"cs{number}",
+					"{number}",
+				},
+				Public: true,
+			},
+			{
+				Code:          Math,
+				Name:          "Mathematics",
+				CourseFormats: []string{"{dept}{number}", "{dept}-{number}"},
+				Public:        true,
+			},
+			{
+				Code:          Law,
+				Name:          "Law",
+				CourseFormats: []string{"{dept}{number}", "{dept}-{number}"},
+				Public:        true,
+			},
+		},
+
+		ExamFirstPass: []string{
+			`final`,
+			`midterm`,
+			`sample`,
+			`mt`,
+			`practice`,
+			`exam`,
+			`m\ds\.pdf`,
+			`\Wm\.pdf`,
+			`\Wms\.pdf`,
+		},
+
+		CrawlProfiles: defaultCrawlProfiles(),
+
+		Ugrad: Ugrad{
+			User:        "q7w9a",
+			SSHHost:     "remote.ugrad.cs.ubc.ca",
+			IndexHost:   "annacis.ugrad.cs.ubc.ca",
+			ExamsCGIURL: "https://www.ugrad.cs.ubc.ca/~q7w9a/exams.cgi/",
+		},
 	}
-)
+}
+
+// TemplateGlob returns the glob matching all of the templates.
+func (c *Config) TemplateGlob() string {
+	return path.Join(c.TemplateDir, "*")
+}
+
+// Department returns the department with the code or alias, ignoring case,
+// or nil if there isn't one.
+func (c *Config) Department(code string) *Department {
+	if c == nil {
+		return nil
+	}
+	for i, d := range c.Departments {
+		if strings.EqualFold(d.Code, code) {
+			return &c.Departments[i]
+		}
+		for _, alias := range d.Aliases {
+			if strings.EqualFold(alias, code) {
+				return &c.Departments[i]
+			}
+		}
+	}
+	return nil
+}
+
+// PublicDepartment returns whether or not the courses of a department should
+// be published.
+func (c *Config) PublicDepartment(code string) bool {
+	d := c.Department(code)
+	return d != nil && d.Public
+}
+
+// Department is an academic department whose exams are collected.
+type Department struct {
+	// Code is the upper case department code, e.g. CPSC.
+	Code string `toml:"code" yaml:"code"`
+	// Name is the display name of the department.
+	Name string `toml:"name" yaml:"name"`
+	// Aliases are legacy course code prefixes, e.g. "cs" for "cs110".
+	Aliases []string `toml:"aliases" yaml:"aliases"`
+	// CourseFormats are the ways courses are referred to in URLs and file
+	// names. {dept} is replaced with the lower case code and {number} with the
+	// course number.
+	CourseFormats []string `toml:"course_formats" yaml:"course_formats"`
+	// Public is whether the department's courses are published.
+	Public bool `toml:"public" yaml:"public"`
+}
+
+// CourseIDs returns the lower case IDs the course with the number can be
+// referred to by.
+func (d Department) CourseIDs(number int) []string {
+	r := strings.NewReplacer(
+		"{dept}", strings.ToLower(d.Code),
+		"{number}", strconv.Itoa(number),
+	)
+	var ids []string
+	for _, format := range d.CourseFormats {
+		ids = append(ids, strings.ToLower(r.Replace(format)))
+	}
+	return ids
+}
+
+// PDFRegexp is the regexp used to detect if a URL is a PDF.
+var PDFRegexp = regexp.MustCompile(`\.pdf(\?.*)?$`)
 
 // Department codes
 const (
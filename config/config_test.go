@@ -0,0 +1,257 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := path.Join(dir, name)
+	if err := ioutil.WriteFile(fp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
+
+func TestDefaultValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	cases := []struct {
+		name, content string
+	}{
+		{"exams.toml", `
+db_file = "test.json"
+
+[ugrad]
+user = "a1b2c"
+
+[[departments]]
+code = "CPSC"
+aliases = ["cs"]
+public = true
+
+[[departments]]
+code = "MATH"
+public = false
+`},
+		{"exams.yaml", `
+db_file: test.json
+ugrad:
+  user: a1b2c
+departments:
+- code: CPSC
+  aliases: [cs]
+  public: true
+- code: MATH
+  public: false
+`},
+	}
+	for _, c := range cases {
+		fp := writeConfig(t, c.name, c.content)
+		defer os.RemoveAll(path.Dir(fp))
+
+		cfg, err := Load(fp)
+		if err != nil {
+			t.Fatalf("%s: %+v", c.name, err)
+		}
+		if cfg.DBFile != "test.json" {
+			t.Errorf("%s: DBFile = %q", c.name, cfg.DBFile)
+		}
+		if len(cfg.Departments) != 2 {
+			t.Errorf("%s: departments should be replaced; got %+v", c.name, cfg.Departments)
+		}
+		if !cfg.PublicDepartment("cs") || cfg.PublicDepartment(Math) || cfg.PublicDepartment(Law) {
+			t.Errorf("%s: Departments = %+v", c.name, cfg.Departments)
+		}
+		if cfg.Ugrad.User != "a1b2c" || cfg.Ugrad.SSHHost != Default().Ugrad.SSHHost {
+			t.Errorf("%s: Ugrad = %+v", c.name, cfg.Ugrad)
+		}
+		if cfg.StaticDir != Default().StaticDir {
+			t.Errorf("%s: unset keys should keep their defaults; StaticDir = %q", c.name, cfg.StaticDir)
+		}
+	}
+}
+
+func TestLoadFileUnknownKey(t *testing.T) {
+	for name, content := range map[string]string{
+		"exams.toml": `db_flie = "test.json"`,
+		"exams.yml":  `db_flie: test.json`,
+	} {
+		fp := writeConfig(t, name, content)
+		defer os.RemoveAll(path.Dir(fp))
+
+		if _, err := Load(fp); err == nil {
+			t.Errorf("%s: expected error for unknown key", name)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	env := map[string]string{
+		"EXAMS_DB_FILE":             "env.json",
+		"EXAMS_BACKUP_KEEP":         "3",
+		"EXAMS_REMOTE_LAYOUT":       "true",
+		"EXAMS_EXAM_FIRST_PASS":     "final, midterm",
+		"EXAMS_UGRAD_SSH_HOST":      "example.com",
+		"EXAMS_MAX_FILE_SIZE":       "1024",
+		"EXAMS_SOURCES_PIAZZA_USER": "bot@example.com",
+		"EXAMS_UNRELATED_THING":     "ignored",
+	}
+	cfg := Default()
+	err := cfg.loadEnv(func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBFile != "env.json" || cfg.BackupKeep != 3 || !cfg.RemoteLayout || cfg.MaxFileSize != 1024 {
+		t.Errorf("env not applied: %+v", cfg)
+	}
+	if want := []string{"final", "midterm"}; !reflect.DeepEqual(cfg.ExamFirstPass, want) {
+		t.Errorf("ExamFirstPass = %v; not %v", cfg.ExamFirstPass, want)
+	}
+	if cfg.Ugrad.SSHHost != "example.com" {
+		t.Errorf("Ugrad.SSHHost = %q", cfg.Ugrad.SSHHost)
+	}
+	if cfg.Sources.Piazza.User != "bot@example.com" {
+		t.Errorf("Sources.Piazza.User = %q", cfg.Sources.Piazza.User)
+	}
+
+	err = Default().loadEnv(func(key string) (string, bool) {
+		return "many", key == "EXAMS_BACKUP_KEEP"
+	})
+	if err == nil || !strings.Contains(err.Error(), "EXAMS_BACKUP_KEEP") {
+		t.Errorf("expected parse error naming the variable; got %v", err)
+	}
+
+	err = Default().loadEnv(func(key string) (string, bool) {
+		return "CPSC", key == "EXAMS_DEPARTMENTS"
+	})
+	if err == nil {
+		t.Errorf("expected error setting departments from the environment")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.DBFile = ""
+	cfg.BackupKeep = 0
+	cfg.SiteURL = "/relative"
+	cfg.Departments = append(cfg.Departments,
+		Department{Code: "phys", CourseFormats: []string{"{dept}"}},
+		Department{Code: "CS"},
+	)
+	cfg.ExamFirstPass = []string{"("}
+	cfg.CrawlProfiles = append(cfg.CrawlProfiles, CrawlProfile{
+		Name:  "CS",
+		Seeds: []string{"relative/"},
+		Hosts: []CrawlHost{{Host: "example.com", Whitelist: []string{"["}}},
+	})
+	cfg.Sources.Piazza.User = "bot@example.com"
+	cfg.Sources.Mirrors = []MirrorSource{
+		{Scheme: "canvas", Dir: "canvas"},
+		{Scheme: "canvas", Dir: "canvas2"},
+		{Scheme: "https", Dir: "web"},
+		{Host: "example.com"},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected invalid config")
+	}
+	for _, want := range []string{"db_file", "backup_keep", "site_url", `"phys"`, `"{dept}"`, `"CS"`, "exam_first_pass", `crawl profile "CS" is defined more than once`, `"relative/"`, "example.com whitelist", "sources.piazza.password", `mirror "canvas://" is defined more than once`, `mirror "https://": web URLs`, `mirror "://example.com": dir`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %q: %s", want, err)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	want := Default()
+	want.Ugrad.User = "a1b2c"
+
+	var buf bytes.Buffer
+	if err := want.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	fp := writeConfig(t, "exams.toml", buf.String())
+	defer os.RemoveAll(path.Dir(fp))
+
+	got := Default()
+	if err := got.loadFile(fp); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v; not %+v", got, want)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Sources.Piazza = PiazzaSource{User: "bot@example.com", Password: "hunter2"}
+
+	var buf bytes.Buffer
+	if err := cfg.Redacted().Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "hunter2") || !strings.Contains(buf.String(), RedactedSecret) {
+		t.Errorf("redacted config shows the password:\n%s", buf.String())
+	}
+	if cfg.Sources.Piazza.Password != "hunter2" {
+		t.Errorf("Redacted changed the config")
+	}
+}
+
+func TestDepartment(t *testing.T) {
+	cfg := Default()
+	for _, code := range []string{"CPSC", "cpsc", "cs"} {
+		if d := cfg.Department(code); d == nil || d.Code != ComputerScience {
+			t.Errorf("Department(%q) = %+v", code, d)
+		}
+	}
+	if d := cfg.Department("PHYS"); d != nil {
+		t.Errorf("Department(PHYS) = %+v; expected nil", d)
+	}
+
+	want := []string{"cpsc110", "cpsc-110", "cs110", "110"}
+	if got := cfg.Department("cs").CourseIDs(110); !reflect.DeepEqual(got, want) {
+		t.Errorf("CourseIDs(110) = %q; not %q", got, want)
+	}
+}
+
+func TestCrawlProfile(t *testing.T) {
+	cfg := Default()
+	p := cfg.CrawlProfile("CS")
+	if p == nil || p.Name != "cs" {
+		t.Fatalf("CrawlProfile(CS) = %+v", p)
+	}
+	if p := cfg.CrawlProfile("phys"); p != nil {
+		t.Errorf("CrawlProfile(phys) = %+v; expected nil", p)
+	}
+
+	re := regexp.MustCompile(p.OtherDepartmentsPattern())
+	for url, want := range map[string]bool{
+		"https://www.cs.ubc.ca/~a/cpsc110/": false,
+		"https://www.cs.ubc.ca/~a/math200/": true,
+		"https://www.cs.ubc.ca/~a/eece259/": true,
+	} {
+		if got := re.MatchString(url); got != want {
+			t.Errorf("other departments pattern matches %q = %t; not %t", url, got, want)
+		}
+	}
+}
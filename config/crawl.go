@@ -0,0 +1,245 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// CrawlProfile is what exambot crawls for a group of courses.
+type CrawlProfile struct {
+	// Name selects the profile with exambot -profile.
+	Name string `toml:"name" yaml:"name"`
+	// Seeds are the URLs the crawl starts from.
+	Seeds []string `toml:"seeds" yaml:"seeds"`
+	// ArchivePrefixes are searched for on the Internet Archive to find pages
+	// that aren't linked to anymore.
+	ArchivePrefixes []string `toml:"archive_prefixes" yaml:"archive_prefixes"`
+	// Hosts are the only hosts that are crawled.
+	Hosts []CrawlHost `toml:"hosts" yaml:"hosts"`
+	// PageSuffixes are the suffixes of URLs that are pages. URLs with other
+	// extensions aren't crawled.
+	PageSuffixes []string `toml:"page_suffixes" yaml:"page_suffixes"`
+	// Blacklist is regexps matching lower case URLs that shouldn't be crawled.
+	Blacklist []string `toml:"blacklist" yaml:"blacklist"`
+	// Departments are the lower case codes of the departments the profile is
+	// for.
+	Departments []string `toml:"departments" yaml:"departments"`
+	// ExcludeOtherDepartments skips URLs that mention a course of any UBC
+	// department that isn't in Departments.
+	ExcludeOtherDepartments bool `toml:"exclude_other_departments" yaml:"exclude_other_departments"`
+	// Scores prioritize URLs. URLs with lower scores are crawled first.
+	Scores []CrawlScore `toml:"scores" yaml:"scores"`
+	// GitHubOrgs are the GitHub organizations whose repositories and GitHub
+	// Pages are discovered from the GitHub API.
+	GitHubOrgs []string `toml:"github_orgs" yaml:"github_orgs"`
+}
+
+// CrawlHost is a host that is crawled and the rules for its URLs.
+type CrawlHost struct {
+	Host string `toml:"host" yaml:"host"`
+	// Whitelist is regexps, one of which lower case URLs on the host must
+	// match if set.
+	Whitelist []string `toml:"whitelist" yaml:"whitelist"`
+	// Blacklist is regexps matching lower case URLs on the host that
+	// shouldn't be crawled.
+	Blacklist []string `toml:"blacklist" yaml:"blacklist"`
+}
+
+// CrawlScore adds Score to the score of URLs that match each of the patterns.
+type CrawlScore struct {
+	Score    int      `toml:"score" yaml:"score"`
+	Patterns []string `toml:"patterns" yaml:"patterns"`
+}
+
+// CrawlProfile returns the crawl profile with the name or nil if there isn't
+// one.
+func (c *Config) CrawlProfile(name string) *CrawlProfile {
+	for i, p := range c.CrawlProfiles {
+		if strings.EqualFold(p.Name, name) {
+			return &c.CrawlProfiles[i]
+		}
+	}
+	return nil
+}
+
+// OtherDepartmentsPattern returns a regexp matching URLs that mention a
+// course of a UBC department that the profile isn't for.
+func (p CrawlProfile) OtherDepartmentsPattern() string {
+	var codes []string
+	for _, code := range UBCDepartmentCodes {
+		own := false
+		for _, d := range p.Departments {
+			if strings.EqualFold(d, code) {
+				own = true
+				break
+			}
+		}
+		if !own {
+			codes = append(codes, code)
+		}
+	}
+	return fmt.Sprintf(`.*(%s)\d{3}.*`, strings.Join(codes, "|"))
+}
+
+// validate adds the problems with the profile.
+func (p CrawlProfile) validate(addf func(format string, args ...interface{})) {
+	if len(p.Name) == 0 {
+		addf("crawl profiles must have a name")
+	}
+	if len(p.Seeds) == 0 {
+		addf("crawl profile %q: seeds must not be empty", p.Name)
+	}
+	for _, seed := range p.Seeds {
+		if u, err := url.Parse(seed); err != nil || len(u.Scheme) == 0 {
+			addf("crawl profile %q: seed %q must be an absolute URL", p.Name, seed)
+		}
+	}
+	if len(p.Hosts) == 0 {
+		addf("crawl profile %q: hosts must not be empty", p.Name)
+	}
+	patterns := map[string][]string{"blacklist": p.Blacklist}
+	for _, h := range p.Hosts {
+		if len(h.Host) == 0 {
+			addf("crawl profile %q: hosts must have a host", p.Name)
+		}
+		patterns[h.Host+" whitelist"] = h.Whitelist
+		patterns[h.Host+" blacklist"] = h.Blacklist
+	}
+	for _, s := range p.Scores {
+		patterns["scores"] = append(patterns["scores"], s.Patterns...)
+	}
+	for key, list := range patterns {
+		for _, pattern := range list {
+			if _, err := regexp.Compile(pattern); err != nil {
+				addf("crawl profile %q: %s: %s", p.Name, key, err)
+			}
+		}
+	}
+}
+
+// commonBlacklist is URLs on UBC hosts that are never worth crawling.
+var commonBlacklist = []string{
+	`.*\?replytocom=.*`,
+	`.*/bugzilla/.*`,
+	`.*//sites.google.com/.*/system/errors/NodeNotFound.*`,
+}
+
+func defaultCrawlProfiles() []CrawlProfile {
+	return []CrawlProfile{
+		{
+			Name: "cs",
+			Seeds: []string{
+				"https://www.cs.ubc.ca/~schmidtm/Courses/340-F16/",
+				"http://www.cs.ubc.ca/~pcarter/",
+				"https://sites.google.com/site/ubccpsc110/",
+				"https://www.cs.ubc.ca/our-department/people",
+				"https://ubccpsc.github.io",
+				"piazza://",
+			},
+			ArchivePrefixes: []string{
+				"https://www.ugrad.cs.ubc.ca/~",
+				"https://www.cs.ubc.ca/~",
+				"https://blogs.ubc.ca/cpsc",
+				"https://www.cs.ubc.ca/people/",
+			},
+			Hosts: []CrawlHost{
+				{
+					Host: "www.cs.ubc.ca",
+					Blacklist: []string{
+						`.*//www.cs.ubc.ca/~davet/music/.*`,
+						`.*//www.cs.ubc.ca/bookings/.*`,
+						`.*//www.cs.ubc.ca/news-events/calendar/.*`,
+						`.*//www.cs.ubc.ca/print/.*`,
+						`.*people/people/people/people.*`,
+					},
+				},
+				{Host: "www.ugrad.cs.ubc.ca"},
+				{Host: "blogs.ubc.ca"},
+				{
+					Host:      "sites.google.com",
+					Whitelist: []string{`.*(cs|cpsc)\d{3}.*`},
+				},
+				{Host: "ubccpsc.github.io"},
+				{
+					Host:      "github.com",
+					Whitelist: []string{`^https://github.com/ubccpsc.*$`},
+				},
+			},
+			PageSuffixes:            defaultPageSuffixes,
+			Blacklist:               commonBlacklist,
+			Departments:             []string{"cpsc"},
+			ExcludeOtherDepartments: true,
+			Scores: []CrawlScore{
+				{Score: -1, Patterns: []string{"final", "exam", "midterm", "sample", "mt", `(cs|cpsc)\d{3}`, `(20|19)\d{2}`}},
+				{Score: 1, Patterns: []string{"report", "presentation", "thesis", "slide", "print"}},
+			},
+			GitHubOrgs: []string{"ubccpsc"},
+		},
+		{
+			Name:            "math",
+			Seeds:           []string{"https://www.math.ubc.ca/Ugrad/pastExams/"},
+			ArchivePrefixes: []string{"https://www.math.ubc.ca/~"},
+			Hosts: []CrawlHost{
+				{Host: "www.math.ubc.ca"},
+				{Host: "blogs.ubc.ca", Whitelist: []string{`.*math.*`}},
+				{Host: "sites.google.com", Whitelist: []string{`.*math\d{3}.*`}},
+			},
+			PageSuffixes:            defaultPageSuffixes,
+			Blacklist:               commonBlacklist,
+			Departments:             []string{"math"},
+			ExcludeOtherDepartments: true,
+			Scores: []CrawlScore{
+				{Score: -1, Patterns: []string{"final", "exam", "midterm", "sample", "pastexams", `math\d{3}`, `(20|19)\d{2}`}},
+				{Score: 1, Patterns: []string{"seminar", "colloquium", "thesis", "slide", "print"}},
+			},
+		},
+		{
+			Name:  "law",
+			Seeds: []string{"http://law.library.ubc.ca/exams/"},
+			Hosts: []CrawlHost{
+				{Host: "law.library.ubc.ca"},
+				{Host: "www.allard.ubc.ca", Whitelist: []string{`.*(exam|course).*`}},
+			},
+			PageSuffixes:            defaultPageSuffixes,
+			Blacklist:               commonBlacklist,
+			Departments:             []string{"law"},
+			ExcludeOtherDepartments: true,
+			Scores: []CrawlScore{
+				{Score: -1, Patterns: []string{"final", "exam", `law\d{3}`, `(20|19)\d{2}`}},
+				{Score: 1, Patterns: []string{"event", "news", "print"}},
+			},
+		},
+	}
+}
+
+var defaultPageSuffixes = []string{"/", ".html", ".htm", ".cgi", ".php"}
+
+// UBCDepartmentCodes are the lower case codes of all UBC Vancouver
+// departments.
+var UBCDepartmentCodes = []string{
+	"aanb", "acam", "adhe", "afst", "agec", "anae", "anat", "ansc", "anth", "apbi", "appp", "apsc", "arbc", "arc", "arch", "arcl", "arst", "arth", "arts", "asia", "asic", "asla", "astr", "astu", "atsc", "audi",
+	"ba", "baac", "babs", "baen", "bafi", "bahc", "bahr", "baim", "bait", "bala", "bama", "bams", "bapa", "basc", "basd", "basm", "batl", "batm", "baul", "bioc", "biof", "biol", "biot", "bmeg", "bota", "brdg", "busi",
+	"caps", "ccfi", "ccst", "cdst", "ceen", "cell", "cens", "chbe", "chem", "chil", "chin", "cics", "civl", "clch", "clst", "cnps", "cnrs", "cnto", "coec", "cogs", "cohr", "comm", "cons", "cpen", "cpsc", "crwr", "csis", "cspw",
+	"dani", "dent", "derm", "dhyg", "dmed", "dpas", "dsci",
+	"eced", "econ", "edcp", "edst", "educ", "eece", "elec", "eli", "emba", "emer", "ends", "engl", "enph", "enpp", "envr", "eosc", "epse", "etec", "exch", "exgr",
+	"fact", "febc", "fhis", "fipr", "fish", "fist", "fmed", "fmpr", "fmst", "fnel", "fnh", "fnis", "food", "fopr", "fre", "fren", "frsi", "frst",
+	"gbpr", "gem", "gene", "geob", "geog", "germ", "gpp", "grek", "grs", "grsj", "gsat",
+	"hebr", "heso", "hgse", "hinu", "hist", "hpb", "hunu",
+	"iar", "iest", "igen", "inde", "indo", "inds", "info", "isci", "ital", "itst", "iwme",
+	"japn", "jrnl",
+	"kin", "korn",
+	"lais", "larc", "laso", "last", "latn", "law", "lfs", "libe", "libr", "ling", "lled",
+	"math", "mdvl", "mech", "medd", "medg", "medi", "mgmt", "micb", "midw", "mine", "mrne", "mtrl", "musc",
+	"name", "nest", "neur", "nrsc", "nurs",
+	"obms", "obst", "ohs", "onco", "opth", "ornt", "orpa",
+	"paed", "path", "pcth", "pers", "phar", "phil", "phrm", "phth", "phyl", "phys", "plan", "plnt", "poli", "pols", "port", "prin", "psyc", "psyt", "punj",
+	"radi", "relg", "res", "rgla", "rhsc", "rmst", "rsot", "russ",
+	"sans", "scan", "scie", "seal", "slav", "soal", "soci", "soil", "sowk", "span", "spha", "spph", "stat", "sts", "surg", "swed",
+	"test", "thtr", "tibt", "trsc",
+	"udes", "ufor", "ukrn", "uro", "urst", "ursy",
+	"vant", "vgrd", "visa", "vrhc", "vurs",
+	"wood", "wrds", "writ",
+	"zool",
+}
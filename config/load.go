@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of the environment variables that override the
+// configuration. The rest of the name is the upper cased key, with nested
+// keys joined by underscores, e.g. EXAMS_DB_FILE and EXAMS_UGRAD_USER.
+const EnvPrefix = "EXAMS_"
+
+// Load returns the default configuration overridden by the file at fp (if
+// set) and then by the environment. The file is parsed as YAML if it has a
+// .yaml or .yml extension and as TOML otherwise.
+func Load(fp string) (*Config, error) {
+	c := Default()
+	if len(fp) > 0 {
+		if err := c.loadFile(fp); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.loadEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) loadFile(fp string) error {
+	raw, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(fp)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(raw, c)
+	default:
+		var md toml.MetaData
+		md, err = toml.Decode(string(raw), c)
+		if err == nil {
+			if undecoded := md.Undecoded(); len(undecoded) > 0 {
+				err = errors.Errorf("unknown key %q", undecoded[0].String())
+			}
+		}
+	}
+	return errors.Wrapf(err, "parsing %q", fp)
+}
+
+// loadEnv overrides every field that has a matching environment variable.
+// Lists are comma separated.
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	return setFromEnv(reflect.ValueOf(c).Elem(), EnvPrefix, lookup)
+}
+
+func setFromEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		name := prefix + strings.ToUpper(t.Field(i).Tag.Get("toml"))
+		if field.Kind() == reflect.Struct {
+			if err := setFromEnv(field, name+"_", lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		val, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(val)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return errors.Wrapf(err, "parsing $%s", name)
+			}
+			field.SetBool(b)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "parsing $%s", name)
+			}
+			field.SetInt(n)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				return errors.Errorf("$%s can only be set in the config file", name)
+			}
+			var list []string
+			for _, item := range strings.Split(val, ",") {
+				if item = strings.TrimSpace(item); len(item) > 0 {
+					list = append(list, item)
+				}
+			}
+			field.Set(reflect.ValueOf(list))
+		default:
+			return errors.Errorf("unsupported type for $%s: %s", name, field.Type())
+		}
+	}
+	return nil
+}
+
+var departmentCodeRegexp = regexp.MustCompile(`^[A-Z]+$`)
+
+// Validate checks that the configuration is usable and returns all of the
+// problems with it.
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for key, val := range map[string]string{
+		"static_dir":     c.StaticDir,
+		"exams_dir":      c.ExamsDir,
+		"db_file":        c.DBFile,
+		"db_backup_dir":  c.DBBackupDir,
+		"template_dir":   c.TemplateDir,
+		"classifier_dir": c.ClassifierDir,
+		"backup_dir":     c.BackupDir,
+		"layout_file":    c.LayoutFile,
+	} {
+		if len(val) == 0 {
+			addf("%s must be set", key)
+		}
+	}
+	if c.BackupKeep < 1 {
+		addf("backup_keep must be at least 1; got %d", c.BackupKeep)
+	}
+	if c.MaxFileSize <= 0 {
+		addf("max_file_size must be positive; got %d", c.MaxFileSize)
+	}
+	for key, val := range map[string]string{
+		"site_url":            c.SiteURL,
+		"layout_url":          c.LayoutURL,
+		"ugrad.exams_cgi_url": c.Ugrad.ExamsCGIURL,
+	} {
+		if u, err := url.Parse(val); err != nil || !u.IsAbs() {
+			addf("%s must be an absolute URL; got %q", key, val)
+		}
+	}
+	if len(c.Departments) == 0 {
+		addf("departments must not be empty")
+	}
+	seen := map[string]bool{}
+	for _, d := range c.Departments {
+		if !departmentCodeRegexp.MatchString(d.Code) {
+			addf("department code %q must be upper case letters", d.Code)
+		}
+		for _, code := range append([]string{d.Code}, d.Aliases...) {
+			if seen[strings.ToLower(code)] {
+				addf("department code or alias %q is used more than once", code)
+			}
+			seen[strings.ToLower(code)] = true
+		}
+		for _, format := range d.CourseFormats {
+			if !strings.Contains(format, "{number}") {
+				addf("department %s: course format %q must contain {number}", d.Code, format)
+			}
+		}
+	}
+	for _, pattern := range c.ExamFirstPass {
+		if _, err := regexp.Compile(pattern); err != nil {
+			addf("exam_first_pass: %s", err)
+		}
+	}
+	profiles := map[string]bool{}
+	for _, p := range c.CrawlProfiles {
+		if profiles[strings.ToLower(p.Name)] {
+			addf("crawl profile %q is defined more than once", p.Name)
+		}
+		profiles[strings.ToLower(p.Name)] = true
+		p.validate(addf)
+	}
+	c.Sources.validate(addf)
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Write writes the configuration to w as TOML.
+func (c *Config) Write(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(c)
+}
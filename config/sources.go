@@ -0,0 +1,68 @@
+package config
+
+import "strings"
+
+// Sources are the course platforms that exambot fetches with the club's own
+// access instead of crawling them over the web.
+type Sources struct {
+	Piazza PiazzaSource `toml:"piazza" yaml:"piazza"`
+	// Mirrors serve URLs from local directories.
+	Mirrors []MirrorSource `toml:"mirrors" yaml:"mirrors"`
+}
+
+// PiazzaSource is the Piazza account that piazza:// URLs are fetched with.
+// Piazza isn't crawled if User isn't set.
+type PiazzaSource struct {
+	User     string `toml:"user" yaml:"user"`
+	Password string `toml:"password" yaml:"password"`
+}
+
+// MirrorSource serves URLs from files in a local directory, e.g. a Canvas
+// export or a mirror of a course site. Exactly one of Scheme and Host is set.
+type MirrorSource struct {
+	// Scheme is the scheme of the URLs that the mirror serves, e.g. canvas for
+	// canvas://cpsc110/exams/final.pdf. The files of each URL host are in the
+	// directory under Dir named after it.
+	Scheme string `toml:"scheme" yaml:"scheme"`
+	// Host is the host of the web URLs that the mirror serves instead of
+	// fetching them.
+	Host string `toml:"host" yaml:"host"`
+	Dir  string `toml:"dir" yaml:"dir"`
+}
+
+// RedactedSecret is what secrets are replaced with by Config.Redacted.
+const RedactedSecret = "REDACTED"
+
+// Redacted returns a copy of the configuration with the secrets that are set
+// replaced so it can be shown.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if len(redacted.Sources.Piazza.Password) > 0 {
+		redacted.Sources.Piazza.Password = RedactedSecret
+	}
+	return &redacted
+}
+
+// validate adds the problems with the sources.
+func (s Sources) validate(addf func(format string, args ...interface{})) {
+	if len(s.Piazza.User) > 0 && len(s.Piazza.Password) == 0 {
+		addf("sources.piazza.password must be set with the user")
+	}
+	seen := map[string]bool{}
+	for _, m := range s.Mirrors {
+		key := strings.ToLower(m.Scheme) + "://" + strings.ToLower(m.Host)
+		switch {
+		case len(m.Scheme) > 0 && len(m.Host) > 0, len(m.Scheme) == 0 && len(m.Host) == 0:
+			addf("mirror %q: exactly one of scheme and host must be set", key)
+		case strings.EqualFold(m.Scheme, "http"), strings.EqualFold(m.Scheme, "https"):
+			addf("mirror %q: web URLs must be mirrored by host", key)
+		}
+		if len(m.Dir) == 0 {
+			addf("mirror %q: dir must be set", key)
+		}
+		if seen[key] {
+			addf("mirror %q is defined more than once", key)
+		}
+		seen[key] = true
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ubccsss/exams/examdb"
+	"github.com/ubccsss/exams/generators"
+)
+
+// handleCourseIndex lists all courses with links to edit them.
+func handleCourseIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	renderAdminHeader(w)
+
+	db.Mu.RLock()
+	var courses []*examdb.Course
+	for code, c := range db.Courses {
+		if len(code) > 0 {
+			courses = append(courses, c)
+		}
+	}
+	db.Mu.RUnlock()
+	sort.Slice(courses, func(i, j int) bool {
+		return courses[i].Code < courses[j].Code
+	})
+
+	fmt.Fprintf(w, "<title>Courses</title><h1>Courses (%d)</h1><ul>", len(courses))
+	for _, c := range courses {
+		var links []string
+		if len(c.Aliases) > 0 {
+			links = append(links, "aliases: "+strings.Join(c.Aliases, ", "))
+		}
+		if len(c.Predecessors) > 0 {
+			links = append(links, "replaced: "+strings.Join(c.Predecessors, ", "))
+		}
+		if len(c.Successors) > 0 {
+			links = append(links, "replaced by: "+strings.Join(c.Successors, ", "))
+		}
+		fmt.Fprintf(w, `<li><a href="/admin/course/%s">%s</a> %s</li>`,
+			url.PathEscape(c.Code), html.EscapeString(strings.ToUpper(c.Code)), html.EscapeString(strings.Join(links, "; ")))
+	}
+	fmt.Fprint(w, "</ul>")
+}
+
+// handleCourse shows and updates the aliases, predecessors and successors of a
+// course.
+func handleCourse(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimPrefix(r.URL.Path, "/admin/course/")
+	db.Mu.RLock()
+	c, ok := db.Courses[code]
+	var course examdb.Course
+	if ok {
+		course = *c
+	}
+	db.Mu.RUnlock()
+	if !ok {
+		http.Error(w, "not found", 404)
+		return
+	}
+
+	if r.Method == "POST" {
+		handleCoursePost(w, r, course)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	renderAdminHeader(w)
+	data := struct {
+		examdb.Course
+		Name string
+	}{
+		Course: course,
+		Name:   strings.ToUpper(course.Code),
+	}
+	if err := generators.ExecuteTemplate(w, "course_edit.html", data); err != nil {
+		handleErr(w, err)
+		return
+	}
+}
+
+func handleCoursePost(w http.ResponseWriter, r *http.Request, c examdb.Course) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	err := db.SetCourseLinks(c.Code,
+		splitCommaList(r.FormValue("aliases")),
+		splitCommaList(r.FormValue("predecessors")),
+		splitCommaList(r.FormValue("successors")),
+	)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	http.Redirect(w, r, "/admin/course/"+url.PathEscape(c.Code), 302)
+
+	// The previous numbering sections of every linked course can change.
+	codes := []string{c.Code}
+	codes = append(codes, c.Predecessors...)
+	codes = append(codes, c.Successors...)
+	codes = append(codes, db.PreviousCourses(c.Code)...)
+	codes = append(codes, db.NextCourses(c.Code)...)
+	if err := saveAndGenerateCourses(codes...); err != nil {
+		handleErr(w, err)
+		return
+	}
+}
+
+// splitCommaList splits a comma separated list, e.g. of course codes, and
+// drops empty items.
+func splitCommaList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/ubccsss/exams/examdb"
+	"github.com/ubccsss/exams/generators"
+	"github.com/urfave/cli"
+)
+
+// parseCoverageOptions parses the options of a coverage report. Lists are
+// comma separated and empty values are left as the defaults.
+func parseCoverageOptions(from, to, terms, kinds, dept, sortBy string) (examdb.CoverageOptions, error) {
+	opts := examdb.CoverageOptions{Department: dept, Sort: sortBy}
+	var err error
+	if len(from) > 0 {
+		if opts.FromYear, err = strconv.Atoi(from); err != nil {
+			return opts, errors.Wrapf(err, "invalid from year %q", from)
+		}
+	}
+	if len(to) > 0 {
+		if opts.ToYear, err = strconv.Atoi(to); err != nil {
+			return opts, errors.Wrapf(err, "invalid to year %q", to)
+		}
+	}
+	for _, term := range splitCommaList(terms) {
+		t, ok := examdb.NormalizeTerm(term)
+		if !ok {
+			return opts, errors.Errorf("invalid term %q", term)
+		}
+		opts.Terms = append(opts.Terms, t)
+	}
+	for _, kind := range splitCommaList(kinds) {
+		kind = strings.ToLower(kind)
+		if err := (examdb.Label{Kind: kind}).Validate(); err != nil {
+			return opts, err
+		}
+		opts.Kinds = append(opts.Kinds, kind)
+	}
+	switch sortBy {
+	case "", examdb.CoverageByLevel, examdb.CoverageByCourse, examdb.CoverageByMissing:
+	default:
+		return opts, errors.Errorf("invalid sort %q", sortBy)
+	}
+	return opts, nil
+}
+
+func coverageOptionsFromRequest(r *http.Request) (examdb.CoverageOptions, error) {
+	q := r.URL.Query()
+	return parseCoverageOptions(q.Get("from"), q.Get("to"), q.Get("terms"), q.Get("kinds"), q.Get("dept"), q.Get("sort"))
+}
+
+// writeCoverageSummary writes how many exams each course has, is potentially
+// and is missing, one course per line.
+func writeCoverageSummary(w io.Writer, report *examdb.CoverageReport) {
+	fmt.Fprintf(w, "Coverage of %s in %s from %d to %d.\n",
+		strings.Join(report.Kinds, ", "), strings.Join(report.Terms, ", "), report.FromYear, report.ToYear)
+	for _, c := range report.Courses {
+		fmt.Fprintf(w, "%s (%s): %d have, %d potential, %d missing\n", c.Course, c.YearLevel, c.Have, c.Potential, c.Missing)
+	}
+}
+
+func coverageReport(c *cli.Context) error {
+	opts, err := parseCoverageOptions(c.String("from"), c.String("to"), c.String("terms"), c.String("kinds"), c.String("dept"), c.String("sort"))
+	if err != nil {
+		return err
+	}
+	report := db.Coverage(opts)
+
+	switch {
+	case c.Bool("json"):
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case c.Bool("csv"):
+		return report.WriteCSV(os.Stdout)
+	}
+	writeCoverageSummary(os.Stdout, report)
+	return nil
+}
+
+// coverageSession is a column of the coverage page.
+type coverageSession struct {
+	examdb.Session
+	Cells []examdb.CoverageCell
+}
+
+// coverageRow is a course of the coverage page.
+type coverageRow struct {
+	examdb.CourseCoverage
+	Sessions []coverageSession
+}
+
+// handleCoverage renders the coverage report as a table with a row per course
+// and a column per term.
+func handleCoverage(w http.ResponseWriter, r *http.Request) {
+	opts, err := coverageOptionsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	report := db.Coverage(opts)
+
+	w.Header().Set("Content-Type", "text/html")
+	renderAdminHeader(w)
+	q := r.URL.Query()
+	q.Del("dept")
+	base := "/admin/coverage?"
+	if encoded := q.Encode(); len(encoded) > 0 {
+		base += encoded + "&"
+	}
+	renderDepartmentFilter(w, base, opts.Department)
+
+	var rows []coverageRow
+	for _, c := range report.Courses {
+		row := coverageRow{CourseCoverage: c}
+		for i := 0; i < len(c.Cells); i += len(report.Kinds) {
+			cells := c.Cells[i : i+len(report.Kinds)]
+			row.Sessions = append(row.Sessions, coverageSession{
+				Session: examdb.Session{Year: cells[0].Year, Term: cells[0].Term},
+				Cells:   cells,
+			})
+		}
+		rows = append(rows, row)
+	}
+	type sortLink struct {
+		Name, URL string
+		Selected  bool
+	}
+	var sortLinks []sortLink
+	for _, by := range []string{examdb.CoverageByLevel, examdb.CoverageByCourse, examdb.CoverageByMissing} {
+		q := r.URL.Query()
+		q.Set("sort", by)
+		sortLinks = append(sortLinks, sortLink{
+			Name:     by,
+			URL:      "/admin/coverage?" + q.Encode(),
+			Selected: by == opts.Sort || (len(opts.Sort) == 0 && by == examdb.CoverageByLevel),
+		})
+	}
+
+	var header []examdb.Session
+	if len(rows) > 0 {
+		for _, s := range rows[0].Sessions {
+			header = append(header, s.Session)
+		}
+	}
+
+	data := struct {
+		*examdb.CoverageReport
+		Rows       []coverageRow
+		Header     []examdb.Session
+		Department string
+		Sorts      []sortLink
+		Query      string
+		Statuses   []string
+	}{
+		CoverageReport: report,
+		Rows:           rows,
+		Header:         header,
+		Department:     opts.Department,
+		Sorts:          sortLinks,
+		Query:          r.URL.RawQuery,
+		Statuses:       []string{examdb.CoverageHave, examdb.CoveragePotential, examdb.CoverageMissing, examdb.CoverageInactive},
+	}
+	if err := generators.ExecuteTemplate(w, "coverage.html", data); err != nil {
+		handleErr(w, err)
+		return
+	}
+}
+
+func handleCoverageCSV(w http.ResponseWriter, r *http.Request) {
+	opts, err := coverageOptionsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="coverage.csv"`)
+	if err := db.Coverage(opts).WriteCSV(w); err != nil {
+		handleErr(w, err)
+		return
+	}
+}
+
+func handleCoverageJSON(w http.ResponseWriter, r *http.Request) {
+	opts, err := coverageOptionsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(db.Coverage(opts)); err != nil {
+		handleErr(w, err)
+		return
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/ubccsss/exams/examdb"
+	"github.com/urfave/cli"
+)
+
+func setupDatabaseCommands() cli.Command {
+	return cli.Command{
+		Name:  "db",
+		Usage: "manage the exams database",
+		Subcommands: []cli.Command{
+			{
+				Name:   "migrate",
+				Usage:  "migrate the database to the current schema version",
+				Action: migrateDatabase,
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "dry-run, n",
+						Usage: "Report what would change without saving.",
+					},
+				},
+			},
+			{
+				Name:   "fsck",
+				Usage:  "check the database and the files on disk for consistency",
+				Action: fsckDatabase,
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "repair",
+						Usage: "Fix issues that are safe to fix automatically and save the database.",
+					},
+					cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the report as JSON.",
+					},
+				},
+			},
+			{
+				Name:   "coverage",
+				Usage:  "report which exams each course has and is missing by term",
+				Action: coverageReport,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "from",
+						Usage: "First session year to report on. Defaults to ten years ago.",
+					},
+					cli.StringFlag{
+						Name:  "to",
+						Usage: "Last session year to report on. Defaults to the current session.",
+					},
+					cli.StringFlag{
+						Name:  "terms",
+						Usage: "Comma separated terms to report on.",
+						Value: "W1,W2",
+					},
+					cli.StringFlag{
+						Name:  "kinds",
+						Usage: "Comma separated kinds of exams to report on.",
+						Value: "final,midterm",
+					},
+					cli.StringFlag{
+						Name:  "dept",
+						Usage: "Only report on the courses of the department.",
+					},
+					cli.StringFlag{
+						Name:  "sort",
+						Usage: "Sort courses by level, course or missing.",
+						Value: "level",
+					},
+					cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the report as JSON.",
+					},
+					cli.BoolFlag{
+						Name:  "csv",
+						Usage: "Print the report as CSV with a row per course, term and kind.",
+					},
+				},
+			},
+		},
+	}
+}
+
+// backupDatabaseFile writes a copy of the raw database to the backup directory
+// and returns the path. Backups are named by schema version and content hash
+// so backing up the same database twice is a no-op.
+func backupDatabaseFile(raw []byte, version int) (string, error) {
+	hash := sha1.Sum(raw)
+	fp := path.Join(cfg.DBBackupDir, fmt.Sprintf("exams-v%d-%s.json", version, hex.EncodeToString(hash[:])[:12]))
+	if _, err := os.Stat(fp); err == nil {
+		return fp, nil
+	}
+	if err := os.MkdirAll(cfg.DBBackupDir, 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(fp, raw, 0644); err != nil {
+		return "", err
+	}
+	return fp, nil
+}
+
+func migrateDatabase(c *cli.Context) error {
+	dryRun := c.Bool("dry-run")
+
+	// The loaded database has already been migrated in memory so compute the
+	// changes against the database on disk.
+	raw, err := ioutil.ReadFile(cfg.DBFile)
+	if err != nil {
+		return err
+	}
+	onDisk := examdb.Database{Config: cfg}
+	if err := json.Unmarshal(raw, &onDisk); err != nil {
+		return errors.Wrapf(err, "parsing %q", cfg.DBFile)
+	}
+	if !onDisk.NeedsMigration() {
+		fmt.Printf("Database is up to date (schema version %d).\n", onDisk.SchemaVersion)
+		return nil
+	}
+
+	fmt.Printf("Migrating from schema version %d to %d.\n", onDisk.SchemaVersion, examdb.SchemaVersion)
+	results, err := onDisk.Migrate(os.Stdout)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		fmt.Printf("Migration %d (%s): %d changes.\n", r.Version, r.Desc, r.Changes)
+	}
+
+	if dryRun {
+		fmt.Println("Dry run, not saving.")
+		return nil
+	}
+	// Saving backs up the database on disk first.
+	return saveDatabase()
+}
+
+// writeFsckReport writes the report as one issue per line followed by a
+// summary.
+func writeFsckReport(w io.Writer, report *examdb.FsckReport) {
+	for _, issue := range report.Issues {
+		fmt.Fprintln(w, issue)
+	}
+	fmt.Fprintf(w, "Checked %d files: %d issues, %d repaired.\n", report.Files, len(report.Issues), report.Repaired)
+}
+
+func fsckDatabase(c *cli.Context) error {
+	repair := c.Bool("repair")
+	report, err := db.Fsck(examdb.FsckOptions{Repair: repair})
+	if err != nil {
+		return err
+	}
+	if repair && report.Repaired > 0 {
+		if err := saveDatabase(); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		writeFsckReport(os.Stdout, report)
+	}
+
+	if n := report.Unrepaired(); n > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d issues need to be fixed", n), 1)
+	}
+	return nil
+}
+
+// handleFsck checks the database and repairs it if the request is a POST since
+// repairing moves and removes files.
+func handleFsck(w http.ResponseWriter, r *http.Request) {
+	repair := r.Method == "POST"
+	report, err := db.Fsck(examdb.FsckOptions{Repair: repair})
+	if err != nil {
+		handleErr(w, err)
+		return
+	}
+	writeFsckReport(w, report)
+	if repair && report.Repaired > 0 {
+		if err := saveAndGenerate(); err != nil {
+			handleErr(w, err)
+			return
+		}
+	}
+	if !repair && len(report.Issues) > 0 {
+		fmt.Fprint(w, `</pre><form method="POST" action="/admin/fsck"><button>Repair</button></form><pre>`)
+	}
+}
+
+func handleFsckJSON(w http.ResponseWriter, r *http.Request) {
+	report, err := db.Fsck(examdb.FsckOptions{})
+	if err != nil {
+		handleErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		handleErr(w, err)
+		return
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"strings"
 
 	zglob "github.com/mattn/go-zglob"
-	"github.com/ubccsss/exams/config"
 	"github.com/ubccsss/exams/examdb"
 )
 
@@ -18,21 +17,21 @@ import (
 func findDuplicates(w io.Writer, db *examdb.Database) ([]string, error) {
 	var duplicate []string
 
-	pattern := path.Join(config.StaticDir, "**/*.pdf*")
+	pattern := path.Join(cfg.StaticDir, "**/*.pdf*")
 	paths, err := zglob.Glob(pattern)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, path := range paths {
-		// Strip off config.StaticDir
-		staticPath := strings.TrimPrefix(path, config.StaticDir+"/")
+		// Strip off cfg.StaticDir
+		staticPath := strings.TrimPrefix(path, cfg.StaticDir+"/")
 		f := db.FindFileByPath(staticPath)
 		if f == nil {
 			f := examdb.File{
 				Path: staticPath,
 			}
-			if err := f.ComputeHash(); err != nil {
+			if err := f.ComputeHash(cfg); err != nil {
 				return nil, err
 			}
 			f2 := db.FindFile(f.Hash)
@@ -67,7 +66,7 @@ func handleRemoveDuplicates(w http.ResponseWriter, r *http.Request) {
 	}
 	for _, d := range duplicates {
 		fmt.Fprintf(w, "Removing: %s\n", d)
-		p := path.Join(config.StaticDir, d)
+		p := path.Join(cfg.StaticDir, d)
 		if err := os.Remove(p); err != nil {
 			handleErr(w, err)
 			return
@@ -81,9 +80,8 @@ func handleListIncorrectLocations(w http.ResponseWriter, r *http.Request) {
 		if len(f.Path) == 0 {
 			continue
 		}
-		dir := f.IdealDir()
-		if !strings.HasPrefix(f.Path, dir) {
-			fmt.Fprintf(w, "%s: %s\n", dir, f.Path)
+		if !db.InIdealDir(f) {
+			fmt.Fprintf(w, "%s: %s\n", f.IdealDir(), f.Path)
 		}
 	}
 	w.Write([]byte("Done."))
@@ -48,7 +48,7 @@ func exportToML(c *cli.Context) error {
 
 func convertAndSaveFileAsML(f *examdb.File, class int) error {
 	log.Printf("Egressing %s", f)
-	in, err := f.Reader()
+	in, err := f.Reader(cfg)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ubccsss/exams/exambot/exambotlib"
+)
+
+// maxSitemaps is the most sitemaps fetched per host, including the ones listed
+// in sitemap indexes.
+const maxSitemaps = 20
+
+// discover starts finding URLs related to the URL that pages don't link to.
+// Each sitemap, GitHub repository and Google Site is only discovered once.
+func (s *Spider) discover(uri string) {
+	u, err := url.Parse(uri)
+	// Fetched URLs don't have anything to discover on the web.
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || s.Fetchers.Lookup(u) != nil {
+		return
+	}
+	if s.firstDiscovery("sitemap:" + u.Host) {
+		go s.discoverSitemaps(u)
+	}
+	if repo, ok := exambotlib.FindGitHubRepo(u, s.Scope.Profile.GitHubOrgs); ok {
+		if s.firstDiscovery("github:" + repo.Owner + "/" + repo.Repo) {
+			go s.discoverGitHub(repo)
+		}
+	}
+	if hierarchy, ok := exambotlib.GoogleSitesHierarchy(u); ok && s.firstDiscovery(hierarchy) {
+		// The hierarchy is a static page so it's crawled like any other.
+		s.AddURLs([]string{hierarchy})
+	}
+}
+
+// firstDiscovery returns whether discovery of key hasn't been started yet and
+// marks it as started.
+func (s *Spider) firstDiscovery(key string) bool {
+	s.discoveredMu.Lock()
+	defer s.discoveredMu.Unlock()
+
+	if _, ok := s.discovered[key]; ok {
+		return false
+	}
+	s.discovered[key] = struct{}{}
+	return true
+}
+
+// discoverSitemaps adds the URLs in the sitemaps of the host listed in its
+// robots.txt, or at /sitemap.xml if there aren't any.
+func (s *Spider) discoverSitemaps(u *url.URL) {
+	var queue []string
+	if robots := s.robotsData(u.Host); robots != nil {
+		queue = append(queue, robots.Sitemaps...)
+	}
+	if len(queue) == 0 {
+		queue = append(queue, exambotlib.DefaultSitemap(u))
+	}
+
+	seen := map[string]struct{}{}
+	for len(queue) > 0 && len(seen) < maxSitemaps {
+		sitemap := queue[0]
+		queue = queue[1:]
+		if _, ok := seen[sitemap]; ok {
+			continue
+		}
+		seen[sitemap] = struct{}{}
+
+		var urls, sitemaps []string
+		err := s.discoverFrom(sitemap, func(r io.Reader) ([]string, error) {
+			var err error
+			urls, sitemaps, err = exambotlib.ParseSitemap(r)
+			return urls, err
+		})
+		if err != nil {
+			log.Printf("DISCOVER sitemap err: %s", err)
+			continue
+		}
+		queue = append(queue, sitemaps...)
+	}
+}
+
+// discoverGitHub adds the repositories of the org or the files in the
+// repository.
+func (s *Spider) discoverGitHub(repo exambotlib.GitHubRepo) {
+	var err error
+	if len(repo.Repo) == 0 {
+		err = s.discoverFrom(repo.ReposURL(), exambotlib.ParseGitHubRepos)
+	} else {
+		err = s.discoverFrom(repo.TreeURL(), repo.ParseTree)
+	}
+	if err != nil {
+		log.Printf("DISCOVER GitHub err: %s", err)
+	}
+}
+
+// acquireHost waits until a request can be made to the host like the workers
+// do. It returns false if the spider is drained first.
+func (s *Spider) acquireHost(host string) bool {
+	for s.waitRunning() {
+		wait, ok := s.Hosts.Acquire(host)
+		if ok {
+			return true
+		}
+		time.Sleep(wait)
+	}
+	return false
+}
+
+// discoverFrom fetches the listing, parses the URLs out of it, saves it as a
+// discovered page so the URLs are exported with the pages and adds the URLs.
+// The listing is fetched with the same robots.txt rules and host limits as
+// crawled pages.
+func (s *Spider) discoverFrom(listing string, parse func(io.Reader) ([]string, error)) error {
+	u, err := url.Parse(listing)
+	if err != nil {
+		return err
+	}
+	if !s.allowedByRobots(listing) {
+		return fmt.Errorf("%q is disallowed by robots.txt", listing)
+	}
+	if !s.acquireHost(u.Host) {
+		return fmt.Errorf("drained before fetching %q", listing)
+	}
+	resp, err := makeGet(listing)
+	if err != nil {
+		s.Hosts.Release(u.Host, true)
+		s.Monitor.Failed(u.Host)
+		return err
+	}
+	defer resp.Body.Close()
+
+	var urls []string
+	if resp.StatusCode == http.StatusOK {
+		urls, err = parse(resp.Body)
+	}
+	// APIs like GitHub's return 403 once their rate limit is used up.
+	failed := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 ||
+		resp.Header.Get("X-RateLimit-Remaining") == "0"
+	s.Hosts.Release(u.Host, failed)
+	s.Monitor.Fetched(u.Host, resp.StatusCode, failed)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expected %q to return 200; got %d", listing, resp.StatusCode)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing %q: %s", listing, err)
+	}
+
+	hash := sha1.Sum([]byte(strings.Join(urls, "\n")))
+	if err := s.savePage(Page{
+		URL:        listing,
+		StatusCode: resp.StatusCode,
+		Hash:       hex.EncodeToString(hash[:]),
+		Fetched:    time.Now(),
+		Links:      urls,
+		Discovered: true,
+	}); err != nil {
+		return err
+	}
+	log.Printf("DISCOVER %d URLs from %s", len(urls), listing)
+	s.AddAndExpandURLs(urls, false)
+	return nil
+}
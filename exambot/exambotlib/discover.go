@@ -0,0 +1,168 @@
+package exambotlib
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/ubccsss/exams/config"
+)
+
+// Sites that render their links with JavaScript have nothing for goquery to
+// find, so URLs on them are discovered from sitemaps and APIs instead.
+
+type sitemap struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// ParseSitemap returns the page URLs in a sitemap and the URLs of the
+// sitemaps listed if it's a sitemap index.
+func ParseSitemap(r io.Reader) (urls []string, sitemaps []string, err error) {
+	var s sitemap
+	if err := xml.NewDecoder(r).Decode(&s); err != nil {
+		return nil, nil, err
+	}
+	for _, u := range s.URLs {
+		if loc := strings.TrimSpace(u.Loc); len(loc) > 0 {
+			urls = append(urls, loc)
+		}
+	}
+	for _, u := range s.Sitemaps {
+		if loc := strings.TrimSpace(u.Loc); len(loc) > 0 {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+	return urls, sitemaps, nil
+}
+
+// DefaultSitemap returns where the sitemap of the URL's host usually is.
+func DefaultSitemap(u *url.URL) string {
+	return fmt.Sprintf("%s://%s/sitemap.xml", u.Scheme, u.Host)
+}
+
+// GitHubRepo is a repository whose files are discovered from its tree.
+type GitHubRepo struct {
+	Owner, Repo string
+	// Pages is set if the files are served by GitHub Pages.
+	Pages bool
+}
+
+// FindGitHubRepo returns the repository that the URL is in if it's owned by
+// one of the orgs. Org URLs without a repository return just the owner.
+// GitHub Pages of a project are under the repository name and the other
+// pages are in the <org>.github.io repository.
+func FindGitHubRepo(u *url.URL, orgs []string) (GitHubRepo, bool) {
+	host := strings.ToLower(u.Host)
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for _, org := range orgs {
+		org = strings.ToLower(org)
+		if host == org+".github.io" {
+			repo := GitHubRepo{Owner: org, Repo: host, Pages: true}
+			// A single segment with an extension is a file of the org site.
+			if len(parts[0]) > 0 && (len(parts) > 1 || path.Ext(parts[0]) == "") {
+				repo.Repo = parts[0]
+			}
+			return repo, true
+		}
+		if host != "github.com" || strings.ToLower(parts[0]) != org {
+			continue
+		}
+		repo := GitHubRepo{Owner: org}
+		if len(parts) > 1 {
+			repo.Repo = parts[1]
+		}
+		return repo, true
+	}
+	return GitHubRepo{}, false
+}
+
+// ReposURL returns the API URL listing the repositories of the owner.
+func (r GitHubRepo) ReposURL() string {
+	return fmt.Sprintf("https://api.github.com/orgs/%s/repos?per_page=100", r.Owner)
+}
+
+// TreeURL returns the API URL listing every file in the repository.
+func (r GitHubRepo) TreeURL() string {
+	return fmt.Sprintf("https://api.github.com/repos/%s/%s/git/trees/HEAD?recursive=1", r.Owner, r.Repo)
+}
+
+// pagesSite returns the GitHub Pages site of the org.
+func (r GitHubRepo) pagesSite() string {
+	return r.Owner + ".github.io"
+}
+
+// FileURL returns where the file at the path in the repository can be
+// fetched. PDFs are fetched raw, other files from GitHub Pages if the
+// repository is served by it or otherwise the GitHub page for the file.
+func (r GitHubRepo) FileURL(p string) string {
+	switch {
+	case r.Pages && r.Repo == r.pagesSite():
+		return fmt.Sprintf("https://%s/%s", r.Repo, p)
+	case r.Pages:
+		return fmt.Sprintf("https://%s/%s/%s", r.pagesSite(), r.Repo, p)
+	case config.PDFRegexp.MatchString(strings.ToLower(p)):
+		return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/HEAD/%s", r.Owner, r.Repo, p)
+	default:
+		return fmt.Sprintf("https://github.com/%s/%s/blob/HEAD/%s", r.Owner, r.Repo, p)
+	}
+}
+
+// ParseGitHubRepos returns the URLs of the repositories in a GitHub API
+// repository listing.
+func ParseGitHubRepos(r io.Reader) ([]string, error) {
+	var repos []struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(r).Decode(&repos); err != nil {
+		return nil, err
+	}
+	var urls []string
+	for _, repo := range repos {
+		urls = append(urls, repo.HTMLURL)
+	}
+	return urls, nil
+}
+
+// ParseTree returns the URLs of the files in a GitHub API tree of the
+// repository.
+func (r GitHubRepo) ParseTree(reader io.Reader) ([]string, error) {
+	var tree struct {
+		Tree []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+		} `json:"tree"`
+	}
+	if err := json.NewDecoder(reader).Decode(&tree); err != nil {
+		return nil, err
+	}
+	var urls []string
+	for _, entry := range tree.Tree {
+		if entry.Type != "blob" {
+			continue
+		}
+		urls = append(urls, r.FileURL(entry.Path))
+	}
+	return urls, nil
+}
+
+// GoogleSitesHierarchy returns the static page of a classic Google Site that
+// links to every page of the site.
+func GoogleSitesHierarchy(u *url.URL) (string, bool) {
+	if u.Host != "sites.google.com" {
+		return "", false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "site" {
+		return "", false
+	}
+	return "https://sites.google.com/" + path.Join(parts[0], parts[1], "system/app/pages/sitemap/hierarchy"), true
+}
@@ -0,0 +1,151 @@
+package exambotlib
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSitemap(t *testing.T) {
+	cases := []struct {
+		xml            string
+		urls, sitemaps []string
+	}{
+		{
+			`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>https://ubccpsc.github.io/</loc></url>
+	<url><loc>
+		https://ubccpsc.github.io/110/exams.html
+	</loc><lastmod>2017-01-01</lastmod></url>
+</urlset>`,
+			[]string{"https://ubccpsc.github.io/", "https://ubccpsc.github.io/110/exams.html"},
+			nil,
+		},
+		{
+			`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>https://example.com/sitemap1.xml</loc></sitemap>
+</sitemapindex>`,
+			nil,
+			[]string{"https://example.com/sitemap1.xml"},
+		},
+	}
+	for i, c := range cases {
+		urls, sitemaps, err := ParseSitemap(strings.NewReader(c.xml))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(urls, c.urls) || !reflect.DeepEqual(sitemaps, c.sitemaps) {
+			t.Errorf("%d. ParseSitemap = %+v, %+v; not %+v, %+v", i, urls, sitemaps, c.urls, c.sitemaps)
+		}
+	}
+
+	if _, _, err := ParseSitemap(strings.NewReader("<html>")); err == nil {
+		t.Error("expected an error for HTML")
+	}
+}
+
+func TestFindGitHubRepo(t *testing.T) {
+	orgs := []string{"ubccpsc"}
+	cases := []struct {
+		uri  string
+		want GitHubRepo
+		ok   bool
+	}{
+		{"https://github.com/ubccpsc", GitHubRepo{Owner: "ubccpsc"}, true},
+		{"https://github.com/ubccpsc/310/tree/master/exams", GitHubRepo{Owner: "ubccpsc", Repo: "310"}, true},
+		{"https://ubccpsc.github.io/", GitHubRepo{Owner: "ubccpsc", Repo: "ubccpsc.github.io", Pages: true}, true},
+		{"https://ubccpsc.github.io/index.html", GitHubRepo{Owner: "ubccpsc", Repo: "ubccpsc.github.io", Pages: true}, true},
+		{"https://ubccpsc.github.io/310/exams/final.pdf", GitHubRepo{Owner: "ubccpsc", Repo: "310", Pages: true}, true},
+		{"https://ubccpsc.github.io/110", GitHubRepo{Owner: "ubccpsc", Repo: "110", Pages: true}, true},
+		{"https://github.com/other/310", GitHubRepo{}, false},
+		{"https://www.cs.ubc.ca/ubccpsc/310", GitHubRepo{}, false},
+	}
+	for i, c := range cases {
+		u, err := url.Parse(c.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, ok := FindGitHubRepo(u, orgs)
+		if out != c.want || ok != c.ok {
+			t.Errorf("%d. FindGitHubRepo(%q) = %+v, %t; not %+v, %t", i, c.uri, out, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestParseGitHub(t *testing.T) {
+	urls, err := ParseGitHubRepos(strings.NewReader(`[{"name": "310", "html_url": "https://github.com/ubccpsc/310"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://github.com/ubccpsc/310"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("ParseGitHubRepos = %+v; not %+v", urls, want)
+	}
+
+	tree := `{"tree": [
+		{"path": "exams", "type": "tree"},
+		{"path": "exams/final.pdf", "type": "blob"},
+		{"path": "README.md", "type": "blob"}
+	]}`
+	repo := GitHubRepo{Owner: "ubccpsc", Repo: "310"}
+	urls, err = repo.ParseTree(strings.NewReader(tree))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://raw.githubusercontent.com/ubccpsc/310/HEAD/exams/final.pdf",
+		"https://github.com/ubccpsc/310/blob/HEAD/README.md",
+	}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("ParseTree = %+v; not %+v", urls, want)
+	}
+
+	pages := GitHubRepo{Owner: "ubccpsc", Repo: "ubccpsc.github.io", Pages: true}
+	urls, err = pages.ParseTree(strings.NewReader(tree))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"https://ubccpsc.github.io/exams/final.pdf",
+		"https://ubccpsc.github.io/README.md",
+	}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("ParseTree = %+v; not %+v", urls, want)
+	}
+
+	project := GitHubRepo{Owner: "ubccpsc", Repo: "310", Pages: true}
+	urls, err = project.ParseTree(strings.NewReader(tree))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"https://ubccpsc.github.io/310/exams/final.pdf",
+		"https://ubccpsc.github.io/310/README.md",
+	}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("ParseTree = %+v; not %+v", urls, want)
+	}
+}
+
+func TestGoogleSitesHierarchy(t *testing.T) {
+	cases := []struct {
+		uri, want string
+		ok        bool
+	}{
+		{"https://sites.google.com/site/ubccpsc110/exams", "https://sites.google.com/site/ubccpsc110/system/app/pages/sitemap/hierarchy", true},
+		{"https://sites.google.com/site/ubccpsc110", "https://sites.google.com/site/ubccpsc110/system/app/pages/sitemap/hierarchy", true},
+		{"https://sites.google.com/view/cpsc110", "", false},
+		{"https://www.cs.ubc.ca/site/cpsc110", "", false},
+	}
+	for i, c := range cases {
+		u, err := url.Parse(c.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, ok := GoogleSitesHierarchy(u)
+		if out != c.want || ok != c.ok {
+			t.Errorf("%d. GoogleSitesHierarchy(%q) = %q, %t; not %q, %t", i, c.uri, out, ok, c.want, c.ok)
+		}
+	}
+}
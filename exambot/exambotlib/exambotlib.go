@@ -57,3 +57,9 @@ func ValidSuffix(uri string, validPostfix []string) bool {
 	}
 	return validExt
 }
+
+// CleanText collapses runs of whitespace in text from a page into single
+// spaces.
+func CleanText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
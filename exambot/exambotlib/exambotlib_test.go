@@ -85,3 +85,20 @@ func TestValidSuffix(t *testing.T) {
 		}
 	}
 }
+
+func TestCleanText(t *testing.T) {
+	cases := []struct {
+		text, want string
+	}{
+		{"", ""},
+		{"Final", "Final"},
+		{"\n  2016 W1\n\tFinal  (PDF) ", "2016 W1 Final (PDF)"},
+	}
+
+	for i, c := range cases {
+		out := CleanText(c.text)
+		if out != c.want {
+			t.Errorf("%d. CleanText(%q) = %q; not %q", i, c.text, out, c.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package exambotlib
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Fetcher fetches the URLs of a source that isn't crawled over the web, e.g. a
+// course platform that the club has an account on.
+type Fetcher interface {
+	// Fetch returns the response for the URL.
+	Fetch(u *url.URL) (*http.Response, error)
+	// AlwaysVisit returns whether the URL is crawled every time it's found,
+	// e.g. an index of the account's courses.
+	AlwaysVisit(u *url.URL) bool
+	// Opaque returns whether the paths of the fetcher's URLs aren't a
+	// hierarchy, so relative links on its pages aren't followed and its URLs
+	// aren't expanded to their parents.
+	Opaque() bool
+}
+
+// Fetchers are the fetchers of URL schemes and web hosts.
+type Fetchers struct {
+	schemes map[string]Fetcher
+	hosts   map[string]Fetcher
+}
+
+// MakeFetchers makes an empty set of fetchers.
+func MakeFetchers() *Fetchers {
+	return &Fetchers{
+		schemes: map[string]Fetcher{},
+		hosts:   map[string]Fetcher{},
+	}
+}
+
+// AddScheme fetches all URLs with the scheme with f.
+func (fs *Fetchers) AddScheme(scheme string, f Fetcher) error {
+	scheme = strings.ToLower(scheme)
+	if _, ok := fs.schemes[scheme]; ok {
+		return fmt.Errorf("scheme %q already has a fetcher", scheme)
+	}
+	fs.schemes[scheme] = f
+	return nil
+}
+
+// AddHost fetches the web URLs of the host with f.
+func (fs *Fetchers) AddHost(host string, f Fetcher) error {
+	host = strings.ToLower(host)
+	if _, ok := fs.hosts[host]; ok {
+		return fmt.Errorf("host %q already has a fetcher", host)
+	}
+	fs.hosts[host] = f
+	return nil
+}
+
+// Lookup returns the fetcher for the URL or nil if it's fetched over the web.
+func (fs *Fetchers) Lookup(u *url.URL) Fetcher {
+	if f, ok := fs.schemes[strings.ToLower(u.Scheme)]; ok {
+		return f
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	return fs.hosts[strings.ToLower(u.Host)]
+}
+
+// Schemes returns the schemes that have fetchers.
+func (fs *Fetchers) Schemes() []string {
+	var schemes []string
+	for scheme := range fs.schemes {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// makeResponse makes a response to a GET of the URL.
+func makeResponse(u *url.URL, statusCode int, contentType, body string) *http.Response {
+	header := http.Header{}
+	if len(contentType) > 0 {
+		header.Set("Content-Type", contentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       &http.Request{Method: "GET", URL: u},
+	}
+}
+
+// DirFetcher serves URLs from the files in a directory, e.g. a Canvas export
+// or a mirror of a course site. Directories without an index.html are served
+// as a page that links to their entries.
+type DirFetcher struct {
+	Dir string
+	// HostDirs is set if the files of each URL host are in the directory
+	// named after it.
+	HostDirs bool
+}
+
+// MakeDirFetcher makes a new fetcher serving the files in dir.
+func MakeDirFetcher(dir string, hostDirs bool) *DirFetcher {
+	return &DirFetcher{Dir: dir, HostDirs: hostDirs}
+}
+
+// Fetch returns the file for the URL or a 404 if there isn't one.
+func (f *DirFetcher) Fetch(u *url.URL) (*http.Response, error) {
+	p := path.Clean("/" + u.Path)
+	if f.HostDirs {
+		p = path.Join("/", u.Host, p)
+	}
+	fp := filepath.Join(f.Dir, filepath.FromSlash(p))
+
+	info, err := os.Stat(fp)
+	if os.IsNotExist(err) {
+		return makeResponse(u, http.StatusNotFound, "", ""), nil
+	} else if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		index := filepath.Join(fp, "index.html")
+		if _, err := os.Stat(index); err != nil {
+			return f.listing(u, fp)
+		}
+		fp = index
+	}
+
+	file, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(fp))
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+	resp := makeResponse(u, http.StatusOK, contentType, "")
+	resp.Body = file
+	resp.ContentLength = info.Size()
+	return resp, nil
+}
+
+// listing returns a page linking to the entries of the directory.
+func (f *DirFetcher) listing(u *url.URL, dir string) (*http.Response, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	body.WriteString("<ul>\n")
+	for _, e := range entries {
+		link := *u
+		link.Path = path.Join(u.Path, e.Name())
+		if e.IsDir() {
+			link.Path += "/"
+		}
+		link.RawQuery = ""
+		link.Fragment = ""
+		fmt.Fprintf(&body, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(link.String()), html.EscapeString(e.Name()))
+	}
+	body.WriteString("</ul>\n")
+	return makeResponse(u, http.StatusOK, "text/html", body.String()), nil
+}
+
+// AlwaysVisit returns false since the files only change when they're copied
+// again.
+func (f *DirFetcher) AlwaysVisit(u *url.URL) bool {
+	return false
+}
+
+// Opaque returns false since URLs are paths in the directory.
+func (f *DirFetcher) Opaque() bool {
+	return false
+}
@@ -0,0 +1,107 @@
+package exambotlib
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFetchersLookup(t *testing.T) {
+	fs := MakeFetchers()
+	canvas := MakeDirFetcher("canvas", true)
+	mirror := MakeDirFetcher("mirror", false)
+	if err := fs.AddScheme("Canvas", canvas); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.AddHost("www.ugrad.cs.ubc.ca", mirror); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.AddScheme("canvas", mirror); err == nil {
+		t.Error("expected an error adding a scheme twice")
+	}
+
+	for uri, want := range map[string]Fetcher{
+		"canvas://cpsc110/files/":                   canvas,
+		"https://www.ugrad.cs.ubc.ca/~cs110/":       mirror,
+		"http://WWW.ugrad.cs.ubc.ca/~cs110/":        mirror,
+		"https://www.cs.ubc.ca/~cs110/":             nil,
+		"ftp://www.ugrad.cs.ubc.ca/~cs110/exam.pdf": nil,
+	} {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fs.Lookup(u); got != want {
+			t.Errorf("Lookup(%q) = %+v; not %+v", uri, got, want)
+		}
+	}
+	if got, want := fs.Schemes(), []string{"canvas"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Schemes = %q; not %q", got, want)
+	}
+}
+
+func TestDirFetcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirfetcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for fp, body := range map[string]string{
+		"canvas/cpsc110/exams/final.pdf":  "final",
+		"canvas/cpsc110/notes/index.html": "<a href=\"1.html\">1</a>",
+		"secret":                          "outside",
+	} {
+		fp = filepath.Join(dir, filepath.FromSlash(fp))
+		if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fp, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := MakeDirFetcher(filepath.Join(dir, "canvas"), true)
+	cases := []struct {
+		uri         string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"canvas://cpsc110/exams/final.pdf", 200, "application/pdf", "final"},
+		{"canvas://cpsc110/notes/", 200, "text/html; charset=utf-8", "<a href=\"1.html\">1</a>"},
+		{"canvas://cpsc110/exams", 200, "text/html", "<ul>\n<li><a href=\"canvas://cpsc110/exams/final.pdf\">final.pdf</a></li>\n</ul>\n"},
+		{"canvas://cpsc110/", 200, "text/html", "<ul>\n<li><a href=\"canvas://cpsc110/exams/\">exams</a></li>\n<li><a href=\"canvas://cpsc110/notes/\">notes</a></li>\n</ul>\n"},
+		{"canvas://cpsc110/midterm.pdf", 404, "", ""},
+		{"canvas://cpsc110/../../secret", 404, "", ""},
+	}
+	for _, c := range cases {
+		u, err := url.Parse(c.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := f.Fetch(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != c.status || resp.Header.Get("Content-Type") != c.contentType || string(body) != c.body {
+			t.Errorf("Fetch(%q) = %d %q %q; not %d %q %q", c.uri, resp.StatusCode, resp.Header.Get("Content-Type"), body, c.status, c.contentType, c.body)
+		}
+	}
+
+	if resp, err := MakeDirFetcher(dir, false).Fetch(&url.URL{Scheme: "https", Host: "example.com", Path: "/secret"}); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Fetch of a web URL = %+v, %v", resp, err)
+	}
+	if f.Opaque() || f.AlwaysVisit(&url.URL{}) {
+		t.Errorf("DirFetcher = %+v", f)
+	}
+}
@@ -0,0 +1,95 @@
+package exambotlib
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrTooLarge is returned when a file is larger than the store accepts.
+var ErrTooLarge = errors.New("file is too large")
+
+// FileStore stores files by the SHA1 hash of their contents so the same file
+// linked from many URLs is only stored once.
+type FileStore struct {
+	Dir string
+	// MaxSize is the largest file in bytes that is stored.
+	MaxSize int64
+}
+
+// StoredFile is a file the crawler fetched and the pages that link to it.
+type StoredFile struct {
+	URL         string
+	Hash        string    `json:",omitempty"`
+	ContentType string    `json:",omitempty"`
+	Size        int64     `json:",omitempty"`
+	StatusCode  int       `json:",omitempty"`
+	Fetched     time.Time `json:",omitempty"`
+	// Referrers are the pages that link to the file.
+	Referrers []string `json:",omitempty"`
+}
+
+// AddReferrer adds the page to the referrers and returns whether it's new.
+func (f *StoredFile) AddReferrer(page string) bool {
+	for _, r := range f.Referrers {
+		if r == page {
+			return false
+		}
+	}
+	f.Referrers = append(f.Referrers, page)
+	return true
+}
+
+// MakeFileStore makes a new file store in dir.
+func MakeFileStore(dir string, maxSize int64) *FileStore {
+	return &FileStore{Dir: dir, MaxSize: maxSize}
+}
+
+// Path returns where the file with the hash is stored.
+func (s *FileStore) Path(hash string) string {
+	return filepath.Join(s.Dir, hash[:2], hash)
+}
+
+// Put stores the contents of r and returns its hash and size. Files larger
+// than MaxSize aren't stored and return ErrTooLarge.
+func (s *FileStore) Put(r io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return "", 0, err
+	}
+	tmp, err := ioutil.TempFile(s.Dir, "tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha1.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(r, s.MaxSize+1))
+	if err != nil {
+		return "", 0, err
+	}
+	if size > s.MaxSize {
+		return "", 0, ErrTooLarge
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	path := s.Path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
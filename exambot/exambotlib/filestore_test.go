@@ -0,0 +1,76 @@
+package exambotlib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := MakeFileStore(dir, 10)
+	hash, size, err := s.Put(strings.NewReader("final"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "d594c2cc0a53025004791399d80e20852af4c988"; hash != want || size != 5 {
+		t.Fatalf("Put = %q, %d; not %q, 5", hash, size, want)
+	}
+	body, err := ioutil.ReadFile(s.Path(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "final" {
+		t.Errorf("stored %q; not %q", body, "final")
+	}
+
+	hash2, _, err := s.Put(strings.NewReader("final"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash2 != hash {
+		t.Errorf("Put of the same content = %q; not %q", hash2, hash)
+	}
+
+	if _, _, err := s.Put(strings.NewReader("midterm exam")); err != ErrTooLarge {
+		t.Errorf("Put of a large file = %v; not ErrTooLarge", err)
+	}
+	if _, _, err := s.Put(strings.NewReader("0123456789")); err != nil {
+		t.Errorf("Put of a file of MaxSize = %v", err)
+	}
+
+	// Only the two stored files and their directories should be left.
+	var files int
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			t.Errorf("unexpected file %s", e.Name())
+			continue
+		}
+		sub, err := ioutil.ReadDir(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files += len(sub)
+	}
+	if files != 2 {
+		t.Errorf("stored %d files; not 2", files)
+	}
+}
+
+func TestStoredFileAddReferrer(t *testing.T) {
+	var f StoredFile
+	if !f.AddReferrer("a") || f.AddReferrer("a") || !f.AddReferrer("b") {
+		t.Errorf("AddReferrer: %+v", f.Referrers)
+	}
+}
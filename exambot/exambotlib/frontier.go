@@ -0,0 +1,321 @@
+package exambotlib
+
+import (
+	"container/heap"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	frontierBucket = []byte("frontier")
+	seenBucket     = []byte("seen")
+)
+
+// URLScore is a single URL and a score
+type URLScore struct {
+	URL   string
+	Score int `json:",omitempty"`
+	// Retries is how many times fetching the URL has failed.
+	Retries int `json:",omitempty"`
+}
+
+// An URLHeap is a min-heap of strings.
+type URLHeap []*URLScore
+
+func (h URLHeap) Len() int           { return len(h) }
+func (h URLHeap) Less(i, j int) bool { return h[i].Score < h[j].Score }
+func (h URLHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+// Push ...
+func (h *URLHeap) Push(x interface{}) {
+	// Push and Pop use pointer receivers because they modify the slice's length,
+	// not just its contents.
+	*h = append(*h, x.(*URLScore))
+}
+
+// Pop ...
+func (h *URLHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// Frontier is the queue of URLs to crawl and the set of URLs that have been
+// seen. Both are stored in bolt so a crawl can be resumed even if the process
+// is killed. URLs stay in the frontier until they're marked done, so URLs
+// that were being crawled are crawled again when resumed.
+type Frontier struct {
+	DB *bolt.DB
+
+	mu sync.Mutex
+	// queue is the URLs waiting to be crawled, ordered by score.
+	queue URLHeap
+	// queued is every URL in the frontier, including ones being crawled.
+	queued map[string]struct{}
+}
+
+// OpenFrontier loads the frontier stored in the database.
+func OpenFrontier(db *bolt.DB) (*Frontier, error) {
+	f := &Frontier{
+		DB:     db,
+		queued: map[string]struct{}{},
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(seenBucket); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucketIfNotExists(frontierBucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var us URLScore
+			if err := json.Unmarshal(v, &us); err != nil {
+				return err
+			}
+			f.queue = append(f.queue, &us)
+			f.queued[us.URL] = struct{}{}
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	heap.Init(&f.queue)
+	return f, nil
+}
+
+// MarkSeen adds the URLs to the seen set and returns the ones that hadn't been
+// seen before.
+func (f *Frontier) MarkSeen(urls []string) ([]string, error) {
+	var unseen []string
+	if err := f.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(seenBucket)
+		for _, u := range urls {
+			key := []byte(u)
+			if b.Get(key) != nil {
+				continue
+			}
+			if err := b.Put(key, []byte{}); err != nil {
+				return err
+			}
+			unseen = append(unseen, u)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return unseen, nil
+}
+
+// Unseen returns the URLs that haven't been seen without marking them seen.
+func (f *Frontier) Unseen(urls []string) ([]string, error) {
+	var unseen []string
+	if err := f.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(seenBucket)
+		for _, u := range urls {
+			if b.Get([]byte(u)) == nil {
+				unseen = append(unseen, u)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return unseen, nil
+}
+
+// Add adds the URLs that haven't been seen to the frontier and marks them
+// seen in the same transaction, so a crash can't leave a URL seen but never
+// crawled. It returns how many were added.
+func (f *Frontier) Add(urls []*URLScore) (int, error) {
+	return f.push(urls, true)
+}
+
+// Push adds the URLs to the frontier and returns how many weren't already in
+// it. It doesn't check whether they've been seen so it can be used to
+// recrawl and retry URLs.
+func (f *Frontier) Push(urls []*URLScore) (int, error) {
+	return f.push(urls, false)
+}
+
+func (f *Frontier) push(urls []*URLScore, markSeen bool) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var added []*URLScore
+	if err := f.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(frontierBucket)
+		seen := tx.Bucket(seenBucket)
+		batch := map[string]struct{}{}
+		for _, us := range urls {
+			if markSeen {
+				key := []byte(us.URL)
+				if seen.Get(key) != nil {
+					continue
+				}
+				if err := seen.Put(key, []byte{}); err != nil {
+					return err
+				}
+			}
+			if _, ok := f.queued[us.URL]; ok {
+				continue
+			}
+			if _, ok := batch[us.URL]; ok {
+				continue
+			}
+			v, err := json.Marshal(us)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(us.URL), v); err != nil {
+				return err
+			}
+			batch[us.URL] = struct{}{}
+			added = append(added, us)
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	for _, us := range added {
+		heap.Push(&f.queue, us)
+		f.queued[us.URL] = struct{}{}
+	}
+	return len(added), nil
+}
+
+// Retry puts a URL that was popped back in the queue with its retry count
+// updated.
+func (f *Frontier) Retry(us *URLScore) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	v, err := json.Marshal(us)
+	if err != nil {
+		return err
+	}
+	if err := f.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(frontierBucket).Put([]byte(us.URL), v)
+	}); err != nil {
+		return err
+	}
+	f.queued[us.URL] = struct{}{}
+	heap.Push(&f.queue, us)
+	return nil
+}
+
+// Pop removes the best URL that ready accepts from the queue. At most max
+// URLs are skipped over, and if none are accepted Pop returns the shortest
+// wait that ready returned. Popped URLs stay in the frontier until Done is
+// called.
+func (f *Frontier) Pop(max int, ready func(us *URLScore) (time.Duration, bool)) (*URLScore, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var deferred []*URLScore
+	defer func() {
+		for _, us := range deferred {
+			heap.Push(&f.queue, us)
+		}
+	}()
+
+	var minWait time.Duration
+	for len(f.queue) > 0 && len(deferred) < max {
+		us := heap.Pop(&f.queue).(*URLScore)
+		wait, ok := ready(us)
+		if ok {
+			return us, 0
+		}
+		if minWait == 0 || wait < minWait {
+			minWait = wait
+		}
+		deferred = append(deferred, us)
+	}
+	return nil, minWait
+}
+
+// Done removes a popped URL from the frontier.
+func (f *Frontier) Done(url string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(frontierBucket).Delete([]byte(url))
+	}); err != nil {
+		return err
+	}
+	delete(f.queued, url)
+	return nil
+}
+
+// Len returns the number of URLs waiting in the queue.
+func (f *Frontier) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.queue)
+}
+
+// URLs returns the URLs waiting in the queue.
+func (f *Frontier) URLs() []URLScore {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var urls []URLScore
+	for _, us := range f.queue {
+		urls = append(urls, *us)
+	}
+	return urls
+}
+
+// HostCounts returns the number of URLs waiting in the queue by host.
+func (f *Frontier) HostCounts() map[string]int {
+	hosts := map[string]int{}
+	for _, us := range f.URLs() {
+		if u, err := url.Parse(us.URL); err == nil {
+			hosts[u.Host]++
+		}
+	}
+	return hosts
+}
+
+// Prune removes the queued URLs that match and returns how many were removed.
+func (f *Frontier) Prune(match func(us *URLScore) bool) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var keep URLHeap
+	var removed []string
+	for _, us := range f.queue {
+		if match(us) {
+			removed = append(removed, us.URL)
+		} else {
+			keep = append(keep, us)
+		}
+	}
+	if err := f.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(frontierBucket)
+		for _, u := range removed {
+			if err := b.Delete([]byte(u)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	heap.Init(&keep)
+	f.queue = keep
+	for _, u := range removed {
+		delete(f.queued, u)
+	}
+	return len(removed), nil
+}
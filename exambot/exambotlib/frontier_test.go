@@ -0,0 +1,187 @@
+package exambotlib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func openTestDB(t *testing.T, dir string) *bolt.DB {
+	db, err := bolt.Open(filepath.Join(dir, "test.boltdb"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func always(*URLScore) (time.Duration, bool) {
+	return 0, true
+}
+
+func TestFrontier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frontier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := openTestDB(t, dir)
+	f, err := OpenFrontier(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unseen, err := f.MarkSeen([]string{"a", "b", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(unseen, ",") != "a,b" {
+		t.Fatalf("MarkSeen = %v; not [a b]", unseen)
+	}
+	unseen, err = f.MarkSeen([]string{"b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(unseen, ",") != "c" {
+		t.Fatalf("MarkSeen = %v; not [c]", unseen)
+	}
+
+	added, err := f.Push([]*URLScore{{URL: "a", Score: 1}, {URL: "b", Score: -1}, {URL: "c"}, {URL: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 3 {
+		t.Fatalf("Push added %d; not 3", added)
+	}
+
+	us, _ := f.Pop(10, always)
+	if us == nil || us.URL != "b" {
+		t.Fatalf("Pop = %+v; expected the lowest score", us)
+	}
+	if err := f.Done(us.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// c is popped but not done when the crawler is killed.
+	us, wait := f.Pop(10, func(us *URLScore) (time.Duration, bool) {
+		if us.URL == "c" {
+			return 0, true
+		}
+		return time.Second, false
+	})
+	if us == nil || us.URL != "c" || wait != 0 {
+		t.Fatalf("Pop = %+v, %s; expected to skip to c", us, wait)
+	}
+	if _, wait := f.Pop(10, func(*URLScore) (time.Duration, bool) { return time.Minute, false }); wait != time.Minute {
+		t.Fatalf("Pop wait = %s; not 1m", wait)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openTestDB(t, dir)
+	defer db.Close()
+	f, err = OpenFrontier(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Len() != 2 {
+		t.Fatalf("Len = %d; expected a and the unfinished c", f.Len())
+	}
+	if added, err := f.Push([]*URLScore{{URL: "c"}}); err != nil || added != 0 {
+		t.Fatalf("Push = %d, %v; c should already be queued", added, err)
+	}
+
+	removed, err := f.Prune(func(us *URLScore) bool { return us.URL == "c" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Fatalf("Prune removed %d; not 1", removed)
+	}
+	us, _ = f.Pop(10, always)
+	if us == nil || us.URL != "a" {
+		t.Fatalf("Pop = %+v; not a", us)
+	}
+	us.Retries++
+	if err := f.Retry(us); err != nil {
+		t.Fatal(err)
+	}
+	urls := f.URLs()
+	if len(urls) != 1 || urls[0].Retries != 1 {
+		t.Fatalf("URLs = %+v", urls)
+	}
+}
+
+func TestFrontierAdd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frontier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := openTestDB(t, dir)
+	defer db.Close()
+	f, err := OpenFrontier(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unseen, err := f.Unseen([]string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(unseen, ",") != "a,b" {
+		t.Fatalf("Unseen = %v; not [a b]", unseen)
+	}
+	// Unseen doesn't mark URLs seen, only adding them does.
+	if added, err := f.Add([]*URLScore{{URL: "a"}, {URL: "a"}}); err != nil || added != 1 {
+		t.Fatalf("Add = %d, %v; not 1", added, err)
+	}
+	if unseen, err = f.Unseen([]string{"a", "b"}); err != nil || strings.Join(unseen, ",") != "b" {
+		t.Fatalf("Unseen = %v, %v; not [b]", unseen, err)
+	}
+
+	us, _ := f.Pop(10, always)
+	if err := f.Done(us.URL); err != nil {
+		t.Fatal(err)
+	}
+	if added, err := f.Add([]*URLScore{{URL: "a"}, {URL: "b"}}); err != nil || added != 1 {
+		t.Fatalf("Add = %d, %v; a was already seen", added, err)
+	}
+	if urls := f.URLs(); len(urls) != 1 || urls[0].URL != "b" {
+		t.Fatalf("URLs = %+v; not [b]", urls)
+	}
+}
+
+func TestFrontierHostCounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frontier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := openTestDB(t, dir)
+	defer db.Close()
+	f, err := OpenFrontier(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Push([]*URLScore{
+		{URL: "https://a.com/1"},
+		{URL: "https://a.com/2"},
+		{URL: "https://b.com/1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"a.com": 2, "b.com": 1}
+	if out := f.HostCounts(); !reflect.DeepEqual(out, want) {
+		t.Errorf("HostCounts = %+v; not %+v", out, want)
+	}
+}
@@ -0,0 +1,136 @@
+package exambotlib
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff returns how long to wait after the given number of consecutive
+// failures. It doubles from base for every failure up to max, with up to half
+// of it randomized so retries from many workers don't line up.
+func Backoff(failures int, base, max time.Duration) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	d := base
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// HostScheduler limits how often and how many requests at once are made to
+// each host.
+type HostScheduler struct {
+	// Delay is the minimum time between starting requests to a host unless
+	// the host asks for longer with a crawl delay.
+	Delay time.Duration
+	// MaxConcurrent is the most requests to a host that can be in flight.
+	MaxConcurrent int
+	// BaseBackoff and MaxBackoff bound the backoff after failures.
+	BaseBackoff, MaxBackoff time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+	now   func() time.Time
+}
+
+type hostState struct {
+	next       time.Time
+	active     int
+	failures   int
+	crawlDelay time.Duration
+}
+
+// MakeHostScheduler makes a new host scheduler.
+func MakeHostScheduler(delay time.Duration, maxConcurrent int) *HostScheduler {
+	return &HostScheduler{
+		Delay:         delay,
+		MaxConcurrent: maxConcurrent,
+		BaseBackoff:   5 * time.Second,
+		MaxBackoff:    10 * time.Minute,
+		hosts:         map[string]*hostState{},
+		now:           time.Now,
+	}
+}
+
+func (s *HostScheduler) host(host string) *hostState {
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostState{}
+		s.hosts[host] = h
+	}
+	return h
+}
+
+// SetCrawlDelay sets the crawl delay the host asked for in its robots.txt.
+func (s *HostScheduler) SetCrawlDelay(host string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.host(host).crawlDelay = delay
+}
+
+// Acquire reserves a request to the host if one can be made now. Otherwise it
+// returns how long until one might be possible. Every successful Acquire must
+// be followed by a Release.
+func (s *HostScheduler) Acquire(host string) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := s.host(host)
+	now := s.now()
+	if wait := h.next.Sub(now); wait > 0 {
+		return wait, false
+	}
+	if s.MaxConcurrent > 0 && h.active >= s.MaxConcurrent {
+		return s.delay(h), false
+	}
+	h.active++
+	h.next = now.Add(s.delay(h))
+	return 0, true
+}
+
+// Release finishes a request to the host. Failed requests back the host off
+// exponentially, and a successful one resets the backoff.
+func (s *HostScheduler) Release(host string, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := s.host(host)
+	if h.active > 0 {
+		h.active--
+	}
+	if !failed {
+		h.failures = 0
+		return
+	}
+	h.failures++
+	next := s.now().Add(Backoff(h.failures, s.BaseBackoff, s.MaxBackoff))
+	if next.After(h.next) {
+		h.next = next
+	}
+}
+
+func (s *HostScheduler) delay(h *hostState) time.Duration {
+	if h.crawlDelay > s.Delay {
+		return h.crawlDelay
+	}
+	return s.Delay
+}
+
+// Failures returns the number of consecutive failed requests to the host.
+func (s *HostScheduler) Failures(host string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.host(host).failures
+}
@@ -0,0 +1,80 @@
+package exambotlib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	base := time.Second
+	max := time.Minute
+	cases := []struct {
+		failures int
+		min, max time.Duration
+	}{
+		{0, 0, 0},
+		{1, 500 * time.Millisecond, time.Second},
+		{3, 2 * time.Second, 4 * time.Second},
+		{20, 30 * time.Second, time.Minute},
+	}
+
+	for i, c := range cases {
+		for j := 0; j < 100; j++ {
+			out := Backoff(c.failures, base, max)
+			if out < c.min || out > c.max {
+				t.Fatalf("%d. Backoff(%d) = %s; not in [%s, %s]", i, c.failures, out, c.min, c.max)
+			}
+		}
+	}
+}
+
+func TestHostScheduler(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := MakeHostScheduler(time.Second, 2)
+	s.BaseBackoff = time.Minute
+	s.MaxBackoff = time.Minute
+	s.now = func() time.Time { return now }
+
+	if _, ok := s.Acquire("a"); !ok {
+		t.Fatal("first request should be allowed")
+	}
+	if wait, ok := s.Acquire("a"); ok || wait != time.Second {
+		t.Fatalf("Acquire = %s, %t; expected to wait for the delay", wait, ok)
+	}
+	if _, ok := s.Acquire("b"); !ok {
+		t.Fatal("other hosts shouldn't be limited")
+	}
+
+	now = now.Add(time.Second)
+	if _, ok := s.Acquire("a"); !ok {
+		t.Fatal("request after the delay should be allowed")
+	}
+	now = now.Add(time.Second)
+	if _, ok := s.Acquire("a"); ok {
+		t.Fatal("expected the concurrency limit to be hit")
+	}
+	s.Release("a", false)
+	if _, ok := s.Acquire("a"); !ok {
+		t.Fatal("request after a release should be allowed")
+	}
+
+	s.SetCrawlDelay("b", 10*time.Second)
+	s.Release("b", false)
+	now = now.Add(time.Second)
+	if _, ok := s.Acquire("b"); !ok {
+		t.Fatal("request to b should be allowed")
+	}
+	s.Release("b", false)
+	if wait, ok := s.Acquire("b"); ok || wait != 10*time.Second {
+		t.Fatalf("Acquire = %s, %t; expected to wait for the crawl delay", wait, ok)
+	}
+
+	s.Release("a", true)
+	now = now.Add(10 * time.Second)
+	if wait, ok := s.Acquire("a"); ok || wait < 20*time.Second {
+		t.Fatalf("Acquire = %s, %t; expected to back off", wait, ok)
+	}
+	if n := s.Failures("a"); n != 1 {
+		t.Errorf("Failures = %d; not 1", n)
+	}
+}
@@ -0,0 +1,86 @@
+package exambotlib
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Link is a link on a page and the text around it. Exam pages usually say
+// what a file is in the link text, the heading above it or the table row it's
+// in rather than in the URL.
+type Link struct {
+	URL string
+	// Text is the anchor text.
+	Text string `json:",omitempty"`
+	// Heading is the text of the closest heading before the link.
+	Heading string `json:",omitempty"`
+	// Row is the text of the table row or list item the link is in.
+	Row string `json:",omitempty"`
+}
+
+// HasContext returns whether any text was found for the link.
+func (l Link) HasContext() bool {
+	return len(l.Text) > 0 || len(l.Heading) > 0 || len(l.Row) > 0
+}
+
+const headings = "h1, h2, h3, h4, h5, h6"
+
+// LinkContext returns the text around the anchor. The URL isn't set.
+func LinkContext(a *goquery.Selection) Link {
+	l := Link{
+		Text:    CleanText(a.Text()),
+		Heading: precedingHeading(a),
+	}
+	if text := rowText(a); text != l.Text {
+		l.Row = text
+	}
+	return l
+}
+
+// rowText returns the text of the table row or list item s is in. Cells are
+// separated by spaces.
+func rowText(s *goquery.Selection) string {
+	row := s.Closest("tr")
+	if row.Length() == 0 {
+		return CleanText(s.Closest("li").Text())
+	}
+	var cells []string
+	row.Children().Each(func(_ int, cell *goquery.Selection) {
+		if text := CleanText(cell.Text()); len(text) > 0 {
+			cells = append(cells, text)
+		}
+	})
+	return strings.Join(cells, " ")
+}
+
+// precedingHeading returns the text of the last heading before s in the
+// document.
+func precedingHeading(s *goquery.Selection) string {
+	for ; s.Length() > 0 && !s.Is("body"); s = s.Parent() {
+		prev := s.PrevAll()
+		for i := 0; i < prev.Length(); i++ {
+			sibling := prev.Eq(i)
+			if sibling.Is(headings) {
+				return CleanText(sibling.Text())
+			}
+			if nested := sibling.Find(headings); nested.Length() > 0 {
+				return CleanText(nested.Last().Text())
+			}
+		}
+	}
+	return ""
+}
+
+// LinkContexts returns the links with context by URL. If a URL is linked
+// more than once the first link with anchor text is used.
+func LinkContexts(links []Link) map[string]Link {
+	m := map[string]Link{}
+	for _, l := range links {
+		if prev, ok := m[l.URL]; ok && (len(prev.Text) > 0 || len(l.Text) == 0) {
+			continue
+		}
+		m[l.URL] = l
+	}
+	return m
+}
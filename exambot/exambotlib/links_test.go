@@ -0,0 +1,64 @@
+package exambotlib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const linksTestPage = `<html><body>
+<a href="intro.pdf">Intro</a>
+<div>
+	<h2>Midterm 2</h2>
+	<p>Past exams:</p>
+	<table>
+		<tr><td>2015W1</td><td><a href="mt2-2015.pdf">solutions</a></td></tr>
+	</table>
+	<div><h3>Finals</h3></div>
+	<ul>
+		<li><a href="final.pdf">Final</a> (2016 W2)</li>
+		<li><a href="only.pdf">Only link</a></li>
+	</ul>
+</div>
+</body></html>`
+
+func TestLinkContext(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(linksTestPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []Link
+	doc.Find("a").Each(func(_ int, s *goquery.Selection) {
+		l := LinkContext(s)
+		l.URL = s.AttrOr("href", "")
+		out = append(out, l)
+	})
+	want := []Link{
+		{URL: "intro.pdf", Text: "Intro"},
+		{URL: "mt2-2015.pdf", Text: "solutions", Heading: "Midterm 2", Row: "2015W1 solutions"},
+		{URL: "final.pdf", Text: "Final", Heading: "Finals", Row: "Final (2016 W2)"},
+		{URL: "only.pdf", Text: "Only link", Heading: "Finals"},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("LinkContext = %+v; not %+v", out, want)
+	}
+}
+
+func TestLinkContexts(t *testing.T) {
+	links := []Link{
+		{URL: "a.pdf", Heading: "Finals"},
+		{URL: "a.pdf", Text: "2016 Final"},
+		{URL: "a.pdf", Text: "Final"},
+		{URL: "b.pdf", Text: "Midterm"},
+	}
+	out := LinkContexts(links)
+	want := map[string]Link{
+		"a.pdf": {URL: "a.pdf", Text: "2016 Final"},
+		"b.pdf": {URL: "b.pdf", Text: "Midterm"},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("LinkContexts(%+v) = %+v; not %+v", links, out, want)
+	}
+}
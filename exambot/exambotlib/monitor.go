@@ -0,0 +1,201 @@
+package exambotlib
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// throughputInterval is how long each throughput sample covers.
+	throughputInterval = time.Minute
+	// throughputSamples is how many samples of throughput are kept.
+	throughputSamples = 60
+)
+
+// Monitor collects statistics about a crawl. It's safe to use from multiple
+// workers.
+type Monitor struct {
+	mu      sync.Mutex
+	started time.Time
+	totals  CrawlTotals
+	codes   map[int]int64
+	hosts   map[string]*HostStats
+	samples []ThroughputSample
+	now     func() time.Time
+}
+
+// CrawlTotals are the counts of what happened during a crawl.
+type CrawlTotals struct {
+	Fetched int64
+	Failed  int64
+	Retried int64
+	// Blocked is URLs disallowed by robots.txt.
+	Blocked int64
+	// Deferred is how many times a URL was skipped since its host was busy.
+	Deferred int64
+	// Candidates is new links to PDFs found on crawled pages.
+	Candidates int64
+}
+
+// HostStats are the statistics of a single host.
+type HostStats struct {
+	Fetched int64
+	Failed  int64
+	// Queued is the number of URLs in the frontier for the host.
+	Queued int `json:",omitempty"`
+}
+
+// ThroughputSample is how many pages were fetched during an interval
+// starting at Time.
+type ThroughputSample struct {
+	Time    time.Time
+	Fetched int64
+	Failed  int64
+}
+
+// CrawlStats is a snapshot of the statistics of a crawl.
+type CrawlStats struct {
+	Started time.Time
+	Uptime  time.Duration
+	CrawlTotals
+	// ErrorRate is the fraction of requests that failed.
+	ErrorRate float64
+	// PagesPerMinute is the average throughput since the crawl started.
+	PagesPerMinute float64
+	StatusCodes    map[int]int64
+	Hosts          map[string]*HostStats
+	Throughput     []ThroughputSample
+}
+
+// MakeMonitor makes a new monitor.
+func MakeMonitor() *Monitor {
+	m := &Monitor{
+		codes: map[int]int64{},
+		hosts: map[string]*HostStats{},
+		now:   time.Now,
+	}
+	m.started = m.now()
+	return m
+}
+
+func (m *Monitor) host(host string) *HostStats {
+	h, ok := m.hosts[host]
+	if !ok {
+		h = &HostStats{}
+		m.hosts[host] = h
+	}
+	return h
+}
+
+// sample returns the current throughput sample, starting a new one if the
+// interval is over.
+func (m *Monitor) sample() *ThroughputSample {
+	start := m.now().Truncate(throughputInterval)
+	if n := len(m.samples); n == 0 || !m.samples[n-1].Time.Equal(start) {
+		m.samples = append(m.samples, ThroughputSample{Time: start})
+		if len(m.samples) > throughputSamples {
+			m.samples = m.samples[len(m.samples)-throughputSamples:]
+		}
+	}
+	return &m.samples[len(m.samples)-1]
+}
+
+// Fetched records a response from the host. Failed responses are retried.
+func (m *Monitor) Fetched(host string, statusCode int, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.codes[statusCode]++
+	if failed {
+		m.recordFailure(host)
+		return
+	}
+	m.totals.Fetched++
+	m.host(host).Fetched++
+	m.sample().Fetched++
+}
+
+// Failed records a request to the host that got no response.
+func (m *Monitor) Failed(host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.recordFailure(host)
+}
+
+func (m *Monitor) recordFailure(host string) {
+	m.totals.Failed++
+	m.host(host).Failed++
+	m.sample().Failed++
+}
+
+// Retried records that a URL was queued again.
+func (m *Monitor) Retried() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totals.Retried++
+}
+
+// Blocked records that a URL was disallowed by robots.txt.
+func (m *Monitor) Blocked() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totals.Blocked++
+}
+
+// Deferred records that a URL was skipped since its host was busy.
+func (m *Monitor) Deferred() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totals.Deferred++
+}
+
+// Candidates records new links to PDFs.
+func (m *Monitor) Candidates(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totals.Candidates += int64(n)
+}
+
+// Stats returns a snapshot of the statistics. queued is the number of URLs in
+// the frontier by host.
+func (m *Monitor) Stats(queued map[string]int) CrawlStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	stats := CrawlStats{
+		Started:     m.started,
+		Uptime:      now.Sub(m.started),
+		CrawlTotals: m.totals,
+		StatusCodes: map[int]int64{},
+		Hosts:       map[string]*HostStats{},
+		Throughput:  append([]ThroughputSample(nil), m.samples...),
+	}
+	if requests := m.totals.Fetched + m.totals.Failed; requests > 0 {
+		stats.ErrorRate = float64(m.totals.Failed) / float64(requests)
+	}
+	if minutes := stats.Uptime.Minutes(); minutes > 0 {
+		stats.PagesPerMinute = float64(m.totals.Fetched) / minutes
+	}
+	for code, n := range m.codes {
+		stats.StatusCodes[code] = n
+	}
+	for host, h := range m.hosts {
+		copy := *h
+		stats.Hosts[host] = &copy
+	}
+	for host, n := range queued {
+		h, ok := stats.Hosts[host]
+		if !ok {
+			h = &HostStats{}
+			stats.Hosts[host] = h
+		}
+		h.Queued = n
+	}
+	return stats
+}
@@ -0,0 +1,80 @@
+package exambotlib
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := MakeMonitor()
+	m.now = func() time.Time { return now }
+	m.started = now
+
+	m.Fetched("a", 200, false)
+	m.Fetched("a", 404, false)
+	m.Fetched("b", 503, true)
+	now = now.Add(90 * time.Second)
+	m.Failed("b")
+	m.Fetched("a", 200, false)
+	m.Retried()
+	m.Blocked()
+	m.Deferred()
+	m.Candidates(3)
+
+	stats := m.Stats(map[string]int{"a": 2, "c": 1})
+	wantTotals := CrawlTotals{Fetched: 3, Failed: 2, Retried: 1, Blocked: 1, Deferred: 1, Candidates: 3}
+	if stats.CrawlTotals != wantTotals {
+		t.Errorf("totals = %+v; not %+v", stats.CrawlTotals, wantTotals)
+	}
+	if stats.ErrorRate != 0.4 {
+		t.Errorf("ErrorRate = %f; not 0.4", stats.ErrorRate)
+	}
+	if stats.PagesPerMinute != 2 {
+		t.Errorf("PagesPerMinute = %f; not 2", stats.PagesPerMinute)
+	}
+	wantCodes := map[int]int64{200: 2, 404: 1, 503: 1}
+	if !reflect.DeepEqual(stats.StatusCodes, wantCodes) {
+		t.Errorf("StatusCodes = %+v; not %+v", stats.StatusCodes, wantCodes)
+	}
+	wantHosts := map[string]*HostStats{
+		"a": {Fetched: 3, Queued: 2},
+		"b": {Failed: 2},
+		"c": {Queued: 1},
+	}
+	if !reflect.DeepEqual(stats.Hosts, wantHosts) {
+		t.Errorf("Hosts = %+v; not %+v", stats.Hosts, wantHosts)
+	}
+	wantThroughput := []ThroughputSample{
+		{Time: time.Unix(0, 0), Fetched: 2, Failed: 1},
+		{Time: time.Unix(60, 0), Fetched: 1, Failed: 1},
+	}
+	if !reflect.DeepEqual(stats.Throughput, wantThroughput) {
+		t.Errorf("Throughput = %+v; not %+v", stats.Throughput, wantThroughput)
+	}
+
+	// Snapshots shouldn't change when more is recorded.
+	m.Fetched("a", 200, false)
+	if stats.Hosts["a"].Fetched != 3 {
+		t.Errorf("snapshot changed: %+v", stats.Hosts["a"])
+	}
+}
+
+func TestMonitorThroughputSamples(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := MakeMonitor()
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < throughputSamples+10; i++ {
+		m.Fetched("a", 200, false)
+		now = now.Add(throughputInterval)
+	}
+	samples := m.Stats(nil).Throughput
+	if len(samples) != throughputSamples {
+		t.Fatalf("len(Throughput) = %d; not %d", len(samples), throughputSamples)
+	}
+	if want := time.Unix(0, 0).Add(10 * throughputInterval); !samples[0].Time.Equal(want) {
+		t.Errorf("oldest sample = %s; not %s", samples[0].Time, want)
+	}
+}
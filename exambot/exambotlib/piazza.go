@@ -0,0 +1,64 @@
+package exambotlib
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+// PiazzaFetcher fetches piazza:// URLs with a Piazza account. It logs in on the
+// first fetch and again on later fetches if logging in failed.
+type PiazzaFetcher struct {
+	user, password string
+
+	mu      sync.Mutex
+	wrapper *piazza.HTMLWrapper
+}
+
+// MakePiazzaFetcher makes a new fetcher for the Piazza account.
+func MakePiazzaFetcher(user, password string) *PiazzaFetcher {
+	return &PiazzaFetcher{user: user, password: password}
+}
+
+func (f *PiazzaFetcher) login() (*piazza.HTMLWrapper, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.wrapper == nil {
+		c, err := piazza.MakeClient(f.user, f.password)
+		if err != nil {
+			return nil, err
+		}
+		f.wrapper = c.HTMLWrapper()
+	}
+	return f.wrapper, nil
+}
+
+// Fetch returns the URL rendered as HTML.
+func (f *PiazzaFetcher) Fetch(u *url.URL) (*http.Response, error) {
+	wrapper, err := f.login()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("PIAZZA %s", u)
+	body, err := wrapper.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	return makeResponse(u, http.StatusOK, "text/html", body), nil
+}
+
+// AlwaysVisit returns whether the URL is the root of Piazza or of a class,
+// which list posts that are added all the time.
+func (f *PiazzaFetcher) AlwaysVisit(u *url.URL) bool {
+	return len(u.Path) <= 1
+}
+
+// Opaque returns true since relative links on Piazza pages point to the
+// Piazza website.
+func (f *PiazzaFetcher) Opaque() bool {
+	return true
+}
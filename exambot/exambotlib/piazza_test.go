@@ -0,0 +1,26 @@
+package exambotlib
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPiazzaFetcherAlwaysVisit(t *testing.T) {
+	f := MakePiazzaFetcher("bot@example.com", "password")
+	for uri, want := range map[string]bool{
+		"piazza://":           true,
+		"piazza://abc123/":    true,
+		"piazza://abc123/456": false,
+	} {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.AlwaysVisit(u); got != want {
+			t.Errorf("AlwaysVisit(%q) = %t; not %t", uri, got, want)
+		}
+	}
+	if !f.Opaque() {
+		t.Error("expected Piazza URLs to be opaque")
+	}
+}
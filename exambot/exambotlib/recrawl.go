@@ -0,0 +1,45 @@
+package exambotlib
+
+import "time"
+
+const (
+	// MinRecrawlInterval and MaxRecrawlInterval bound how often a page is
+	// recrawled.
+	MinRecrawlInterval = 24 * time.Hour
+	MaxRecrawlInterval = 120 * 24 * time.Hour
+	// DefaultRecrawlInterval is how long until a new page is recrawled.
+	DefaultRecrawlInterval = 14 * 24 * time.Hour
+)
+
+// NextRecrawlInterval returns how long to wait before recrawling a page again.
+// Pages that changed since the last crawl are recrawled twice as often and
+// pages that didn't half as often.
+func NextRecrawlInterval(prev time.Duration, changed bool) time.Duration {
+	if prev <= 0 {
+		prev = DefaultRecrawlInterval
+	}
+	next := prev * 2
+	if changed {
+		next = prev / 2
+	}
+	if next < MinRecrawlInterval {
+		return MinRecrawlInterval
+	}
+	if next > MaxRecrawlInterval {
+		return MaxRecrawlInterval
+	}
+	return next
+}
+
+// TermStart returns when the UBC term that t is during started. Terms start
+// in January, May and September.
+func TermStart(t time.Time) time.Time {
+	month := time.September
+	switch {
+	case t.Month() < time.May:
+		month = time.January
+	case t.Month() < time.September:
+		month = time.May
+	}
+	return time.Date(t.Year(), month, 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,45 @@
+package exambotlib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRecrawlInterval(t *testing.T) {
+	day := 24 * time.Hour
+	cases := []struct {
+		prev    time.Duration
+		changed bool
+		want    time.Duration
+	}{
+		{0, false, 2 * DefaultRecrawlInterval},
+		{0, true, DefaultRecrawlInterval / 2},
+		{4 * day, true, 2 * day},
+		{day, true, MinRecrawlInterval},
+		{100 * day, false, MaxRecrawlInterval},
+	}
+
+	for i, c := range cases {
+		out := NextRecrawlInterval(c.prev, c.changed)
+		if out != c.want {
+			t.Errorf("%d. NextRecrawlInterval(%s, %t) = %s; not %s", i, c.prev, c.changed, out, c.want)
+		}
+	}
+}
+
+func TestTermStart(t *testing.T) {
+	cases := []struct {
+		t, want time.Time
+	}{
+		{time.Date(2017, time.February, 3, 10, 0, 0, 0, time.UTC), time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2017, time.May, 1, 0, 0, 0, 0, time.UTC), time.Date(2017, time.May, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2017, time.August, 31, 0, 0, 0, 0, time.UTC), time.Date(2017, time.May, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2017, time.December, 25, 0, 0, 0, 0, time.UTC), time.Date(2017, time.September, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for i, c := range cases {
+		if out := TermStart(c.t); !out.Equal(c.want) {
+			t.Errorf("%d. TermStart(%s) = %s; not %s", i, c.t, out, c.want)
+		}
+	}
+}
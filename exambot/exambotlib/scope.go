@@ -0,0 +1,163 @@
+package exambotlib
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/ubccsss/exams/config"
+)
+
+// Scope decides which URLs are crawled and in what order for a crawl
+// profile.
+type Scope struct {
+	Profile config.CrawlProfile
+	// Schemes are URL schemes that are always in scope since they're fetched
+	// by a Fetcher, e.g. piazza://.
+	Schemes []string
+
+	hosts     map[string]scopeHost
+	blacklist []*regexp.Regexp
+	// otherDepartments matches courses of other departments if they're
+	// excluded.
+	otherDepartments *regexp.Regexp
+	scores           []scopeScore
+}
+
+type scopeHost struct {
+	whitelist []*regexp.Regexp
+	blacklist []*regexp.Regexp
+}
+
+type scopeScore struct {
+	score int
+	re    *regexp.Regexp
+}
+
+func compileAll(patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// MakeScope compiles the patterns of the profile.
+func MakeScope(p config.CrawlProfile) (*Scope, error) {
+	s := &Scope{
+		Profile: p,
+		hosts:   map[string]scopeHost{},
+	}
+	var err error
+	if s.blacklist, err = compileAll(p.Blacklist); err != nil {
+		return nil, errors.Wrapf(err, "crawl profile %q blacklist", p.Name)
+	}
+	if p.ExcludeOtherDepartments {
+		s.otherDepartments = regexp.MustCompile(p.OtherDepartmentsPattern())
+	}
+	for _, h := range p.Hosts {
+		var sh scopeHost
+		if sh.whitelist, err = compileAll(h.Whitelist); err != nil {
+			return nil, errors.Wrapf(err, "crawl profile %q host %q whitelist", p.Name, h.Host)
+		}
+		if sh.blacklist, err = compileAll(h.Blacklist); err != nil {
+			return nil, errors.Wrapf(err, "crawl profile %q host %q blacklist", p.Name, h.Host)
+		}
+		s.hosts[h.Host] = sh
+	}
+	for _, score := range p.Scores {
+		res, err := compileAll(score.Patterns)
+		if err != nil {
+			return nil, errors.Wrapf(err, "crawl profile %q scores", p.Name)
+		}
+		for _, re := range res {
+			s.scores = append(s.scores, scopeScore{score: score.Score, re: re})
+		}
+	}
+	return s, nil
+}
+
+func firstMatch(res []*regexp.Regexp, s string) *regexp.Regexp {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return re
+		}
+	}
+	return nil
+}
+
+// Check returns why the URL is out of scope or "" if it's in scope.
+func (s *Scope) Check(uri string) (string, error) {
+	lower := strings.ToLower(uri)
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	for _, scheme := range s.Schemes {
+		if u.Scheme == scheme {
+			return "", nil
+		}
+	}
+
+	h, ok := s.hosts[u.Host]
+	if !ok {
+		return fmt.Sprintf("host %q isn't in crawl profile %q", u.Host, s.Profile.Name), nil
+	}
+	if len(h.whitelist) > 0 && firstMatch(h.whitelist, lower) == nil {
+		return fmt.Sprintf("doesn't match any of the whitelist patterns of %s", u.Host), nil
+	}
+
+	// Skip root indexes. "/", ""
+	if len(u.Path) <= 1 {
+		return "root indexes aren't crawled", nil
+	}
+
+	if !ValidSuffix(u.Path, s.Profile.PageSuffixes) {
+		return fmt.Sprintf("path %q isn't a page, it must have no extension or end with one of %q", u.Path, s.Profile.PageSuffixes), nil
+	}
+	if !ValidSuffix(u.RawQuery, s.Profile.PageSuffixes) {
+		return fmt.Sprintf("query %q isn't a page, it must have no extension or end with one of %q", u.RawQuery, s.Profile.PageSuffixes), nil
+	}
+
+	if re := firstMatch(h.blacklist, lower); re != nil {
+		return fmt.Sprintf("matches the blacklist pattern %q of %s", re, u.Host), nil
+	}
+	if re := firstMatch(s.blacklist, lower); re != nil {
+		return fmt.Sprintf("matches the blacklist pattern %q", re), nil
+	}
+	if s.otherDepartments != nil {
+		if m := s.otherDepartments.FindStringSubmatch(lower); m != nil {
+			return fmt.Sprintf("mentions a course of another department (%s)", m[1]), nil
+		}
+	}
+	return "", nil
+}
+
+// Valid returns whether the URL is in scope.
+func (s *Scope) Valid(uri string) (bool, error) {
+	reason, err := s.Check(uri)
+	if err != nil {
+		return false, err
+	}
+	return len(reason) == 0, nil
+}
+
+// Score returns the priority of the URL. URLs with lower scores are crawled
+// first.
+func (s *Scope) Score(uri string) int {
+	path := strings.ToLower(uri)
+	var score int
+	for _, sc := range s.scores {
+		if sc.re.MatchString(path) {
+			score += sc.score
+		}
+	}
+	return score
+}
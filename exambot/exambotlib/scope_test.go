@@ -0,0 +1,64 @@
+package exambotlib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ubccsss/exams/config"
+)
+
+func TestScope(t *testing.T) {
+	s, err := MakeScope(*config.Default().CrawlProfile("cs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Schemes = []string{"piazza"}
+
+	cases := []struct {
+		uri    string
+		reason string
+	}{
+		{"https://www.cs.ubc.ca/~a/cpsc110/", ""},
+		{"https://www.cs.ubc.ca/~a/cpsc110/notes.php", ""},
+		{"piazza://class/abc", ""},
+		{"https://example.com/cpsc110/", "isn't in crawl profile"},
+		{"https://sites.google.com/site/ubcphys101/", "whitelist"},
+		{"https://sites.google.com/site/ubccpsc110/", ""},
+		{"https://www.cs.ubc.ca/", "root indexes"},
+		{"https://www.cs.ubc.ca/~a/final.pdf", "isn't a page"},
+		{"https://www.cs.ubc.ca/bookings/room/", "blacklist pattern"},
+		{"https://www.cs.ubc.ca/~a/?replytocom=1", "blacklist pattern"},
+		{"https://www.cs.ubc.ca/~a/math200/", "another department (math)"},
+	}
+
+	for i, c := range cases {
+		reason, err := s.Check(c.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c.reason) == 0 && len(reason) > 0 || !strings.Contains(reason, c.reason) {
+			t.Errorf("%d. Check(%q) = %q; expected %q", i, c.uri, reason, c.reason)
+		}
+	}
+}
+
+func TestScopeScore(t *testing.T) {
+	s, err := MakeScope(*config.Default().CrawlProfile("cs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a, b := s.Score("https://www.cs.ubc.ca/~a/cpsc110/2016/final/"), s.Score("https://www.cs.ubc.ca/~a/slides/"); a >= b {
+		t.Errorf("expected exams to be crawled before slides; scores %d and %d", a, b)
+	}
+}
+
+func TestMakeScopeInvalid(t *testing.T) {
+	p := config.CrawlProfile{
+		Name:  "bad",
+		Hosts: []config.CrawlHost{{Host: "example.com", Blacklist: []string{"("}}},
+	}
+	if _, err := MakeScope(p); err == nil || !strings.Contains(err.Error(), "example.com") {
+		t.Errorf("MakeScope = %v; expected an error about the host", err)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/ubccsss/exams/config"
+	"github.com/ubccsss/exams/exambot/exambotlib"
+)
+
+// makeFetchers makes the fetchers for the configured sources. Piazza is only
+// fetched if there's an account for it.
+func makeFetchers(sources config.Sources) (*exambotlib.Fetchers, error) {
+	fetchers := exambotlib.MakeFetchers()
+	if len(sources.Piazza.User) > 0 {
+		f := exambotlib.MakePiazzaFetcher(sources.Piazza.User, sources.Piazza.Password)
+		if err := fetchers.AddScheme(piazza.PiazzaScheme, f); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range sources.Mirrors {
+		var err error
+		if len(m.Scheme) > 0 {
+			err = fetchers.AddScheme(m.Scheme, exambotlib.MakeDirFetcher(m.Dir, true))
+		} else {
+			err = fetchers.AddHost(m.Host, exambotlib.MakeDirFetcher(m.Dir, false))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fetchers, nil
+}
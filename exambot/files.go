@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/ubccsss/exams/config"
+	"github.com/ubccsss/exams/exambot/exambotlib"
+)
+
+// examRegexps compiles the patterns that URLs of possible exams match.
+func examRegexps(cfg *config.Config) []*regexp.Regexp {
+	var regexps []*regexp.Regexp
+	for _, pattern := range cfg.ExamFirstPass {
+		regexps = append(regexps, regexp.MustCompile(pattern))
+	}
+	return regexps
+}
+
+// isCandidate returns whether the URL is a PDF that might be an exam.
+func (s *Spider) isCandidate(uri string) bool {
+	lower := strings.ToLower(uri)
+	if !config.PDFRegexp.MatchString(lower) {
+		return false
+	}
+	for _, r := range s.Candidates {
+		if r.MatchString(lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateFiles calls update with the record of each file, creating the ones
+// that don't exist, and saves the records that update returns true for.
+func (s *Spider) updateFiles(urls []string, update func(f *exambotlib.StoredFile) bool) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(filesBucket)
+		if err != nil {
+			return err
+		}
+		for _, uri := range urls {
+			f := exambotlib.StoredFile{URL: uri}
+			if v := b.Get([]byte(uri)); v != nil {
+				if err := json.Unmarshal(v, &f); err != nil {
+					return err
+				}
+			}
+			if !update(&f) {
+				continue
+			}
+			v, err := json.Marshal(f)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(uri), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addReferrers records that the page links to the exam candidates in links.
+func (s *Spider) addReferrers(page string, links []string) error {
+	var candidates []string
+	for _, link := range links {
+		if s.isCandidate(link) {
+			candidates = append(candidates, link)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return s.updateFiles(candidates, func(f *exambotlib.StoredFile) bool {
+		return f.AddReferrer(page)
+	})
+}
+
+// crawlFile fetches the exam candidate and stores it. It returns whether
+// fetching it failed and should be retried.
+func (s *Spider) crawlFile(uri, host string) bool {
+	resp, err := s.get(uri, nil)
+	if err != nil {
+		s.Hosts.Release(host, true)
+		s.Monitor.Failed(host)
+		log.Printf("WORKER err: %s", err)
+		return true
+	}
+	defer resp.Body.Close()
+
+	failed := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	s.Hosts.Release(host, failed)
+	s.Monitor.Fetched(host, resp.StatusCode, failed)
+	if failed {
+		return true
+	}
+
+	stored := exambotlib.StoredFile{
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Fetched:     time.Now(),
+	}
+	if resp.StatusCode == http.StatusOK {
+		stored.Hash, stored.Size, err = s.Files.Put(resp.Body)
+		if err != nil {
+			log.Printf("WORKER err: storing %s: %s", uri, err)
+		}
+	}
+	if err := s.updateFiles([]string{uri}, func(f *exambotlib.StoredFile) bool {
+		// Keep the last copy if the file is gone now.
+		if len(stored.Hash) > 0 || len(f.Hash) == 0 {
+			f.Hash = stored.Hash
+			f.Size = stored.Size
+			f.ContentType = stored.ContentType
+		}
+		f.StatusCode = stored.StatusCode
+		f.Fetched = stored.Fetched
+		return true
+	}); err != nil {
+		log.Printf("WORKER err: %s", err)
+	}
+	return false
+}
+
+// commandFiles prints the stored exam candidates.
+func commandFiles(db *bolt.DB) error {
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(filesBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var f exambotlib.StoredFile
+			if err := json.Unmarshal(v, &f); err != nil {
+				return err
+			}
+			hash := f.Hash
+			if len(hash) == 0 {
+				hash = "-"
+			}
+			fmt.Printf("%s %d %8d %s %s (%d referrers)\n", hash, f.StatusCode, f.Size, f.ContentType, f.URL, len(f.Referrers))
+			return nil
+		})
+	})
+}
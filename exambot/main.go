@@ -1,7 +1,6 @@
 package main
 
 import (
-	"container/heap"
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
@@ -18,6 +17,7 @@ import (
 	"regexp"
 	"runtime"
 	"runtime/pprof"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -25,88 +25,75 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/boltdb/bolt"
 	archive "github.com/d4l3k/go-internetarchive"
-	piazza "github.com/d4l3k/piazza-api"
 	"github.com/temoto/robotstxt"
 	"github.com/ubccsss/exams/config"
 	"github.com/ubccsss/exams/exambot/exambotlib"
+	"github.com/ubccsss/exams/examdb"
 	"github.com/ubccsss/exams/workers"
-	"github.com/willf/bloom"
 )
 
 const (
 	userAgent  = "UBC CSSS Exam Bot vpc@ubccsss.org"
 	boltDBPath = "exambot.boltdb"
 
-	// bloom filter configuration
-	maxNumberOfPages  = 1000000
-	falsePositiveRate = 0.00001
+	// legacyStatePath is where the queue used to be saved on exit. It's
+	// imported into the frontier if it exists.
+	legacyStatePath = "state.json"
+	// fileStoreDir is where fetched exam candidates are stored.
+	fileStoreDir = "files"
 
-	// shutdownTime is the number of seconds to shutdown after if there is no work
-	// to be done.
+	// shutdownTime is the number of seconds to drain the crawler after if
+	// there is no work to be done.
 	shutdownTime = 240
+
+	// crawlDelay is the minimum time between requests to a host if its
+	// robots.txt doesn't ask for longer.
+	crawlDelay = 2 * time.Second
+	// maxRequestsPerHost is the most requests to a host at once.
+	maxRequestsPerHost = 2
+	// maxRetries is how many times a URL is retried after errors.
+	maxRetries = 5
+	// maxDeferred is how many queued URLs a worker skips over looking for a
+	// host that can be crawled now.
+	maxDeferred = 100
+	// robotsRetry is how long to wait before fetching a robots.txt again that
+	// couldn't be fetched.
+	robotsRetry = 10 * time.Minute
 )
 
 var (
 	pageBucket            = []byte("pages")
 	pageHashBucket        = []byte("pagehash")
 	assortedBucket        = []byte("assorted")
+	filesBucket           = []byte("files")
 	bloomFilterSeenKey    = []byte("bloom:seen")
 	bloomFilterVisitedKey = []byte("bloom:visited")
-	seedURLs              = []string{
-		"https://www.cs.ubc.ca/~schmidtm/Courses/340-F16/",
-		"http://www.cs.ubc.ca/~pcarter/",
-		"https://sites.google.com/site/ubccpsc110/",
-		"https://www.cs.ubc.ca/our-department/people",
-		"https://ubccpsc.github.io",
-		"piazza://",
-	}
-	archiveSearchPrefixes = []string{
-		"https://www.ugrad.cs.ubc.ca/~",
-		"https://www.cs.ubc.ca/~",
-		"https://blogs.ubc.ca/cpsc",
-		"https://www.cs.ubc.ca/people/",
-	}
-	validPostfix = []string{"/", ".html", ".htm", ".cgi", ".php"}
-	blacklist    = []string{
-		".*//www.cs.ubc.ca/~davet/music/.*",
-		".*\\?replytocom=.*",
-		".*//www.cs.ubc.ca/bookings/.*",
-		".*//www.cs.ubc.ca/news-events/calendar/.*",
-		".*//www.cs.ubc.ca/print/.*",
-		".*/bugzilla/.*",
-		".*people/people/people/people.*",
-		".*//sites.google.com/.*/system/errors/NodeNotFound.*",
-		".*(aanb|acam|adhe|afst|agec|anae|anat|ansc|anth|apbi|appp|apsc|arbc|arc|arch|arcl|arst|arth|arts|asia|asic|asla|astr|astu|atsc|audi|ba|baac|babs|baen|bafi|bahc|bahr|baim|bait|bala|bama|bams|bapa|basc|basd|basm|batl|batm|baul|bioc|biof|biol|biot|bmeg|bota|brdg|busi|caps|ccfi|ccst|cdst|ceen|cell|cens|chbe|chem|chil|chin|cics|civl|clch|clst|cnps|cnrs|cnto|coec|cogs|cohr|comm|cons|cpen|crwr|csis|cspw|dani|dent|derm|dhyg|dmed|dpas|dsci|eced|econ|edcp|edst|educ|eece|elec|eli|emba|emer|ends|engl|enph|enpp|envr|eosc|epse|etec|exch|exgr|fact|febc|fhis|fipr|fish|fist|fmed|fmpr|fmst|fnel|fnh|fnis|food|fopr|fre|fren|frsi|frst|gbpr|gem|gene|geob|geog|germ|gpp|grek|grs|grsj|gsat|hebr|heso|hgse|hinu|hist|hpb|hunu|iar|iest|igen|inde|indo|inds|info|isci|ital|itst|iwme|japn|jrnl|kin|korn|lais|larc|laso|last|latn|law|lfs|libe|libr|ling|lled|math|mdvl|mech|medd|medg|medi|mgmt|micb|midw|mine|mrne|mtrl|musc|name|nest|neur|nrsc|nurs|obms|obst|ohs|onco|opth|ornt|orpa|paed|path|pcth|pers|phar|phil|phrm|phth|phyl|phys|plan|plnt|poli|pols|port|prin|psyc|psyt|punj|radi|relg|res|rgla|rhsc|rmst|rsot|russ|sans|scan|scie|seal|slav|soal|soci|soil|sowk|span|spha|spph|stat|sts|surg|swed|test|thtr|tibt|trsc|udes|ufor|ukrn|uro|urst|ursy|vant|vgrd|visa|vrhc|vurs|wood|wrds|writ|zool)\\d{3}.*",
-	}
-	validHosts = map[string]host{
-		"www.cs.ubc.ca":       host{},
-		"www.ugrad.cs.ubc.ca": host{},
-		"blogs.ubc.ca":        host{},
-		"sites.google.com": host{
-			whitelist: []string{
-				".*(cs|cpsc)\\d{3}.*",
-			},
-		},
-		"ubccpsc.github.io": host{},
-		"github.com": host{
-			whitelist: []string{
-				"^https://github.com/ubccpsc.*$",
-			},
-		},
-	}
-	scoreRegexes = map[int][]string{
-		-1: []string{"final", "exam", "midterm", "sample", "mt", "(cs|cpsc)\\d{3}", "(20|19)\\d{2}"},
-		1:  []string{"report", "presentation", "thesis", "slide", "print"},
-	}
 )
 
-type host struct {
-	blacklist []string
-	whitelist []string
+// robotsEntry is a robots.txt that's being fetched or was fetched. ready is
+// closed once it's fetched.
+type robotsEntry struct {
+	ready chan struct{}
+	data  *robotstxt.RobotsData
+	// expires is when to fetch it again if it couldn't be fetched.
+	expires time.Time
+}
+
+// done returns whether fetching the robots.txt finished.
+func (e *robotsEntry) done() bool {
+	select {
+	case <-e.ready:
+		return true
+	default:
+		return false
+	}
 }
 
-var robotsCache = map[string]*robotstxt.Group{}
-var robotsCacheLock sync.RWMutex
+// expired returns whether the robots.txt couldn't be fetched and should be
+// fetched again.
+func (e *robotsEntry) expired() bool {
+	return e.done() && e.data == nil && time.Now().After(e.expires)
+}
 
 type Page struct {
 	URL         string
@@ -115,14 +102,117 @@ type Page struct {
 	Hash        string
 	Fetched     time.Time
 	Links       []string
+	// LinkContext is the text around the links that have any.
+	LinkContext []exambotlib.Link `json:",omitempty"`
+	// Discovered is set if the page is a sitemap or API listing that the
+	// links were discovered from rather than a crawled page. They aren't
+	// recrawled.
+	Discovered bool `json:",omitempty"`
+
+	// ETag and LastModified are the validators of the last response and are
+	// sent back when recrawling so unchanged pages aren't downloaded again.
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+	// Changed is when the page was last seen to change.
+	Changed time.Time `json:",omitempty"`
+	// RecrawlInterval is how long to wait between crawls of the page. It
+	// adapts to how often the page changes.
+	RecrawlInterval time.Duration `json:",omitempty"`
+	NextCrawl       time.Time     `json:",omitempty"`
+}
+
+// scheduleRecrawl sets when to crawl the page next based on whether it
+// changed since the previous crawl.
+func (p *Page) scheduleRecrawl(prev *Page) {
+	if prev == nil {
+		p.Changed = p.Fetched
+		p.RecrawlInterval = exambotlib.DefaultRecrawlInterval
+	} else {
+		changed := p.Hash != prev.Hash
+		p.Changed = prev.Changed
+		if changed {
+			p.Changed = p.Fetched
+		}
+		p.RecrawlInterval = exambotlib.NextRecrawlInterval(prev.RecrawlInterval, changed)
+	}
+	p.NextCrawl = p.Fetched.Add(p.RecrawlInterval)
+}
+
+// nextCrawl returns when the page should be crawled next. Pages saved before
+// recrawls were scheduled are due the default interval after they were
+// fetched.
+func (p Page) nextCrawl() time.Time {
+	if p.NextCrawl.IsZero() {
+		return p.Fetched.Add(exambotlib.DefaultRecrawlInterval)
+	}
+	return p.NextCrawl
+}
+
+// newPDFLinks returns the number of links to PDFs on the page that weren't on
+// prev.
+func (p Page) newPDFLinks(prev *Page) int {
+	old := map[string]struct{}{}
+	if prev != nil {
+		for _, link := range prev.Links {
+			old[link] = struct{}{}
+		}
+	}
+	var n int
+	for _, link := range p.Links {
+		if _, ok := old[link]; ok {
+			continue
+		}
+		old[link] = struct{}{}
+		if config.PDFRegexp.MatchString(strings.ToLower(link)) {
+			n++
+		}
+	}
+	return n
+}
+
+// linksToPDFs returns whether the page links to any PDFs.
+func (p Page) linksToPDFs() bool {
+	for _, link := range p.Links {
+		if config.PDFRegexp.MatchString(strings.ToLower(link)) {
+			return true
+		}
+	}
+	return false
 }
 
 func makeGet(url string) (*http.Response, error) {
+	return makeConditionalGet(url, nil)
+}
+
+// get fetches the URL with its fetcher or otherwise over the web with a
+// request that's conditional on prev.
+func (s *Spider) get(uri string, prev *Page) (*http.Response, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if f := s.Fetchers.Lookup(u); f != nil {
+		return f.Fetch(u)
+	}
+	return makeConditionalGet(uri, prev)
+}
+
+// makeConditionalGet makes a GET request that only returns the body if the
+// page changed since prev was fetched.
+func makeConditionalGet(url string, prev *Page) (*http.Response, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("User-Agent", userAgent)
+	if prev != nil {
+		if len(prev.ETag) > 0 {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if len(prev.LastModified) > 0 {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Printf("GET err %s: %s", url, err)
@@ -149,113 +239,150 @@ func cleanURL(uri string) (string, error) {
 	return u.String(), nil
 }
 
-func validURL(uri string) (bool, error) {
-	lower := strings.ToLower(uri)
-	u, err := url.Parse(uri)
-	if err != nil {
-		return false, err
+// robotsData returns the robots.txt of the host, or nil if it can't be
+// fetched. Each robots.txt is only fetched once at a time and failures are
+// cached for robotsRetry so every URL of a host that's down doesn't fetch it
+// again.
+func (s *Spider) robotsData(host string) *robotstxt.RobotsData {
+	s.robotsMu.Lock()
+	e, ok := s.robots[host]
+	if ok && !e.expired() {
+		s.robotsMu.Unlock()
+		<-e.ready
+		return e.data
 	}
+	e = &robotsEntry{ready: make(chan struct{})}
+	s.robots[host] = e
+	s.robotsMu.Unlock()
 
-	if u.Scheme == piazza.PiazzaScheme {
-		return true, nil
+	e.data = s.fetchRobots(host)
+	if e.data == nil {
+		e.expires = time.Now().Add(robotsRetry)
 	}
+	close(e.ready)
+	return e.data
+}
 
-	// Check valid hosts
-	hostRules, ok := validHosts[u.Host]
-	if !ok {
-		return false, nil
-	}
+// robotsFetched returns whether the robots.txt of the host was fetched and
+// doesn't need to be fetched again.
+func (s *Spider) robotsFetched(host string) bool {
+	s.robotsMu.Lock()
+	defer s.robotsMu.Unlock()
 
-	matchesWhiteList := len(hostRules.whitelist) == 0
-	for _, pattern := range hostRules.whitelist {
-		match, err := regexp.MatchString(pattern, lower)
-		if err != nil {
-			return false, err
-		}
-		if match {
-			matchesWhiteList = true
-			break
-		}
+	e, ok := s.robots[host]
+	return ok && e.done() && !e.expired()
+}
+
+// fetchRobots fetches the robots.txt of the host with the same host limits as
+// crawled pages.
+func (s *Spider) fetchRobots(host string) *robotstxt.RobotsData {
+	if !s.acquireHost(host) {
+		return nil
 	}
-	if !matchesWhiteList {
-		return false, nil
+	robots, status := getRobots(host)
+	failed := status == 0 || status == http.StatusTooManyRequests || status >= 500
+	s.Hosts.Release(host, failed)
+	if status == 0 {
+		s.Monitor.Failed(host)
+	} else {
+		s.Monitor.Fetched(host, status, failed)
 	}
+	return robots
+}
 
-	// Skip root indexes. "/", ""
-	if len(u.Path) <= 1 {
-		return false, nil
+// getRobots fetches the robots.txt of the host and returns it with the status
+// code of the response, or 0 if there wasn't one. It's nil if the host had an
+// error.
+func getRobots(host string) (*robotstxt.RobotsData, int) {
+	resp, err := makeGet(fmt.Sprintf("https://%s/robots.txt", host))
+	if err != nil {
+		return nil, 0
 	}
-
-	if !(exambotlib.ValidSuffix(u.Path, validPostfix) && exambotlib.ValidSuffix(u.RawQuery, validPostfix)) {
-		return false, nil
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, resp.StatusCode
 	}
-
-	for _, pattern := range blacklist {
-		match, err := regexp.MatchString(pattern, lower)
-		if err != nil {
-			return false, err
-		}
-		if match {
-			return false, nil
-		}
+	robots, err := robotstxt.FromResponse(resp)
+	if err != nil {
+		return nil, resp.StatusCode
 	}
+	return robots, resp.StatusCode
+}
 
-	// Check against /robots.txt
-	robotsCacheLock.RLock()
-	robots, ok := robotsCache[u.Host]
-	robotsCacheLock.RUnlock()
-
-	if !ok {
-		resp, err := makeGet(fmt.Sprintf("https://%s/robots.txt", u.Host))
-		if err != nil {
-			return false, nil
-		}
-		defer resp.Body.Close()
-		rhost, err := robotstxt.FromResponse(resp)
-		if err != nil {
-			return false, nil
-		}
-		if u.Host == "github.com" {
-			robots = rhost.FindGroup("Googlebot")
-		} else {
-			robots = rhost.FindGroup(userAgent)
-		}
-
-		robotsCacheLock.Lock()
-		robotsCache[u.Host] = robots
-		robotsCacheLock.Unlock()
+// robotsGroup returns the rules of the host's robots.txt for the bot, or nil
+// if it couldn't be fetched.
+func robotsGroup(host string, robots *robotstxt.RobotsData) *robotstxt.Group {
+	if robots == nil {
+		return nil
 	}
+	if host == "github.com" {
+		return robots.FindGroup("Googlebot")
+	}
+	return robots.FindGroup(userAgent)
+}
 
-	return robots.Test(u.Path), nil
+// allowedByRobots checks the URL against the robots.txt of its host and sets
+// the crawl delay the host asks for.
+func (s *Spider) allowedByRobots(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	// Fetchers don't fetch from the web.
+	if s.Fetchers.Lookup(u) != nil {
+		return true
+	}
+	robots := robotsGroup(u.Host, s.robotsData(u.Host))
+	if robots == nil || !robots.Test(u.Path) {
+		s.Monitor.Blocked()
+		return false
+	}
+	s.Hosts.SetCrawlDelay(u.Host, robots.CrawlDelay)
+	return true
 }
 
-// fetchURL returns all links from the page and the hash of the page.
-func (s *Spider) fetchURL(uri string) (Page, error) {
+// fetchURL returns all links from the page and the hash of the page. If prev
+// is set the page is only downloaded if it changed and prev is returned
+// otherwise.
+func (s *Spider) fetchURL(uri string, prev *Page) (Page, error) {
 	u, err := url.Parse(uri)
 	if err != nil {
 		return Page{}, err
 	}
-	var reader io.Reader
-	var statusCode int
-	if u.Scheme == piazza.PiazzaScheme {
-		log.Printf("PIAZZA %s", uri)
-		resp, err := s.Piazza.Get(uri)
-		if err != nil {
-			return Page{}, err
-		}
-		reader = strings.NewReader(resp)
-		statusCode = 200
-	} else {
-		resp, err := makeGet(uri)
-		if err != nil {
+	resp, err := s.get(uri, prev)
+	if err != nil {
+		return Page{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && prev != nil {
+		page := *prev
+		page.Fetched = time.Now()
+		return page, nil
+	}
+	reader := resp.Body
+	statusCode := resp.StatusCode
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	contentType := resp.Header.Get("Content-Type")
+
+	hasher := sha1.New()
+
+	// Only HTML has links to parse.
+	if len(contentType) > 0 && !strings.Contains(contentType, "html") {
+		if _, err := io.Copy(hasher, reader); err != nil {
 			return Page{}, err
 		}
-		defer resp.Body.Close()
-		reader = resp.Body
-		statusCode = resp.StatusCode
+		return Page{
+			URL:          uri,
+			StatusCode:   statusCode,
+			ContentType:  contentType,
+			Hash:         hex.EncodeToString(hasher.Sum(nil)),
+			Fetched:      time.Now(),
+			ETag:         etag,
+			LastModified: lastModified,
+		}, nil
 	}
 
-	hasher := sha1.New()
 	bodyReader := io.TeeReader(reader, hasher)
 
 	doc, err := goquery.NewDocumentFromReader(bodyReader)
@@ -263,12 +390,11 @@ func (s *Spider) fetchURL(uri string) (Page, error) {
 		return Page{}, err
 	}
 
-	base, err := url.Parse(uri)
-	if err != nil {
-		return Page{}, err
-	}
+	// Relative links on pages of opaque fetchers aren't URLs of the fetcher.
+	opaque := s.opaque(uri)
 
 	var links []string
+	var linkContext []exambotlib.Link
 	doc.Find("a").Each(func(_ int, s *goquery.Selection) {
 		uri := s.AttrOr("href", "")
 
@@ -278,110 +404,96 @@ func (s *Spider) fetchURL(uri string) (Page, error) {
 			return
 		}
 
-		abs := base.ResolveReference(ref)
+		if opaque && !ref.IsAbs() {
+			return
+		}
+		abs := u.ResolveReference(ref)
 		abs.Fragment = ""
 		link := abs.String()
-		// Don't resolve relative to piazza://
-		if !(strings.HasPrefix(link, piazza.PiazzaScheme) && !strings.HasPrefix(uri, piazza.PiazzaScheme)) {
-			links = append(links, link)
+		links = append(links, link)
+		if l := exambotlib.LinkContext(s); l.HasContext() {
+			l.URL = link
+			linkContext = append(linkContext, l)
 		}
 	})
 
 	hash := hex.EncodeToString(hasher.Sum(nil))
 	return Page{
-		URL:        uri,
-		StatusCode: statusCode,
-		Hash:       hash,
-		Links:      links,
-		Fetched:    time.Now(),
+		URL:          uri,
+		StatusCode:   statusCode,
+		ContentType:  contentType,
+		Hash:         hash,
+		Links:        links,
+		LinkContext:  linkContext,
+		Fetched:      time.Now(),
+		ETag:         etag,
+		LastModified: lastModified,
 	}, nil
 }
 
-var regexCache = map[string]*regexp.Regexp{}
-
-func regexpMatch(pattern, path string) bool {
-	r, ok := regexCache[pattern]
-	if !ok {
-		r = regexp.MustCompile(pattern)
-		regexCache[pattern] = r
-	}
-
-	return r.FindIndex([]byte(path)) != nil
-}
-
-func (f *URLScore) computeScore() {
-	path := strings.ToLower(f.URL)
-	var score int
-	for s, rs := range scoreRegexes {
-		for _, r := range rs {
-			if regexpMatch(r, path) {
-				score += s
-			}
-		}
-	}
-	f.Score = score
-}
-
-// URLScore is a single URL and a score
-type URLScore struct {
-	URL   string
-	Score int `json:",omitempty"`
-}
-
-// An URLHeap is a min-heap of strings.
-type URLHeap []*URLScore
-
-func (h URLHeap) Len() int           { return len(h) }
-func (h URLHeap) Less(i, j int) bool { return h[i].Score < h[j].Score }
-func (h URLHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
-
-// Push ...
-func (h *URLHeap) Push(x interface{}) {
-	// Push and Pop use pointer receivers because they modify the slice's length,
-	// not just its contents.
-	*h = append(*h, x.(*URLScore))
-}
-
-// Pop ...
-func (h *URLHeap) Pop() interface{} {
-	old := *h
-	n := len(old)
-	x := old[n-1]
-	*h = old[0 : n-1]
-	return x
+func (s *Spider) makeURLScore(uri string) *exambotlib.URLScore {
+	return &exambotlib.URLScore{URL: uri, Score: s.Scope.Score(uri)}
 }
 
 // Spider is an exam spider.
 type Spider struct {
-	Out io.WriteCloser
-	Mu  struct {
-		sync.RWMutex
-
-		ToVisit    URLHeap
-		ToVisitMap map[string]struct{}
-
-		Seen    *bloom.BloomFilter
-		Visited *bloom.BloomFilter
-	}
-	DB     *bolt.DB
-	Piazza *piazza.HTMLWrapper
+	Out      io.WriteCloser
+	Frontier *exambotlib.Frontier
+	DB       *bolt.DB
+	Fetchers *exambotlib.Fetchers
+	Hosts    *exambotlib.HostScheduler
+	Scope    *exambotlib.Scope
+	Monitor  *exambotlib.Monitor
+	// Files stores the exam candidates so they're kept even if they're
+	// deleted.
+	Files *exambotlib.FileStore
+	// Candidates match the URLs of PDFs that might be exams.
+	Candidates []*regexp.Regexp
+
+	stateMu   sync.Mutex
+	stateCond *sync.Cond
+	state     string
+
+	discoveredMu sync.Mutex
+	// discovered is the sitemaps, repositories and sites that discovery has
+	// been started for.
+	discovered map[string]struct{}
+
+	robotsMu sync.Mutex
+	// robots is the robots.txt of each host.
+	robots map[string]*robotsEntry
 }
 
-// MakeSpider makes a new spider.
-func MakeSpider(db *bolt.DB, p *piazza.HTMLWrapper) *Spider {
+// MakeSpider makes a new spider and loads its frontier.
+func MakeSpider(db *bolt.DB, fetchers *exambotlib.Fetchers, scope *exambotlib.Scope, cfg *config.Config) (*Spider, error) {
 	s := &Spider{
-		DB:     db,
-		Piazza: p,
+		DB:         db,
+		Fetchers:   fetchers,
+		Hosts:      exambotlib.MakeHostScheduler(crawlDelay, maxRequestsPerHost),
+		Scope:      scope,
+		Monitor:    exambotlib.MakeMonitor(),
+		Files:      exambotlib.MakeFileStore(fileStoreDir, cfg.MaxFileSize),
+		Candidates: examRegexps(cfg),
+		state:      stateRunning,
+
+		discovered: map[string]struct{}{},
+		robots:     map[string]*robotsEntry{},
 	}
+	s.stateCond = sync.NewCond(&s.stateMu)
 
-	s.Mu.ToVisitMap = map[string]struct{}{}
-	s.Mu.Seen = bloom.NewWithEstimates(maxNumberOfPages, falsePositiveRate)
-	s.Mu.Visited = bloom.NewWithEstimates(maxNumberOfPages, falsePositiveRate)
-	log.Printf("Fetched bloom filter: m %d k %d", s.Mu.Seen.Cap(), s.Mu.Seen.K())
+	var err error
+	s.Frontier, err = exambotlib.OpenFrontier(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.importLegacyState(); err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded frontier with %d URLs", s.Frontier.Len())
 
 	go s.statsMonitor()
 
-	return s
+	return s, nil
 }
 
 func (s *Spider) statsMonitor() {
@@ -391,89 +503,56 @@ func (s *Spider) statsMonitor() {
 }
 
 func (s *Spider) printStats() {
-	s.Mu.RLock()
-	s.Mu.RUnlock()
-	log.Printf("ToVisit %d", len(s.Mu.ToVisit))
+	stats := s.Monitor.Stats(nil)
+	log.Printf("ToVisit %d (%s)", s.Frontier.Len(), s.State())
+	log.Printf("Fetched %d, failed %d, retried %d, blocked by robots.txt %d, deferred %d, PDF candidates %d",
+		stats.Fetched,
+		stats.Failed,
+		stats.Retried,
+		stats.Blocked,
+		stats.Deferred,
+		stats.Candidates,
+	)
 }
 
 type spiderState struct {
-	ToVisit URLHeap
+	ToVisit exambotlib.URLHeap
 }
 
-// Save saves the spider state.
-func (s *Spider) Save() error {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
-
+// importLegacyState moves the state of crawls from before the frontier was
+// stored in bolt into it. The queue is imported from state.json and, since
+// the old bloom filters can't be converted, the seen set is rebuilt from the
+// saved pages.
+func (s *Spider) importLegacyState() error {
+	var legacy bool
 	if err := s.DB.Update(func(tx *bolt.Tx) error {
-		b, err := tx.CreateBucketIfNotExists(assortedBucket)
-		if err != nil {
-			return err
-		}
-		v, err := s.Mu.Seen.MarshalJSON()
-		if err != nil {
-			return err
-		}
-		log.Printf("Seen bloom filter size = %d bytes", len(v))
-		if err := b.Put(bloomFilterSeenKey, v); err != nil {
-			return err
-		}
-		v, err = s.Mu.Visited.MarshalJSON()
-		if err != nil {
-			return err
+		b := tx.Bucket(assortedBucket)
+		if b == nil || b.Get(bloomFilterSeenKey) == nil {
+			return nil
 		}
-		log.Printf("Visited bloom filter size = %d bytes", len(v))
-		if err := b.Put(bloomFilterVisitedKey, v); err != nil {
+		legacy = true
+		if err := b.Delete(bloomFilterSeenKey); err != nil {
 			return err
 		}
-		return nil
+		return b.Delete(bloomFilterVisitedKey)
 	}); err != nil {
 		return err
 	}
-
-	f, err := os.OpenFile("state.json", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	state := spiderState{
-		s.Mu.ToVisit,
-	}
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(state); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// Load loads the spider state.
-func (s *Spider) Load() error {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
-
-	if err := s.DB.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(assortedBucket)
-		if b == nil {
-			return errors.New("can't find bucket")
-		}
-		v := b.Get(bloomFilterSeenKey)
-		if err := s.Mu.Seen.UnmarshalJSON(v); err != nil {
-			return err
+	if legacy {
+		log.Println("Rebuilding seen URLs from saved pages...")
+		var urls []string
+		for link := range allLinks(s.DB) {
+			urls = append(urls, link.URL)
 		}
-		v = b.Get(bloomFilterVisitedKey)
-		if err := s.Mu.Visited.UnmarshalJSON(v); err != nil {
+		if _, err := s.Frontier.MarkSeen(urls); err != nil {
 			return err
 		}
-		return nil
-	}); err != nil {
-		log.Printf("ERR Loading Bloom Filter: %s", err)
 	}
 
-	f, err := os.Open("state.json")
-	if err != nil {
+	f, err := os.Open(legacyStatePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
 		return err
 	}
 	defer f.Close()
@@ -482,21 +561,20 @@ func (s *Spider) Load() error {
 	if err := json.NewDecoder(f).Decode(&state); err != nil {
 		return err
 	}
-
-	s.Mu.ToVisit = state.ToVisit
-	for _, v := range s.Mu.ToVisit {
-		s.Mu.ToVisitMap[v.URL] = struct{}{}
+	added, err := s.Frontier.Push(state.ToVisit)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	log.Printf("Imported %d URLs from %s", added, legacyStatePath)
+	return os.Rename(legacyStatePath, legacyStatePath+".imported")
 }
 
-// Worker ...
+// Worker crawls URLs from the frontier until the spider is drained.
 func (s *Spider) Worker() {
 	var lastNoWork bool
 	var timeNoWork time.Time
-	for {
-		if len(s.Mu.ToVisit) == 0 {
+	for s.waitRunning() {
+		if s.Frontier.Len() == 0 {
 			if !lastNoWork {
 				log.Println("No URLs queued to visit!")
 				lastNoWork = true
@@ -504,56 +582,147 @@ func (s *Spider) Worker() {
 			}
 			if time.Now().After(timeNoWork) {
 				log.Printf("No work for %ds, shutting down.", shutdownTime)
-				os.Exit(0)
+				s.Drain()
+				return
 			}
 			time.Sleep(1 * time.Second)
 			continue
 		}
 
-		s.Mu.Lock()
-		if len(s.Mu.ToVisit) == 0 {
-			s.Mu.Unlock()
+		url, host, wait := s.nextURL()
+		if url == nil {
+			if wait > time.Second {
+				wait = time.Second
+			}
+			time.Sleep(wait)
 			continue
 		}
-		url := heap.Pop(&s.Mu.ToVisit).(*URLScore)
-		delete(s.Mu.ToVisitMap, url.URL)
-		s.Mu.Unlock()
 
-		valid, err := validURL(url.URL)
-		if err != nil {
+		failed := s.crawl(url, host)
+		if failed && url.Retries < maxRetries {
+			s.retry(url)
+		} else if err := s.Frontier.Done(url.URL); err != nil {
 			log.Printf("WORKER err: %s", err)
-			continue
 		}
 
-		if !valid {
-			continue
+		lastNoWork = false
+	}
+}
+
+// crawl fetches and saves the page and queues its links. It returns whether
+// fetching the page failed and should be retried.
+func (s *Spider) crawl(url *exambotlib.URLScore, host string) bool {
+	valid, err := s.Scope.Valid(url.URL)
+	if err != nil {
+		log.Printf("WORKER err: %s", err)
+	}
+	// Fetchers' URLs are always in scope, so their candidates are files too.
+	fetcher, _ := s.fetcher(url.URL)
+	file := (!valid || fetcher != nil) && s.isCandidate(url.URL)
+	if !(valid || file) {
+		s.Hosts.Release(host, false)
+		return false
+	}
+	// Fetching robots.txt needs its own request to the host so the one
+	// reserved for the URL is given back until it's fetched.
+	if fetcher == nil && !s.robotsFetched(host) {
+		s.Hosts.Release(host, false)
+		if !s.allowedByRobots(url.URL) {
+			return false
 		}
+		if !s.acquireHost(host) {
+			return true
+		}
+	} else if !s.allowedByRobots(url.URL) {
+		s.Hosts.Release(host, false)
+		return false
+	}
+	if file {
+		return s.crawlFile(url.URL, host)
+	}
 
-		page, err := s.fetchURL(url.URL)
+	prev, err := s.loadPage(url.URL)
+	if err != nil {
+		log.Printf("WORKER err: %s", err)
+	}
+	page, err := s.fetchURL(url.URL, prev)
+	failed := err != nil || page.StatusCode == http.StatusTooManyRequests || page.StatusCode >= 500
+	s.Hosts.Release(host, failed)
+	if err != nil {
+		s.Monitor.Failed(host)
+		log.Printf("WORKER err: %s", err)
+		return true
+	}
+	s.Monitor.Fetched(host, page.StatusCode, failed)
+	if failed {
+		return true
+	}
+
+	// Skip pages with the same content as another page, but keep
+	// recrawling pages we already have.
+	if prev == nil && !s.alwaysVisit(url.URL) {
+		duplicate, err := s.duplicatePage(page)
 		if err != nil {
 			log.Printf("WORKER err: %s", err)
-			continue
+			return false
 		}
-
-		s.Mu.Lock()
-		visited := s.Mu.Visited.TestAndAddString(page.Hash)
-		s.Mu.Unlock()
-
-		if visited && !alwaysVisit(url.URL) {
-			continue
+		if duplicate {
+			return false
 		}
+	}
 
-		if err := s.savePage(page); err != nil {
+	// Only follow links if 200 status code and they might be new.
+	changed := prev == nil || prev.Hash != page.Hash
+	if changed {
+		s.Monitor.Candidates(page.newPDFLinks(prev))
+		if err := s.addReferrers(page.URL, page.Links); err != nil {
 			log.Printf("WORKER err: %s", err)
-			continue
 		}
+	}
+	if page.StatusCode == 200 && (changed || s.alwaysVisit(url.URL)) {
+		s.AddAndExpandURLs(page.Links, true)
+	}
 
-		// Only follow links if 200 status code.
-		if page.StatusCode == 200 {
-			s.AddAndExpandURLs(page.Links, true)
+	// The page is saved after its links are queued so if the crawler is
+	// killed in between, the page is changed when it's crawled again and its
+	// links are queued then.
+	page.scheduleRecrawl(prev)
+	if err := s.savePage(page); err != nil {
+		log.Printf("WORKER err: %s", err)
+	}
+	return false
+}
+
+// nextURL pops the best URL whose host can be crawled now and reserves a
+// request to the host. If there isn't one it returns how long until there
+// might be.
+func (s *Spider) nextURL() (*exambotlib.URLScore, string, time.Duration) {
+	var host string
+	us, wait := s.Frontier.Pop(maxDeferred, func(us *exambotlib.URLScore) (time.Duration, bool) {
+		u, err := url.Parse(us.URL)
+		if err != nil {
+			// Let the worker drop it.
+			host = ""
+			return 0, true
+		}
+		wait, ok := s.Hosts.Acquire(u.Host)
+		if !ok {
+			s.Monitor.Deferred()
+			return wait, false
 		}
+		host = u.Host
+		return 0, true
+	})
+	return us, host, wait
+}
 
-		lastNoWork = false
+// retry queues the URL again. The host is already backed off so it won't be
+// retried right away.
+func (s *Spider) retry(u *exambotlib.URLScore) {
+	u.Retries++
+	s.Monitor.Retried()
+	if err := s.Frontier.Retry(u); err != nil {
+		log.Printf("WORKER err: %s", err)
 	}
 }
 
@@ -593,10 +762,85 @@ func (s *Spider) savePage(p Page) error {
 	return nil
 }
 
+// duplicatePage returns whether another page with the same content has been
+// saved.
+func (s *Spider) duplicatePage(p Page) (bool, error) {
+	var duplicate bool
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pageHashBucket)
+		if b == nil {
+			return nil
+		}
+		v := b.Get(hashKey(p.Hash))
+		duplicate = v != nil && string(v) != string(pageKey(p.URL))
+		return nil
+	})
+	return duplicate, err
+}
+
 func (p Page) marshal() ([]byte, error) {
 	return json.Marshal(p)
 }
 
+// loadPage returns the saved page for the URL or nil if it hasn't been
+// crawled.
+func (s *Spider) loadPage(url string) (*Page, error) {
+	var page *Page
+	if err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pageBucket)
+		if b == nil {
+			return nil
+		}
+		v := b.Get(pageKey(url))
+		if v == nil {
+			return nil
+		}
+		page = &Page{}
+		return json.Unmarshal(v, page)
+	}); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// QueueRecrawls queues the saved pages that are due to be crawled again. If
+// termly is set, pages that link to PDFs and haven't been crawled since the
+// current term started are queued too since exams are posted to the same
+// pages every term.
+func (s *Spider) QueueRecrawls(termly bool) error {
+	now := time.Now()
+	termStart := exambotlib.TermStart(now)
+	var due []*exambotlib.URLScore
+	if err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pageBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var page Page
+			if err := json.Unmarshal(v, &page); err != nil {
+				return err
+			}
+			if page.StatusCode != 200 || page.Discovered {
+				return nil
+			}
+			if page.nextCrawl().Before(now) || (termly && page.Fetched.Before(termStart) && page.linksToPDFs()) {
+				due = append(due, s.makeURLScore(page.URL))
+			}
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+
+	added, err := s.Frontier.Push(due)
+	if err != nil {
+		return err
+	}
+	log.Printf("Queued %d pages to recrawl", added)
+	return nil
+}
+
 // AddAndExpandURLs cleans the URLs and adds them.
 func (s *Spider) AddAndExpandURLs(urls []string, expand bool) {
 	// Expand valid URLs
@@ -610,14 +854,14 @@ func (s *Spider) AddAndExpandURLs(urls []string, expand bool) {
 			log.Printf("WORKER err: %s", err)
 			continue
 		}
-		valid, err := validURL(clean)
+		valid, err := s.Scope.Valid(clean)
 		if err != nil {
 			log.Printf("WORKER err: %s", err)
 			continue
 		}
 		if valid {
-			// don't expand piazza:// urls since we want to always visit the root ones
-			if expand && !strings.HasPrefix(clean, "piazza") {
+			s.discover(clean)
+			if expand && !s.opaque(clean) {
 				expanded, err := exambotlib.ExpandURLToParents(clean)
 				if err != nil {
 					log.Printf("WORKER err: %s", err)
@@ -627,55 +871,94 @@ func (s *Spider) AddAndExpandURLs(urls []string, expand bool) {
 			} else {
 				added += s.AddURLs([]string{clean})
 			}
+		} else if s.isCandidate(clean) {
+			added += s.AddURLs([]string{clean})
 		}
 	}
 }
 
-func alwaysVisit(uri string) bool {
-	u, _ := url.Parse(uri)
-	if u.Scheme == piazza.PiazzaScheme {
-		return len(u.Path) <= 1
+// fetcher returns the fetcher of the URL or nil if it's fetched over the web.
+func (s *Spider) fetcher(uri string) (exambotlib.Fetcher, *url.URL) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, nil
 	}
-	return false
+	return s.Fetchers.Lookup(u), u
+}
+
+// alwaysVisit returns whether the URL's fetcher wants it crawled every time
+// it's found.
+func (s *Spider) alwaysVisit(uri string) bool {
+	f, u := s.fetcher(uri)
+	return f != nil && f.AlwaysVisit(u)
+}
+
+// opaque returns whether the URL's fetcher has opaque URLs, which aren't
+// expanded to their parents.
+func (s *Spider) opaque(uri string) bool {
+	f, _ := s.fetcher(uri)
+	return f != nil && f.Opaque()
 }
 
 // AddURLs adds a bunch of URLs to be processed if valid and returns how many
 // were added.
 func (s *Spider) AddURLs(urls []string) int {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
-	added := 0
+	unseen, err := s.Frontier.Unseen(urls)
+	if err != nil {
+		log.Printf("add URL err: %s", err)
+		return 0
+	}
+	isUnseen := map[string]bool{}
+	for _, url := range unseen {
+		isUnseen[url] = true
+	}
+
+	// URLs are only marked seen once they're queued so URLs that are skipped,
+	// e.g. because robots.txt couldn't be fetched, are checked again when
+	// they're found again.
+	var queue, revisit []*exambotlib.URLScore
 	for _, url := range urls {
-		if s.Mu.Seen.TestAndAddString(url) && !alwaysVisit(url) {
+		unseen := isUnseen[url]
+		if !unseen && !s.alwaysVisit(url) {
 			continue
 		}
-
-		fmt.Fprintf(s.Out, "%s\n", url)
-		valid, err := validURL(url)
+		delete(isUnseen, url)
+		valid, err := s.Scope.Valid(url)
 		if err != nil {
 			log.Printf("add URL err: %s", err)
 			continue
 		}
-		if !valid {
+		if !(valid || s.isCandidate(url)) || !s.allowedByRobots(url) {
 			continue
 		}
-		// Don't add a URL multiple times.
-		if _, ok := s.Mu.ToVisitMap[url]; ok {
-			continue
+		if unseen {
+			fmt.Fprintf(s.Out, "%s\n", url)
+			queue = append(queue, s.makeURLScore(url))
+		} else {
+			revisit = append(revisit, s.makeURLScore(url))
 		}
-		us := &URLScore{URL: url}
-		us.computeScore()
-		heap.Push(&s.Mu.ToVisit, us)
-		s.Mu.ToVisitMap[url] = struct{}{}
-		added++
 	}
-	return added
+	added, err := s.Frontier.Add(queue)
+	if err != nil {
+		log.Printf("add URL err: %s", err)
+	}
+	revisited, err := s.Frontier.Push(revisit)
+	if err != nil {
+		log.Printf("add URL err: %s", err)
+	}
+	return added + revisited
+}
+
+// link is a link and the page it was first found on.
+type link struct {
+	exambotlib.Link
+	Referrer string
 }
 
-func allLinks(db *bolt.DB) <-chan string {
-	outputChan := make(chan string, workers.Count)
+func allLinks(db *bolt.DB) <-chan link {
+	outputChan := make(chan link, workers.Count)
 	inputChan := make(chan []byte, workers.Count)
-	seen := bloom.NewWithEstimates(maxNumberOfPages, falsePositiveRate)
+	seen := map[string]struct{}{}
 
 	var wg sync.WaitGroup
 	var count int
@@ -687,23 +970,25 @@ func allLinks(db *bolt.DB) <-chan string {
 		go func() {
 			defer wg.Done()
 
-			var page Page
-
 			for v := range inputChan {
+				var page Page
 				if err := json.Unmarshal(v, &page); err != nil {
 					log.Fatal(err)
 				}
 
-				seenMu.Lock()
-				s := seen.TestAndAddString(page.URL)
-				seenMu.Unlock()
-				if !s {
-					outputChan <- page.URL
-				}
-
+				links := []link{{Link: exambotlib.Link{URL: page.URL}}}
+				context := exambotlib.LinkContexts(page.LinkContext)
 				for _, l := range page.Links {
+					lc, ok := context[l]
+					if !ok {
+						lc.URL = l
+					}
+					links = append(links, link{Link: lc, Referrer: page.URL})
+				}
+				for _, l := range links {
 					seenMu.Lock()
-					s := seen.TestAndAddString(l)
+					_, s := seen[l.URL]
+					seen[l.URL] = struct{}{}
 					seenMu.Unlock()
 					if !s {
 						outputChan <- l
@@ -749,36 +1034,165 @@ func allLinks(db *bolt.DB) <-chan string {
 
 func commandList(db *bolt.DB) {
 	for link := range allLinks(db) {
-		fmt.Println(link)
+		fmt.Println(link.URL)
 	}
 }
 
-func commandExams(db *bolt.DB) {
-	var regexps []*regexp.Regexp
-	for _, pattern := range config.ExamFirstPass {
-		regexps = append(regexps, regexp.MustCompile(pattern))
+// commandExams prints the linked PDFs that look like exams. With -json
+// they're printed as potential files, one per line, with the page they were
+// linked from and the text around the link so they can be imported with
+// `exams ingress exambot`.
+func commandExams(db *bolt.DB, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("exams", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the exams as JSON potential files")
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
+
+	regexps := examRegexps(cfg)
+	enc := json.NewEncoder(os.Stdout)
 	for link := range allLinks(db) {
-		lower := strings.ToLower(link)
+		lower := strings.ToLower(link.URL)
 		if !config.PDFRegexp.MatchString(lower) {
 			continue
 		}
 
 		for _, r := range regexps {
-			if r.MatchString(lower) {
-				fmt.Println(link)
-				break
+			if !r.MatchString(lower) {
+				continue
+			}
+			if !*asJSON {
+				fmt.Println(link.URL)
+			} else if err := enc.Encode(examdb.File{
+				Source:     link.URL,
+				Referrer:   link.Referrer,
+				AnchorText: link.Text,
+				Heading:    link.Heading,
+				RowText:    link.Row,
+			}); err != nil {
+				return err
 			}
+			break
 		}
 	}
+	return nil
+}
+
+// commandFrontier prints how many URLs are queued per host, or lists the
+// queued URLs that match the filters and optionally removes them.
+func commandFrontier(db *bolt.DB, args []string) error {
+	fs := flag.NewFlagSet("frontier", flag.ExitOnError)
+	host := fs.String("host", "", "only queued URLs on the `host`")
+	pattern := fs.String("match", "", "only queued URLs matching the `regexp`")
+	prune := fs.Bool("prune", false, "remove the matching URLs from the frontier")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var re *regexp.Regexp
+	if len(*pattern) > 0 {
+		var err error
+		if re, err = regexp.Compile(*pattern); err != nil {
+			return err
+		}
+	}
+	filtered := len(*host) > 0 || re != nil
+	if *prune && !filtered {
+		return errors.New("-prune needs -host or -match")
+	}
+
+	f, err := exambotlib.OpenFrontier(db)
+	if err != nil {
+		return err
+	}
+	match := func(us *exambotlib.URLScore) bool {
+		if len(*host) > 0 {
+			u, err := url.Parse(us.URL)
+			if err != nil || u.Host != *host {
+				return false
+			}
+		}
+		return re == nil || re.MatchString(us.URL)
+	}
+
+	if !filtered {
+		hosts := f.HostCounts()
+		var names []string
+		for name := range hosts {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			return hosts[names[i]] > hosts[names[j]]
+		})
+		for _, name := range names {
+			fmt.Printf("%8d %s\n", hosts[name], name)
+		}
+		fmt.Printf("%8d total\n", f.Len())
+		return nil
+	}
+
+	if *prune {
+		removed, err := f.Prune(match)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d URLs.\n", removed)
+		return nil
+	}
+	for _, us := range f.URLs() {
+		if match(&us) {
+			fmt.Printf("%4d %2d %s\n", us.Score, us.Retries, us.URL)
+		}
+	}
+	return nil
+}
+
+// commandScopeTest prints whether the URLs would be crawled with the scope
+// and why not.
+func commandScopeTest(scope *exambotlib.Scope, fetchers *exambotlib.Fetchers, urls []string) error {
+	if len(urls) == 0 {
+		return errors.New("scope-test needs a URL")
+	}
+	for _, uri := range urls {
+		reason, err := scope.Check(uri)
+		if err != nil {
+			return err
+		}
+		fmt.Println(uri)
+		if len(reason) > 0 {
+			fmt.Printf("  scope: skipped, %s\n", reason)
+		} else {
+			fmt.Printf("  scope: crawled with profile %q\n", scope.Profile.Name)
+		}
+		fmt.Printf("  score: %d\n", scope.Score(uri))
+
+		u, err := url.Parse(uri)
+		if err != nil {
+			return err
+		}
+		if fetchers.Lookup(u) != nil {
+			fmt.Printf("  robots.txt: not checked, fetched from a source\n")
+			continue
+		}
+		data, _ := getRobots(u.Host)
+		robots := robotsGroup(u.Host, data)
+		switch {
+		case robots == nil:
+			fmt.Printf("  robots.txt: couldn't be fetched for %s\n", u.Host)
+		case !robots.Test(u.Path):
+			fmt.Printf("  robots.txt: disallowed\n")
+		default:
+			fmt.Printf("  robots.txt: allowed, crawl delay %s\n", robots.CrawlDelay)
+		}
+	}
+	return nil
 }
 
 var (
 	cpuprofile = flag.String("cpuprofile", "", "write cpu profile `file`")
 	memprofile = flag.String("memprofile", "", "write memory profile to `file`")
 
-	piazzaUser = flag.String("piazzauser", "", "username of Piazza account to use for scraping")
-	piazzaPass = flag.String("piazzapass", "", "password of Piazza account to use for scraping")
+	configFile = flag.String("config", os.Getenv("EXAMS_CONFIG"), "load the configuration from a TOML or YAML `file`")
+	profile    = flag.String("profile", "cs", "`name` of the crawl profile to use")
 )
 
 func main() {
@@ -807,28 +1221,62 @@ func main() {
 	}
 	defer db.Close()
 
+	cfg, err := config.Load(*configFile)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	crawlProfile := cfg.CrawlProfile(*profile)
+	if crawlProfile == nil {
+		log.Fatalf("unknown crawl profile %q", *profile)
+	}
+	scope, err := exambotlib.MakeScope(*crawlProfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fetchers, err := makeFetchers(cfg.Sources)
+	if err != nil {
+		log.Fatal(err)
+	}
+	scope.Schemes = fetchers.Schemes()
+
 	args := flag.Args()
-	if len(args) == 1 {
+	recrawl := len(args) == 1 && args[0] == "recrawl"
+	if len(args) > 0 && !recrawl {
 		switch args[0] {
+		case "frontier":
+			if err := commandFrontier(db, args[1:]); err != nil {
+				log.Fatal(err)
+			}
+			return
 		case "list":
 			commandList(db)
 			return
+		case "files":
+			if err := commandFiles(db); err != nil {
+				log.Fatal(err)
+			}
+			return
 		case "exams":
-			commandExams(db)
+			if err := commandExams(db, cfg, args[1:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "scope-test":
+			if err := commandScopeTest(scope, fetchers, args[1:]); err != nil {
+				log.Fatal(err)
+			}
 			return
 		}
 	}
 
-	p, err := piazza.MakeClient(*piazzaUser, *piazzaPass)
+	s, err := MakeSpider(db, fetchers, scope, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	s := MakeSpider(db, p.HTMLWrapper())
-	if err := s.Load(); err != nil {
-		log.Print(err)
-	}
-
 	if _, err2 := os.Stat("index.txt"); os.IsNotExist(err2) {
 		s.Out, err = os.Create("index.txt")
 	} else if err != nil {
@@ -841,47 +1289,61 @@ func main() {
 	}
 	defer s.Out.Close()
 
+	s.registerHandlers(http.DefaultServeMux)
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	go func() {
-		for _ = range c {
-			log.Println("Saving progress...")
-			if err := s.Save(); err != nil {
-				log.Fatal(err)
-			}
-			pprof.StopCPUProfile()
-			if *memprofile != "" {
-				f, err := os.Create(*memprofile)
-				if err != nil {
-					log.Fatal("could not create memory profile: ", err)
-				}
-				runtime.GC() // get up-to-date statistics
-				if err := pprof.WriteHeapProfile(f); err != nil {
-					log.Fatal("could not write memory profile: ", err)
-				}
-				f.Close()
-			}
-			s.printStats()
-			os.Exit(0)
-		}
+		// The frontier is saved as it changes so the crawl can be stopped
+		// right away if draining takes too long.
+		<-c
+		log.Println("Draining, interrupt again to stop now...")
+		s.Drain()
+		<-c
+		s.printStats()
+		os.Exit(1)
 	}()
 
-	s.AddAndExpandURLs(seedURLs, true)
-	log.Println("Fetching seed data from internet archive...")
-	for _, prefix := range archiveSearchPrefixes {
-		log.Printf("... searching prefix %q", prefix)
-		results := archive.SearchPrefix(prefix)
-		var urls []string
-		for result := range results {
-			urls = append(urls, result.OriginalURL)
+	if err := s.QueueRecrawls(recrawl); err != nil {
+		log.Fatal(err)
+	}
+	if !recrawl {
+		s.AddAndExpandURLs(scope.Profile.Seeds, true)
+		log.Println("Fetching seed data from internet archive...")
+		for _, prefix := range scope.Profile.ArchivePrefixes {
+			log.Printf("... searching prefix %q", prefix)
+			results := archive.SearchPrefix(prefix)
+			var urls []string
+			for result := range results {
+				urls = append(urls, result.OriginalURL)
+			}
+			log.Printf("... adding %d urls", len(urls))
+			go s.AddAndExpandURLs(urls, false)
 		}
-		log.Printf("... adding %d urls", len(urls))
-		go s.AddAndExpandURLs(urls, false)
 	}
 
 	log.Printf("Spinning up %d workers...", workers.Count)
-	for i := 0; i < workers.Count-1; i++ {
-		go s.Worker()
+	var wg sync.WaitGroup
+	for i := 0; i < workers.Count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Worker()
+		}()
+	}
+	wg.Wait()
+	log.Println("Drained.")
+	s.printStats()
+
+	if *memprofile != "" {
+		f, err := os.Create(*memprofile)
+		if err != nil {
+			log.Fatal("could not create memory profile: ", err)
+		}
+		runtime.GC() // get up-to-date statistics
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			log.Fatal("could not write memory profile: ", err)
+		}
+		f.Close()
 	}
-	s.Worker()
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/ubccsss/exams/exambot/exambotlib"
+)
+
+const (
+	stateRunning  = "running"
+	statePaused   = "paused"
+	stateDraining = "draining"
+)
+
+// State returns whether the spider is running, paused or draining.
+func (s *Spider) State() string {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	return s.state
+}
+
+func (s *Spider) setState(state string) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	// Draining can't be undone since workers have exited.
+	if s.state == stateDraining {
+		return
+	}
+	s.state = state
+	s.stateCond.Broadcast()
+}
+
+// Pause stops workers from starting to crawl new URLs.
+func (s *Spider) Pause() { s.setState(statePaused) }
+
+// Resume continues a paused crawl.
+func (s *Spider) Resume() { s.setState(stateRunning) }
+
+// Drain stops the workers once the URLs they're crawling are done.
+func (s *Spider) Drain() { s.setState(stateDraining) }
+
+// waitRunning blocks while the spider is paused and returns false if it's
+// draining.
+func (s *Spider) waitRunning() bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	for s.state == statePaused {
+		s.stateCond.Wait()
+	}
+	return s.state == stateRunning
+}
+
+type crawlStatus struct {
+	State    string
+	Queued   int
+	Profile  string
+	Stats    exambotlib.CrawlStats
+	HostList []hostStatus `json:"-"`
+	CodeList []codeStatus `json:"-"`
+}
+
+type hostStatus struct {
+	Host string
+	*exambotlib.HostStats
+}
+
+type codeStatus struct {
+	Code  int
+	Count int64
+}
+
+func (s *Spider) status() crawlStatus {
+	status := crawlStatus{
+		State:   s.State(),
+		Queued:  s.Frontier.Len(),
+		Profile: s.Scope.Profile.Name,
+		Stats:   s.Monitor.Stats(s.Frontier.HostCounts()),
+	}
+	for host, h := range status.Stats.Hosts {
+		status.HostList = append(status.HostList, hostStatus{host, h})
+	}
+	sort.Slice(status.HostList, func(i, j int) bool {
+		a, b := status.HostList[i], status.HostList[j]
+		if a.Fetched+int64(a.Queued) != b.Fetched+int64(b.Queued) {
+			return a.Fetched+int64(a.Queued) > b.Fetched+int64(b.Queued)
+		}
+		return a.Host < b.Host
+	})
+	for code, n := range status.Stats.StatusCodes {
+		status.CodeList = append(status.CodeList, codeStatus{code, n})
+	}
+	sort.Slice(status.CodeList, func(i, j int) bool {
+		return status.CodeList[i].Code < status.CodeList[j].Code
+	})
+	return status
+}
+
+// registerHandlers adds the status page, its JSON and the crawl controls to
+// the mux.
+func (s *Spider) registerHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/status.json", s.handleStatusJSON)
+	mux.HandleFunc("/pause", s.handleControl(s.Pause))
+	mux.HandleFunc("/resume", s.handleControl(s.Resume))
+	mux.HandleFunc("/drain", s.handleControl(s.Drain))
+}
+
+func (s *Spider) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if err := statusTemplate.Execute(w, s.status()); err != nil {
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+func (s *Spider) handleStatusJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.status()); err != nil {
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+func (s *Spider) handleControl(f func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "must POST", http.StatusMethodNotAllowed)
+			return
+		}
+		f()
+		log.Printf("Crawler %s from %s", s.State(), r.RemoteAddr)
+		http.Redirect(w, r, "/status", http.StatusSeeOther)
+	}
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>exambot: {{.State}}</title>
+<meta http-equiv="refresh" content="10">
+<style>
+body { font-family: sans-serif; }
+td, th { padding: 0 1em 0 0; text-align: left; }
+form { display: inline; }
+</style>
+</head>
+<body>
+<h1>exambot: {{.State}}</h1>
+<p>
+  {{if eq .State "running"}}<form method="POST" action="/pause"><button>Pause</button></form>{{end}}
+  {{if eq .State "paused"}}<form method="POST" action="/resume"><button>Resume</button></form>{{end}}
+  {{if ne .State "draining"}}<form method="POST" action="/drain"><button>Drain</button></form>{{end}}
+  <a href="/status.json">JSON</a>
+  <a href="/debug/pprof/">pprof</a>
+</p>
+
+{{with .Stats}}
+<table>
+  <tr><th>Profile</th><td>{{$.Profile}}</td></tr>
+  <tr><th>Started</th><td>{{.Started.Format "2006-01-02 15:04:05"}} ({{.Uptime}})</td></tr>
+  <tr><th>Queued</th><td>{{$.Queued}}</td></tr>
+  <tr><th>Fetched</th><td>{{.Fetched}} ({{printf "%.1f" .PagesPerMinute}}/min)</td></tr>
+  <tr><th>Failed</th><td>{{.Failed}} ({{printf "%.3f" .ErrorRate}} error rate)</td></tr>
+  <tr><th>Retried</th><td>{{.Retried}}</td></tr>
+  <tr><th>Blocked by robots.txt</th><td>{{.Blocked}}</td></tr>
+  <tr><th>Deferred</th><td>{{.Deferred}}</td></tr>
+  <tr><th>PDF candidates</th><td>{{.Candidates}}</td></tr>
+</table>
+
+<h2>Throughput</h2>
+<table>
+  <tr><th>Minute</th><th>Fetched</th><th>Failed</th></tr>
+  {{range .Throughput}}
+  <tr><td>{{.Time.Format "15:04"}}</td><td>{{.Fetched}}</td><td>{{.Failed}}</td></tr>
+  {{end}}
+</table>
+{{end}}
+
+<h2>Status codes</h2>
+<table>
+  {{range .CodeList}}
+  <tr><th>{{.Code}}</th><td>{{.Count}}</td></tr>
+  {{end}}
+</table>
+
+<h2>Hosts</h2>
+<table>
+  <tr><th>Host</th><th>Fetched</th><th>Failed</th><th>Queued</th></tr>
+  {{range .HostList}}
+  <tr><td>{{.Host}}</td><td>{{.Fetched}}</td><td>{{.Failed}}</td><td>{{.Queued}}</td></tr>
+  {{end}}
+</table>
+</body>
+</html>
+`))
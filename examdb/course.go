@@ -11,49 +11,125 @@ import (
 
 // Course represents a single course.
 type Course struct {
-	// Code should always be lowercase.
+	// Code should always be lowercase and in the canonical form of the
+	// department code, a space and the course number, e.g. "cpsc 110".
 	Code string `json:",omitempty"`
 	Desc string `json:",omitempty"`
+	// Aliases are other codes the course is known by, e.g. legacy codes and
+	// cross-listings.
+	Aliases []string `json:",omitempty"`
+	// Predecessors are the codes of the courses this course replaced when it
+	// was renumbered.
+	Predecessors []string `json:",omitempty"`
+	// Successors are the codes of the courses that replaced this course.
+	Successors []string `json:",omitempty"`
+	// Catalog is how the course was listed in the calendar of each academic
+	// year, sorted by year.
+	Catalog []CatalogEntry `json:",omitempty"`
 }
 
-// Department returns the department code for the course.
-func (c Course) Department() string {
-	code := strings.ToLower(c.Code)
-	for _, dept := range config.Departments {
-		if strings.HasPrefix(code, strings.ToLower(dept)) {
-			return dept
+// CatalogEntry is a course's listing in the calendar of an academic year.
+type CatalogEntry struct {
+	// Year is the year the academic session started in, the same as File.Year.
+	Year          int
+	Title         string `json:",omitempty"`
+	Desc          string `json:",omitempty"`
+	Credits       string `json:",omitempty"`
+	Prerequisites string `json:",omitempty"`
+}
+
+// LatestCatalog returns the most recent calendar listing of the course or nil
+// if it has no catalog history.
+func (c Course) LatestCatalog() *CatalogEntry {
+	if len(c.Catalog) == 0 {
+		return nil
+	}
+	return &c.Catalog[len(c.Catalog)-1]
+}
+
+// Active returns whether the course was listed in the calendar of the academic
+// year. Courses without catalog history are always active.
+func (c Course) Active(year int) bool {
+	if len(c.Catalog) == 0 {
+		return true
+	}
+	for _, e := range c.Catalog {
+		if e.Year == year {
+			return true
 		}
 	}
-	if strings.HasPrefix(c.Code, "cs") {
-		return config.ComputerScience
+	return false
+}
+
+// Predates returns whether the year is before the course was first listed in
+// the calendar. since is the year of the oldest calendar that was fetched, the
+// course might be older than it so years before it are never predated.
+func (c Course) Predates(year, since int) bool {
+	return len(c.Catalog) > 0 && year > 0 && year >= since && year < c.Catalog[0].Year
+}
+
+var courseCodeRegexp = regexp.MustCompile(`^([a-z]+)\s*-?\s*(\d+[a-z]*)$`)
+
+// CanonicalCourseCode returns the canonical form of the course code. Legacy
+// department aliases are replaced, so "cs110", "CPSC110" and "cpsc-110" are
+// all "cpsc 110". Codes that can't be parsed are only lower cased.
+func CanonicalCourseCode(cfg *config.Config, code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	matches := courseCodeRegexp.FindStringSubmatch(code)
+	if matches == nil {
+		return code
+	}
+	dept := matches[1]
+	if d := cfg.Department(dept); d != nil {
+		dept = strings.ToLower(d.Code)
 	}
-	return ""
+	return dept + " " + matches[2]
 }
 
-// AlternateIDs returns the possible ID formats.
-func (c Course) AlternateIDs() []string {
-	dept := c.Department()
-	number := c.Number()
-	if dept == "" || number < 0 {
-		return nil
+// Department returns the department code for the course.
+func (c Course) Department() string {
+	matches := courseCodeRegexp.FindStringSubmatch(strings.ToLower(strings.TrimSpace(c.Code)))
+	if matches == nil {
+		return ""
 	}
+	return strings.ToUpper(matches[1])
+}
 
-	ids := []string{
-		c.Code,
-		fmt.Sprintf("%s%d", dept, number),
-		fmt.Sprintf("%s-%d", dept, number),
+// InDepartment returns whether the course is in the department with the code
+// or alias dept. Every course is in the empty department.
+func (c Course) InDepartment(cfg *config.Config, dept string) bool {
+	if len(dept) == 0 {
+		return true
 	}
-	if dept == config.ComputerScience {
-		ids = append(ids, strconv.Itoa(number))
+	code := c.Department()
+	if len(code) == 0 {
+		return false
 	}
+	if strings.EqualFold(code, dept) {
+		return true
+	}
+	d := cfg.Department(dept)
+	return d != nil && cfg.Department(code) == d
+}
 
-	properFormat := fmt.Sprintf("%s %d", dept, number)
-	if !strings.EqualFold(c.Code, properFormat) {
-		ids = append(ids, properFormat)
+// AlternateIDs returns the possible ID formats using the course formats of
+// the course's department.
+func (c Course) AlternateIDs(dept *config.Department) []string {
+	number := c.Number()
+	if dept == nil || number < 0 {
+		return nil
 	}
 
-	for i, id := range ids {
-		ids[i] = strings.ToLower(id)
+	var ids []string
+	seen := map[string]bool{}
+	candidates := append([]string{strings.ToLower(c.Code)}, dept.CourseIDs(number)...)
+	candidates = append(candidates, strings.ToLower(fmt.Sprintf("%s %d", dept.Code, number)))
+	for _, id := range candidates {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
 	}
 	return ids
 }
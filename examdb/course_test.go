@@ -15,7 +15,8 @@ func TestCourseDepartment(t *testing.T) {
 		{Course{}, ""},
 		{Course{Code: "wat"}, ""},
 		{Course{Code: "CPSC 100"}, config.ComputerScience},
-		{Course{Code: "cs100"}, config.ComputerScience},
+		{Course{Code: "cpsc-100"}, config.ComputerScience},
+		{Course{Code: "cs100"}, "CS"},
 		{Course{Code: "MATH 100"}, config.Math},
 	}
 
@@ -27,6 +28,28 @@ func TestCourseDepartment(t *testing.T) {
 	}
 }
 
+func TestCanonicalCourseCode(t *testing.T) {
+	cfg := config.Default()
+	cases := []struct {
+		code, want string
+	}{
+		{"", ""},
+		{"wat", "wat"},
+		{"CPSC 110", "cpsc 110"},
+		{" cpsc-110 ", "cpsc 110"},
+		{"cs110", "cpsc 110"},
+		{"MATH649D", "math 649d"},
+		{"phys 101", "phys 101"},
+	}
+
+	for i, c := range cases {
+		out := CanonicalCourseCode(cfg, c.code)
+		if out != c.want {
+			t.Errorf("%d. CanonicalCourseCode(%q) = %q; not %q", i, c.code, out, c.want)
+		}
+	}
+}
+
 func TestCourseAlternateIDs(t *testing.T) {
 	cases := []struct {
 		c    Course
@@ -34,13 +57,13 @@ func TestCourseAlternateIDs(t *testing.T) {
 	}{
 		{Course{}, nil},
 		{Course{Code: "wat"}, nil},
-		{Course{Code: "CPSC 101"}, []string{"cpsc 101", "cpsc101", "cpsc-101", "101"}},
+		{Course{Code: "CPSC 101"}, []string{"cpsc 101", "cpsc101", "cpsc-101", "cs101", "101"}},
 		{Course{Code: "cs120"}, []string{"cs120", "cpsc120", "cpsc-120", "120", "cpsc 120"}},
 		{Course{Code: "MATH 649D"}, []string{"math 649d", "math649", "math-649", "math 649"}},
 	}
 
 	for i, c := range cases {
-		out := c.c.AlternateIDs()
+		out := c.c.AlternateIDs(config.Default().Department(c.c.Department()))
 		if !reflect.DeepEqual(out, c.want) {
 			t.Errorf("%d. %+v.AlternateIDs() = %q; not %q", i, c.c, out, c.want)
 		}
@@ -79,7 +102,7 @@ func TestCourseYearLevel(t *testing.T) {
 		{Course{Code: "wat"}, ""},
 		{Course{Code: "CPSC 100"}, "CPSC 100"},
 		{Course{Code: "CPSC 101"}, "CPSC 100"},
-		{Course{Code: "cs120"}, "CPSC 100"},
+		{Course{Code: "cs120"}, "CS 100"},
 		{Course{Code: "MATH 120"}, "MATH 100"},
 		{Course{Code: "MATH 649D"}, "MATH 600"},
 		{Course{Code: "MATH 6449D"}, "MATH 6400"},
@@ -92,3 +115,48 @@ func TestCourseYearLevel(t *testing.T) {
 		}
 	}
 }
+
+func TestCourseCatalog(t *testing.T) {
+	c := Course{Catalog: []CatalogEntry{{Year: 2005}, {Year: 2007, Title: "Latest"}}}
+	if latest := c.LatestCatalog(); latest == nil || latest.Title != "Latest" {
+		t.Errorf("LatestCatalog() = %+v", latest)
+	}
+	for year, want := range map[int]bool{2004: false, 2005: true, 2006: false, 2007: true} {
+		if out := c.Active(year); out != want {
+			t.Errorf("Active(%d) = %t; not %t", year, out, want)
+		}
+	}
+	for year, want := range map[int]bool{0: false, 1999: false, 2002: true, 2004: true, 2005: false, 2010: false} {
+		if out := c.Predates(year, 2002); out != want {
+			t.Errorf("Predates(%d, 2002) = %t; not %t", year, out, want)
+		}
+	}
+
+	var empty Course
+	if empty.LatestCatalog() != nil || !empty.Active(2000) || empty.Predates(2000, 0) {
+		t.Errorf("courses without catalog history should always be active")
+	}
+}
+
+func TestCourseInDepartment(t *testing.T) {
+	cfg := config.Default()
+	cases := []struct {
+		course, dept string
+		want         bool
+	}{
+		{"cpsc 110", "", true},
+		{"", "", true},
+		{"cpsc 110", "CPSC", true},
+		{"cs110", "cpsc", true},
+		{"cpsc 110", "cs", true},
+		{"math 100", "CPSC", false},
+		{"", "CPSC", false},
+		{"phys 100", "PHYS", true},
+		{"phys 100", "CPSC", false},
+	}
+	for i, c := range cases {
+		if out := (Course{Code: c.course}).InDepartment(cfg, c.dept); out != c.want {
+			t.Errorf("%d. Course{%q}.InDepartment(%q) = %t; not %t", i, c.course, c.dept, out, c.want)
+		}
+	}
+}
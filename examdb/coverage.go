@@ -0,0 +1,201 @@
+package examdb
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Coverage statuses of a course's exams of a kind in a term.
+const (
+	CoverageHave      = "have"
+	CoveragePotential = "potential"
+	CoverageMissing   = "missing"
+	// CoverageInactive is a year the course wasn't in the calendar for, so
+	// there's nothing to be missing. Years after the latest calendar we have
+	// are never inactive.
+	CoverageInactive = "inactive"
+)
+
+// Orders that the courses of a coverage report can be sorted in.
+const (
+	CoverageByLevel   = "level"
+	CoverageByCourse  = "course"
+	CoverageByMissing = "missing"
+)
+
+// CoverageOptions filters which courses, sessions and kinds of exams a
+// coverage report covers.
+type CoverageOptions struct {
+	// FromYear and ToYear are the inclusive range of session years. They
+	// default to the last ten years.
+	FromYear, ToYear int
+	// Terms default to the winter terms.
+	Terms []string
+	// Kinds default to finals and midterms.
+	Kinds []string
+	// Department only includes the courses of the department if set.
+	Department string
+	// Sort is one of CoverageByLevel (the default), CoverageByCourse and
+	// CoverageByMissing.
+	Sort string
+}
+
+// CoverageCell is the coverage of one kind of exam in one term of a course.
+type CoverageCell struct {
+	Year           int
+	Term           string
+	Kind           string
+	HandClassified int
+	Potential      int
+	Status         string
+}
+
+// CourseCoverage is the coverage of a single course.
+type CourseCoverage struct {
+	Course    string
+	YearLevel string
+	Cells     []CoverageCell
+	Have      int
+	Potential int
+	Missing   int
+}
+
+// CoverageReport says which exams we have for every course in every term
+// and which are missing.
+type CoverageReport struct {
+	FromYear, ToYear int
+	Terms            []string
+	Kinds            []string
+	Courses          []CourseCoverage
+}
+
+type coverageKey struct {
+	course, term, kind string
+	year               int
+}
+
+// Coverage returns which exams we have hand classified, which are only
+// potential files with inferred labels and which are missing for each course.
+func (db *Database) Coverage(opts CoverageOptions) *CoverageReport {
+	if opts.ToYear == 0 {
+		opts.ToYear = SessionFromDate(time.Now()).Year
+	}
+	if opts.FromYear == 0 {
+		opts.FromYear = opts.ToYear - 9
+	}
+	if len(opts.Terms) == 0 {
+		opts.Terms = []string{TermW1, TermW2}
+	}
+	if len(opts.Kinds) == 0 {
+		opts.Kinds = []string{KindFinal, KindMidterm}
+	}
+
+	db.Mu.RLock()
+	defer db.Mu.RUnlock()
+
+	have := map[coverageKey]int{}
+	potential := map[coverageKey]int{}
+	for _, f := range db.Files {
+		switch {
+		case f.NotAnExam:
+		case f.HandClassified:
+			have[coverageKey{f.Course, f.Term, f.Kind, f.Year}]++
+		case f.Inferred != nil && !f.Inferred.NotAnExam:
+			i := f.Inferred
+			potential[coverageKey{i.Course, i.Term, i.Kind, i.Year}]++
+		}
+	}
+
+	report := &CoverageReport{
+		FromYear: opts.FromYear,
+		ToYear:   opts.ToYear,
+		Terms:    opts.Terms,
+		Kinds:    opts.Kinds,
+	}
+	for code, c := range db.Courses {
+		if len(code) == 0 {
+			continue
+		}
+		if !c.InDepartment(db.Config, opts.Department) {
+			continue
+		}
+		cc := CourseCoverage{Course: code, YearLevel: c.YearLevel()}
+		for year := opts.FromYear; year <= opts.ToYear; year++ {
+			for _, term := range opts.Terms {
+				for _, kind := range opts.Kinds {
+					key := coverageKey{code, term, kind, year}
+					cell := CoverageCell{
+						Year:           year,
+						Term:           term,
+						Kind:           kind,
+						HandClassified: have[key],
+						Potential:      potential[key],
+					}
+					switch {
+					case cell.HandClassified > 0:
+						cell.Status = CoverageHave
+						cc.Have++
+					case cell.Potential > 0:
+						cell.Status = CoveragePotential
+						cc.Potential++
+					case !c.Active(year) && year <= c.LatestCatalog().Year:
+						cell.Status = CoverageInactive
+					default:
+						cell.Status = CoverageMissing
+						cc.Missing++
+					}
+					cc.Cells = append(cc.Cells, cell)
+				}
+			}
+		}
+		report.Courses = append(report.Courses, cc)
+	}
+
+	courses := report.Courses
+	sort.Slice(courses, func(i, j int) bool {
+		a, b := courses[i], courses[j]
+		switch opts.Sort {
+		case CoverageByMissing:
+			if a.Missing != b.Missing {
+				return a.Missing > b.Missing
+			}
+		case CoverageByCourse:
+		default:
+			if a.YearLevel != b.YearLevel {
+				return a.YearLevel < b.YearLevel
+			}
+		}
+		return a.Course < b.Course
+	})
+	return report
+}
+
+// WriteCSV writes the report with one row per course, term and kind.
+func (r *CoverageReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"course", "year_level", "year", "term", "kind", "hand_classified", "potential", "status"}); err != nil {
+		return err
+	}
+	for _, c := range r.Courses {
+		for _, cell := range c.Cells {
+			row := []string{
+				c.Course,
+				c.YearLevel,
+				strconv.Itoa(cell.Year),
+				cell.Term,
+				cell.Kind,
+				strconv.Itoa(cell.HandClassified),
+				strconv.Itoa(cell.Potential),
+				cell.Status,
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
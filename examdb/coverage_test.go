@@ -0,0 +1,82 @@
+package examdb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ubccsss/exams/config"
+)
+
+func TestCoverage(t *testing.T) {
+	db := &Database{
+		Config: config.Default(),
+		Courses: map[string]*Course{
+			"cpsc 310": {Code: "cpsc 310"},
+			"cpsc 110": {Code: "cpsc 110", Catalog: []CatalogEntry{{Year: 2015}}},
+			"math 100": {Code: "math 100"},
+		},
+		Files: []*File{
+			{Course: "cpsc 110", Year: 2015, Term: TermW1, Label: Label{Kind: KindFinal}, HandClassified: true},
+			{Course: "cpsc 110", Year: 2015, Term: TermW1, Label: Label{Kind: KindFinal}, HandClassified: true},
+			{Inferred: &File{Course: "cpsc 110", Year: 2015, Term: TermW2, Label: Label{Kind: KindFinal}}},
+			{Inferred: &File{Course: "cpsc 110", Year: 2015, Term: TermW1, Label: Label{Kind: KindMidterm}, NotAnExam: true}},
+			{Course: "cpsc 310", Year: 2016, Term: TermW1, Label: Label{Kind: KindFinal}, NotAnExam: true},
+		},
+	}
+
+	report := db.Coverage(CoverageOptions{
+		FromYear:   2014,
+		ToYear:     2016,
+		Terms:      []string{TermW1, TermW2},
+		Kinds:      []string{KindFinal},
+		Department: "cs",
+	})
+	if len(report.Courses) != 2 {
+		t.Fatalf("expected only CPSC courses; got %+v", report.Courses)
+	}
+
+	c := report.Courses[0]
+	if c.Course != "cpsc 110" || c.YearLevel != "CPSC 100" {
+		t.Fatalf("expected courses sorted by year level; got %q", c.Course)
+	}
+	want := []CoverageCell{
+		{Year: 2014, Term: TermW1, Kind: KindFinal, Status: CoverageInactive},
+		{Year: 2014, Term: TermW2, Kind: KindFinal, Status: CoverageInactive},
+		{Year: 2015, Term: TermW1, Kind: KindFinal, HandClassified: 2, Status: CoverageHave},
+		{Year: 2015, Term: TermW2, Kind: KindFinal, Potential: 1, Status: CoveragePotential},
+		{Year: 2016, Term: TermW1, Kind: KindFinal, Status: CoverageMissing},
+		{Year: 2016, Term: TermW2, Kind: KindFinal, Status: CoverageMissing},
+	}
+	if len(c.Cells) != len(want) {
+		t.Fatalf("cells = %+v; not %+v", c.Cells, want)
+	}
+	for i, cell := range c.Cells {
+		if cell != want[i] {
+			t.Errorf("cell %d = %+v; not %+v", i, cell, want[i])
+		}
+	}
+	if c.Have != 1 || c.Potential != 1 || c.Missing != 2 {
+		t.Errorf("counts = %d, %d, %d; not 1, 1, 2", c.Have, c.Potential, c.Missing)
+	}
+	if m := report.Courses[1].Missing; m != 6 {
+		t.Errorf("cpsc 310 missing = %d; not 6", m)
+	}
+
+	byMissing := db.Coverage(CoverageOptions{FromYear: 2014, ToYear: 2016, Department: "CPSC", Sort: CoverageByMissing})
+	if byMissing.Courses[0].Course != "cpsc 310" {
+		t.Errorf("expected the course missing the most first; got %q", byMissing.Courses[0].Course)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 13 {
+		t.Fatalf("expected a header and 12 rows; got %d lines", len(lines))
+	}
+	if lines[3] != "cpsc 110,CPSC 100,2015,W1,final,2,0,have" {
+		t.Errorf("row = %q", lines[3])
+	}
+}
@@ -19,6 +19,9 @@ import (
 
 // Database stores all of the courses and files.
 type Database struct {
+	// SchemaVersion is the version of the schema the database was last
+	// migrated to.
+	SchemaVersion int `json:",omitempty"`
 	// Courses should not be accessed without locking Mu.
 	Courses map[string]*Course `json:",omitempty"`
 	// Files should not be accessed without locking Mu.
@@ -29,13 +32,18 @@ type Database struct {
 
 	UnprocessedSources   []*File      `json:",omitempty"`
 	UnprocessedSourcesMu sync.RWMutex `json:"-"`
+
+	// Config is where the files are stored and which courses are displayed.
+	Config *config.Config `json:"-"`
 }
 
 // MakeDatabase makes a new database.
-func MakeDatabase() *Database {
+func MakeDatabase(cfg *config.Config) *Database {
 	return &Database{
-		Courses:      map[string]*Course{},
-		SourceHashes: map[string]string{},
+		Config:        cfg,
+		SchemaVersion: SchemaVersion,
+		Courses:       map[string]*Course{},
+		SourceHashes:  map[string]string{},
 	}
 }
 
@@ -44,8 +52,11 @@ func (db *Database) CoursesNoFiles() []string {
 	var classes []string
 	for id, count := range db.CourseFileCount() {
 		if count.HandClassified == 0 {
-			c := db.Courses[id]
-			classes = append(classes, c.AlternateIDs()...)
+			c, ok := db.Courses[id]
+			if !ok {
+				continue
+			}
+			classes = append(classes, c.AlternateIDs(db.Config.Department(c.Department()))...)
 		}
 	}
 	return classes
@@ -167,7 +178,7 @@ func (db *Database) ProcessedFiles() []*File {
 	return files
 }
 
-// DisplayCourses returns all course codes that should be displayed in
+// DisplayCourses returns all course codes of public departments in
 // alphabetical order.
 func (db *Database) DisplayCourses() []string {
 	db.Mu.RLock()
@@ -175,7 +186,7 @@ func (db *Database) DisplayCourses() []string {
 
 	var courses []string
 	for code, c := range db.Courses {
-		if config.DisplayDepartment[c.Department()] {
+		if db.Config.PublicDepartment(c.Department()) {
 			courses = append(courses, code)
 		}
 	}
@@ -231,15 +242,6 @@ func AllYears(files []*File) []int {
 	return years
 }
 
-func validFileName(name string) bool {
-	for _, label := range ExamLabels {
-		if name == label {
-			return true
-		}
-	}
-	return false
-}
-
 // NeedFixReasons contains a file and the reasons it needs to be fixed.
 type NeedFixReasons struct {
 	File    *File
@@ -255,6 +257,9 @@ func (db *Database) NeedFix() []NeedFixReasons {
 	var reasons NeedFixReasons
 	for _, f := range db.Files {
 		if !f.HandClassified {
+			if f.Inferred != nil && len(f.Inferred.Review) > 0 {
+				files = append(files, NeedFixReasons{File: f, Reasons: []string{f.Inferred.Review}})
+			}
 			continue
 		}
 
@@ -263,8 +268,8 @@ func (db *Database) NeedFix() []NeedFixReasons {
 			Reasons: nil,
 		}
 
-		if !validFileName(f.Name) {
-			reasons.Reasons = append(reasons.Reasons, "invalid file name")
+		if reason := labelFixReason(f); len(reason) > 0 {
+			reasons.Reasons = append(reasons.Reasons, reason)
 		}
 		if f.Term == "" {
 			reasons.Reasons = append(reasons.Reasons, "missing term")
@@ -280,16 +285,35 @@ func (db *Database) NeedFix() []NeedFixReasons {
 	return files
 }
 
+// OldestCatalogYear returns the year of the oldest calendar that a course was
+// listed in or 0 if there's no catalog history.
+func (db *Database) OldestCatalogYear() int {
+	db.Mu.RLock()
+	defer db.Mu.RUnlock()
+
+	oldest := 0
+	for _, c := range db.Courses {
+		if len(c.Catalog) > 0 && (oldest == 0 || c.Catalog[0].Year < oldest) {
+			oldest = c.Catalog[0].Year
+		}
+	}
+	return oldest
+}
+
 // AddCourse adds a course the DB if it doesn't exist already.
 func (db *Database) AddCourse(w io.Writer, code, desc string) {
 	db.Mu.Lock()
 	defer db.Mu.Unlock()
 
-	code = strings.ToLower(strings.TrimSpace(code))
+	code = CanonicalCourseCode(db.Config, code)
 	if c, ok := db.Courses[code]; ok {
 		c.Desc = desc
 		return
 	}
+	// Don't add aliases of existing courses as separate courses.
+	if _, ok := db.resolveCourseLocked(code); ok {
+		return
+	}
 	if db.Courses == nil {
 		db.Courses = map[string]*Course{}
 	}
@@ -297,6 +321,40 @@ func (db *Database) AddCourse(w io.Writer, code, desc string) {
 	fmt.Fprintf(w, "Added: %s\n", code)
 }
 
+// AddCatalogEntry records the calendar listing of a course, replacing any
+// listing from the same year. The course is added if it doesn't exist and its
+// description is set from the latest listing.
+func (db *Database) AddCatalogEntry(w io.Writer, code string, entry CatalogEntry) {
+	db.Mu.Lock()
+	defer db.Mu.Unlock()
+
+	code, ok := db.resolveCourseLocked(code)
+	if !ok {
+		if db.Courses == nil {
+			db.Courses = map[string]*Course{}
+		}
+		db.Courses[code] = &Course{Code: code}
+		fmt.Fprintf(w, "Added: %s\n", code)
+	}
+	c := db.Courses[code]
+
+	var catalog []CatalogEntry
+	for _, e := range c.Catalog {
+		if e.Year != entry.Year {
+			catalog = append(catalog, e)
+		}
+	}
+	catalog = append(catalog, entry)
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].Year < catalog[j].Year
+	})
+	c.Catalog = catalog
+
+	if latest := c.LatestCatalog(); len(latest.Title) > 0 {
+		c.Desc = latest.Title
+	}
+}
+
 // AddFile adds a file to the database.
 func (db *Database) AddFile(f *File) error {
 	db.Mu.Lock()
@@ -306,12 +364,13 @@ func (db *Database) AddFile(f *File) error {
 }
 
 func (db *Database) addFileLocked(f *File) error {
+	f.Course, _ = db.resolveCourseLocked(f.Course)
 	course := f.Course
 	if _, ok := db.Courses[course]; !ok {
 		db.Courses[course] = &Course{Code: course}
 	}
 
-	if err := f.ComputeHash(); err != nil {
+	if err := f.ComputeHash(db.Config); err != nil {
 		return err
 	}
 
@@ -319,6 +378,10 @@ func (db *Database) addFileLocked(f *File) error {
 	if found == nil {
 		db.Files = append(db.Files, f)
 	} else {
+		// Keep when the file was first classified when it's added again.
+		if found.HandClassified && !found.Classified.IsZero() {
+			f.Classified = found.Classified
+		}
 		*found = *f
 	}
 
@@ -382,17 +445,18 @@ func (db *Database) AddPotentialFiles(w io.Writer, files []*File) {
 // FetchFileAndSave fetches the file and saves it to a directory.
 func (db *Database) FetchFileAndSave(file *File) error {
 	log.Printf("Fetching %q", file.Source)
-	resp, err := file.Reader()
+	resp, err := file.Reader(db.Config)
 	if err != nil {
 		return err
 	}
 	defer resp.Close()
+	file.Course, _ = db.ResolveCourse(file.Course)
 	filename := file.Source
 	if len(file.Source) == 0 {
 		filename = file.Path
 	}
 	dir := file.IdealDir()
-	if err := os.MkdirAll(path.Join(config.ExamsDir, dir), 0755); err != nil {
+	if err := os.MkdirAll(path.Join(db.Config.ExamsDir, dir), 0755); err != nil {
 		return err
 	}
 	attempt := path.Base(filename)
@@ -401,12 +465,12 @@ func (db *Database) FetchFileAndSave(file *File) error {
 			attempt = incrementFileName(attempt)
 		}
 		file.Path = path.Join(dir, attempt)
-		if _, err := os.Stat(file.PathOnDisk()); !os.IsNotExist(err) {
+		if _, err := os.Stat(file.PathOnDisk(db.Config)); !os.IsNotExist(err) {
 			f2 := db.FindFileByPath(file.Path)
 			if f2 == nil || f2.Hash != file.Hash {
 				// One final check in case something became inconsistent.
 				f3 := File{Path: file.Path}
-				if err := f3.ComputeHash(); err != nil {
+				if err := f3.ComputeHash(db.Config); err != nil {
 					return err
 				}
 				if f3.Hash != file.Hash {
@@ -417,7 +481,7 @@ func (db *Database) FetchFileAndSave(file *File) error {
 		break
 	}
 	raw, _ := ioutil.ReadAll(resp)
-	if err := ioutil.WriteFile(file.PathOnDisk(), raw, 0755); err != nil {
+	if err := ioutil.WriteFile(file.PathOnDisk(db.Config), raw, 0755); err != nil {
 		return err
 	}
 	return db.AddFile(file)
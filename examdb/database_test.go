@@ -8,6 +8,9 @@ import (
 	"sort"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/ubccsss/exams/config"
 )
 
 var testFiles struct {
@@ -61,7 +64,7 @@ It is created automatically during tests and should be removed automatically as
 func TestAddFile(t *testing.T) {
 	defer cleanupTestFiles(t)
 
-	db := MakeDatabase()
+	db := MakeDatabase(config.Default())
 	a := testFile(t)
 	b := testFile(t)
 	c := testFile(t)
@@ -97,6 +100,32 @@ func TestAddFile(t *testing.T) {
 	}
 }
 
+func TestAddFileKeepsClassified(t *testing.T) {
+	defer cleanupTestFiles(t)
+
+	db := MakeDatabase(config.Default())
+	a := testFile(t)
+	a.MarkClassified()
+	if err := db.AddFile(a); err != nil {
+		t.Fatal(err)
+	}
+	want := a.Classified
+
+	b := *a
+	b.Classified = time.Time{}
+	b.MarkClassified()
+	if err := db.AddFile(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.Files) != 1 {
+		t.Fatalf("expected 1 file; got %d", len(db.Files))
+	}
+	if got := db.Files[0].Classified; !got.Equal(want) {
+		t.Errorf("re-adding a file should keep the classified time. got %s; want %s", got, want)
+	}
+}
+
 func TestIncrementFileName(t *testing.T) {
 	cases := []struct {
 		name string
@@ -122,12 +151,14 @@ func TestDisplayCourses(t *testing.T) {
 	}{
 		{
 			&Database{
+				Config:  config.Default(),
 				Courses: map[string]*Course{},
 			},
 			nil,
 		},
 		{
 			&Database{
+				Config: config.Default(),
 				Courses: map[string]*Course{
 					"cpsc 120": {
 						Code: "cpsc 120",
@@ -138,10 +169,13 @@ func TestDisplayCourses(t *testing.T) {
 					"math 100": {
 						Code: "math 100",
 					},
+					"phys 100": {
+						Code: "phys 100",
+					},
 					"": {},
 				},
 			},
-			[]string{"cpsc 101", "cpsc 120"},
+			[]string{"cpsc 101", "cpsc 120", "math 100"},
 		},
 	}
 	for i, c := range cases {
@@ -151,3 +185,46 @@ func TestDisplayCourses(t *testing.T) {
 		}
 	}
 }
+
+func TestAddCatalogEntry(t *testing.T) {
+	db := &Database{
+		Config: config.Default(),
+		Courses: map[string]*Course{
+			"cpsc 259": {Code: "cpsc 259", Desc: "Old", Aliases: []string{"eece 259"}},
+		},
+	}
+	db.AddCatalogEntry(ioutil.Discard, "CPSC 259", CatalogEntry{Year: 2016, Title: "Data Structures and Algorithms for Electrical Engineering"})
+	db.AddCatalogEntry(ioutil.Discard, "EECE 259", CatalogEntry{Year: 2010, Title: "Introduction to Microcomputers"})
+	db.AddCatalogEntry(ioutil.Discard, "cpsc 259", CatalogEntry{Year: 2016, Title: "Data Structures and Algorithms for Electrical Engineering", Credits: "4"})
+	db.AddCatalogEntry(ioutil.Discard, "cs110", CatalogEntry{Year: 2012, Title: "Computation, Programs, and Programming"})
+
+	c := db.Courses["cpsc 259"]
+	if len(c.Catalog) != 2 || c.Catalog[0].Year != 2010 || c.Catalog[1].Credits != "4" {
+		t.Errorf("catalog = %+v", c.Catalog)
+	}
+	if c.Desc != "Data Structures and Algorithms for Electrical Engineering" {
+		t.Errorf("Desc = %q; expected the latest title", c.Desc)
+	}
+	if _, ok := db.Courses["eece 259"]; ok {
+		t.Errorf("entries for aliases should be added to the course")
+	}
+	if c, ok := db.Courses["cpsc 110"]; !ok || c.Desc != "Computation, Programs, and Programming" {
+		t.Errorf("expected course to be added; got %+v", c)
+	}
+	if oldest := db.OldestCatalogYear(); oldest != 2010 {
+		t.Errorf("OldestCatalogYear() = %d; not 2010", oldest)
+	}
+}
+
+func TestNeedFixInferredReview(t *testing.T) {
+	db := &Database{
+		Files: []*File{
+			{Hash: "a", Inferred: &File{Course: "cpsc 110", Year: 2003, Review: "too old"}},
+			{Hash: "b", Inferred: &File{Course: "cpsc 110", Year: 2016}},
+		},
+	}
+	reasons := db.NeedFix()
+	if len(reasons) != 1 || reasons[0].File != db.Files[0] || reasons[0].Reasons[0] != "too old" {
+		t.Errorf("expected only the file flagged for review to need fixing; got %+v", reasons)
+	}
+}
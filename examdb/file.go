@@ -22,32 +22,18 @@ const (
 	TermW1      = "W1"
 	TermW2      = "W2"
 	TermS       = "S"
+	TermS1      = "S1"
+	TermS2      = "S2"
 	TermUnknown = "unknown"
 )
 
 var (
-	// ExamLabels are all the possible labels that a file can fall under.
-	ExamLabels = []string{
-		"Final",
-		"Final (Solution)",
-		"Sample Final",
-		"Sample Final (Solution)",
-		"Midterm",
-		"Midterm (Solution)",
-		"Sample Midterm",
-		"Sample Midterm (Solution)",
-		"Midterm 1",
-		"Midterm 1 (Solution)",
-		"Sample Midterm 1",
-		"Sample Midterm 1 (Solution)",
-		"Midterm 2",
-		"Midterm 2 (Solution)",
-		"Sample Midterm 2",
-		"Sample Midterm 2 (Solution)",
-	}
+	// ExamLabels are the names of the common labels that a file can fall
+	// under.
+	ExamLabels = examLabels()
 
 	// ExamTerms are all the possible terms that a file can fall under.
-	ExamTerms = []string{TermW1, TermW2, TermS, TermUnknown}
+	ExamTerms = []string{TermW1, TermW2, TermS, TermS1, TermS2, TermUnknown}
 
 	// FileNameScoreRegexes are a list of regexps and values that can be used to
 	// rank files based on how likely they are an exam.
@@ -59,7 +45,11 @@ var (
 
 // File is a single exam file typically a PDF.
 type File struct {
-	Name           string    `json:",omitempty"`
+	// Name is the display name of the file. For files with a label it's
+	// derived from the label.
+	Name string `json:",omitempty"`
+	Label
+
 	Path           string    `json:",omitempty"`
 	Source         string    `json:",omitempty"`
 	Hash           string    `json:",omitempty"`
@@ -70,20 +60,34 @@ type File struct {
 	Year           int       `json:",omitempty"`
 	HandClassified bool      `json:",omitempty"`
 	Updated        time.Time `json:",omitempty"`
+	// Classified is when the file was first hand classified.
+	Classified time.Time `json:",omitempty"`
 
 	LastResponseCode int `json:",omitempty"`
 
+	// Referrer is the page the file was found linked from and AnchorText is
+	// the text of that link. Heading is the closest heading before the link
+	// and RowText is the table row or list item it's in. They help infer the
+	// course, term and type.
+	Referrer   string `json:",omitempty"`
+	AnchorText string `json:",omitempty"`
+	Heading    string `json:",omitempty"`
+	RowText    string `json:",omitempty"`
+
 	// Inferred is the results that are inferred via ML.
 	Inferred *File `json:",omitempty"`
+	// Review is why inferred results need to be checked by hand, e.g. a year
+	// before the course was first listed in the calendar.
+	Review string `json:",omitempty"`
 }
 
 // PathOnDisk returns the path to the file on disk.
-func (f File) PathOnDisk() string {
+func (f File) PathOnDisk(cfg *config.Config) string {
 	// Only for tests.
 	if filepath.IsAbs(f.Path) && strings.HasPrefix(f.Path, os.TempDir()) {
 		return f.Path
 	}
-	return path.Join(config.ExamsDir, f.Path)
+	return path.Join(cfg.ExamsDir, f.Path)
 }
 
 // IsPotential returns whether the file has been processed yet.
@@ -91,13 +95,22 @@ func (f File) IsPotential() bool {
 	return !(f.NotAnExam || f.HandClassified)
 }
 
+// MarkClassified marks the file as hand classified and records when that
+// first happened.
+func (f *File) MarkClassified() {
+	f.HandClassified = true
+	if f.Classified.IsZero() {
+		f.Classified = time.Now()
+	}
+}
+
 // Reader opens the file either over HTTP or from disk and returns an
 // io.ReadCloser which needs to be closed by the caller.
-func (f *File) Reader() (io.ReadCloser, error) {
+func (f *File) Reader(cfg *config.Config) (io.ReadCloser, error) {
 	var source io.ReadCloser
 	if len(f.Path) > 0 {
 		var err error
-		source, err = os.Open(f.PathOnDisk())
+		source, err = os.Open(f.PathOnDisk(cfg))
 		if err != nil {
 			return nil, err
 		}
@@ -123,14 +136,14 @@ func (f File) String() string {
 }
 
 // ComputeHash hashes the document and then saves it to f.Hash.
-func (f *File) ComputeHash() error {
+func (f *File) ComputeHash(cfg *config.Config) error {
 	hasher := sha1.New()
-	source, err := f.Reader()
+	source, err := f.Reader(cfg)
 	if err != nil {
 		return err
 	}
 	defer source.Close()
-	if _, err := io.Copy(hasher, io.LimitReader(source, config.MaxFileSize)); err != nil {
+	if _, err := io.Copy(hasher, io.LimitReader(source, cfg.MaxFileSize)); err != nil {
 		return err
 	}
 	f.Hash = hex.EncodeToString(hasher.Sum(nil))
@@ -139,7 +152,7 @@ func (f *File) ComputeHash() error {
 
 // ComputeScore computes the rank for f and stores it f.Score.
 func (f *File) ComputeScore(db *Database) float64 {
-	path := strings.ToLower(f.Source)
+	path := strings.ToLower(strings.Join([]string{f.Source, f.AnchorText, f.RowText}, " "))
 	var score int
 	for _, r := range db.CoursesNoFiles() {
 		if util.RegexpMatch(r, path) {
@@ -168,6 +181,37 @@ func (f File) IdealDir() string {
 	return fmt.Sprintf("%s/%d", f.Course, f.Year)
 }
 
+// InIdealDir returns whether the file is in its ideal directory or in the
+// directory of one of its course's aliases, e.g. a file of "cpsc 110" that was
+// published in "cs110/2016" before the course code was canonicalized. Those
+// files are left where they are so their URLs keep working.
+func (db *Database) InIdealDir(f *File) bool {
+	db.Mu.RLock()
+	defer db.Mu.RUnlock()
+
+	return db.inIdealDirLocked(f)
+}
+
+func (db *Database) inIdealDirLocked(f *File) bool {
+	dir := f.IdealDir()
+	if strings.HasPrefix(f.Path, dir+"/") {
+		return true
+	}
+	if f.NotAnExam || f.IsPotential() {
+		return false
+	}
+	c, ok := db.Courses[f.Course]
+	if !ok {
+		return false
+	}
+	for _, alias := range c.Aliases {
+		if strings.HasPrefix(f.Path, fmt.Sprintf("%s/%d/", alias, f.Year)) {
+			return true
+		}
+	}
+	return false
+}
+
 // FileSlice attaches the methods of sort.Interface to []*File, sorting in increasing order.
 type FileSlice []*File
 
@@ -197,9 +241,11 @@ type FileByTerm []*File
 func (p FileByTerm) Len() int { return len(p) }
 
 var termOrder = map[string]int{
-	"W1": -3,
-	"W2": -2,
-	"S":  -1,
+	TermW1: -5,
+	TermW2: -4,
+	TermS:  -3,
+	TermS1: -2,
+	TermS2: -1,
 }
 
 func (p FileByTerm) Less(i, j int) bool {
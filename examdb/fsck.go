@@ -0,0 +1,329 @@
+package examdb
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of issues found by Fsck.
+const (
+	IssueMissingFile   = "missing-file"
+	IssueHashMismatch  = "hash-mismatch"
+	IssueWrongDir      = "wrong-dir"
+	IssueDuplicateHash = "duplicate-hash"
+	IssueDuplicatePath = "duplicate-path"
+	IssueMissingCourse = "missing-course"
+	IssueOrphanFile    = "orphan-file"
+	IssueInvalidTerm   = "invalid-term"
+	IssueInvalidYear   = "invalid-year"
+)
+
+// minExamYear is the earliest year that an exam can plausibly be from.
+const minExamYear = 1950
+
+// Issue is a single invariant that doesn't hold.
+type Issue struct {
+	Kind     string
+	Hash     string `json:",omitempty"`
+	Path     string `json:",omitempty"`
+	Detail   string
+	Repaired bool `json:",omitempty"`
+}
+
+func (i Issue) String() string {
+	var bits []string
+	if i.Repaired {
+		bits = append(bits, "[repaired]")
+	}
+	bits = append(bits, i.Kind)
+	if len(i.Hash) > 0 {
+		bits = append(bits, i.Hash)
+	}
+	if len(i.Path) > 0 {
+		bits = append(bits, fmt.Sprintf("%q", i.Path))
+	}
+	bits = append(bits, i.Detail)
+	return strings.Join(bits, " ")
+}
+
+// FsckReport is the result of checking the database.
+type FsckReport struct {
+	Files    int
+	Issues   []Issue
+	Repaired int
+}
+
+// Unrepaired returns the number of issues that still need to be fixed.
+func (r FsckReport) Unrepaired() int {
+	return len(r.Issues) - r.Repaired
+}
+
+// FsckOptions configures Fsck.
+type FsckOptions struct {
+	// Repair fixes issues that are safe to fix automatically: files in the
+	// wrong directory are moved, missing courses are created, duplicate entries
+	// are dropped, terms are normalized, orphaned copies of verified files are
+	// removed and files missing from their path are pointed at an orphaned
+	// copy.
+	Repair bool
+}
+
+// Fsck checks every invariant of the database and the files on disk.
+func (db *Database) Fsck(opts FsckOptions) (*FsckReport, error) {
+	if opts.Repair {
+		db.Mu.Lock()
+		defer db.Mu.Unlock()
+	} else {
+		db.Mu.RLock()
+		defer db.Mu.RUnlock()
+	}
+
+	c := fsckChecker{
+		db:       db,
+		opts:     opts,
+		report:   &FsckReport{Files: len(db.Files)},
+		verified: map[string]bool{},
+		missing:  map[string]int{},
+	}
+	c.checkFiles()
+	if err := c.checkOrphans(); err != nil {
+		return nil, err
+	}
+	for _, issue := range c.report.Issues {
+		if issue.Repaired {
+			c.report.Repaired++
+		}
+	}
+	return c.report, nil
+}
+
+type fsckChecker struct {
+	db     *Database
+	opts   FsckOptions
+	report *FsckReport
+	// verified is the paths of the files whose hash matched on disk.
+	verified map[string]bool
+	// missing maps the hashes of files that don't exist on disk to their
+	// missing-file issue.
+	missing map[string]int
+}
+
+func (c *fsckChecker) add(issue Issue) {
+	c.report.Issues = append(c.report.Issues, issue)
+}
+
+func (c *fsckChecker) checkFiles() {
+	hashes := map[string]*File{}
+	paths := map[string]*File{}
+	maxYear := time.Now().Year() + 1
+
+	var files []*File
+	for _, f := range c.db.Files {
+		if first, ok := hashes[f.Hash]; ok {
+			issue := Issue{Kind: IssueDuplicateHash, Hash: f.Hash, Path: f.Path, Detail: fmt.Sprintf("same hash as %q", first.Path)}
+			if c.opts.Repair && (f.Path == first.Path || len(f.Path) == 0) {
+				issue.Repaired = true
+				c.add(issue)
+				continue
+			}
+			c.add(issue)
+		} else {
+			hashes[f.Hash] = f
+		}
+		files = append(files, f)
+
+		if _, ok := c.db.Courses[f.Course]; len(f.Course) > 0 && !ok {
+			issue := Issue{Kind: IssueMissingCourse, Hash: f.Hash, Path: f.Path, Detail: fmt.Sprintf("course %q doesn't exist", f.Course)}
+			if c.opts.Repair {
+				c.db.Courses[f.Course] = &Course{Code: f.Course}
+				issue.Repaired = true
+			}
+			c.add(issue)
+		}
+
+		if len(f.Term) > 0 && !validTerm(f.Term) {
+			issue := Issue{Kind: IssueInvalidTerm, Hash: f.Hash, Path: f.Path, Detail: fmt.Sprintf("term %q", f.Term)}
+			if term := strings.ToUpper(strings.TrimSpace(f.Term)); c.opts.Repair && validTerm(term) {
+				f.Term = term
+				issue.Repaired = true
+			}
+			c.add(issue)
+		}
+
+		if f.Year != 0 && (f.Year < minExamYear || f.Year > maxYear) {
+			c.add(Issue{Kind: IssueInvalidYear, Hash: f.Hash, Path: f.Path, Detail: fmt.Sprintf("year %d", f.Year)})
+		}
+
+		// Unfetched files only have a source.
+		if len(f.Path) == 0 {
+			continue
+		}
+
+		if first, ok := paths[f.Path]; ok {
+			c.add(Issue{Kind: IssueDuplicatePath, Hash: f.Hash, Path: f.Path, Detail: fmt.Sprintf("same path as %s", first.Hash)})
+		} else {
+			paths[f.Path] = f
+		}
+
+		hash, err := hashFile(f.PathOnDisk(c.db.Config), c.db.Config.MaxFileSize)
+		if os.IsNotExist(err) {
+			c.missing[f.Hash] = len(c.report.Issues)
+			c.add(Issue{Kind: IssueMissingFile, Hash: f.Hash, Path: f.Path, Detail: "file doesn't exist on disk"})
+			continue
+		} else if err != nil {
+			c.add(Issue{Kind: IssueMissingFile, Hash: f.Hash, Path: f.Path, Detail: err.Error()})
+			continue
+		}
+		if hash != f.Hash {
+			c.add(Issue{Kind: IssueHashMismatch, Hash: f.Hash, Path: f.Path, Detail: fmt.Sprintf("file on disk has hash %s", hash)})
+		}
+		verified := hash == f.Hash
+
+		if !c.db.inIdealDirLocked(f) {
+			issue := Issue{Kind: IssueWrongDir, Hash: f.Hash, Path: f.Path, Detail: fmt.Sprintf("should be in %q", f.IdealDir())}
+			if c.opts.Repair && paths[f.Path] == f {
+				if err := c.moveToIdealDir(f); err != nil {
+					issue.Detail += fmt.Sprintf(": %s", err)
+				} else {
+					issue.Detail += fmt.Sprintf(": moved to %q", f.Path)
+					issue.Repaired = true
+				}
+			}
+			c.add(issue)
+		}
+		// Moving changes the path so it's recorded last.
+		if verified {
+			c.verified[f.Path] = true
+		}
+	}
+	if c.opts.Repair {
+		c.db.Files = files
+	}
+}
+
+// moveToIdealDir moves the file on disk into its ideal directory and updates
+// its path.
+func (c *fsckChecker) moveToIdealDir(f *File) error {
+	p := path.Join(f.IdealDir(), path.Base(f.Path))
+	moved := File{Path: p}
+	if _, err := os.Stat(moved.PathOnDisk(c.db.Config)); !os.IsNotExist(err) {
+		return errors.Errorf("%q already exists", p)
+	}
+	if err := os.MkdirAll(path.Dir(moved.PathOnDisk(c.db.Config)), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(f.PathOnDisk(c.db.Config), moved.PathOnDisk(c.db.Config)); err != nil {
+		return err
+	}
+	f.Path = p
+	return nil
+}
+
+// checkOrphans finds PDFs on disk that aren't in the database. Orphans are
+// only removed if they're copies of a file that was verified on disk, and
+// files that are missing from their path are pointed at an orphaned copy,
+// e.g. if they were moved by hand.
+func (c *fsckChecker) checkOrphans() error {
+	paths := map[string]bool{}
+	hashes := map[string]*File{}
+	for _, f := range c.db.Files {
+		if len(f.Path) > 0 {
+			paths[f.Path] = true
+			hashes[f.Hash] = f
+		}
+	}
+
+	var orphans []string
+	err := filepath.Walk(c.db.Config.ExamsDir, func(fp string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.Contains(strings.ToLower(info.Name()), ".pdf") {
+			return nil
+		}
+		rel, err := filepath.Rel(c.db.Config.ExamsDir, fp)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !paths[rel] {
+			orphans = append(orphans, rel)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	sort.Strings(orphans)
+
+	for _, orphan := range orphans {
+		fp := path.Join(c.db.Config.ExamsDir, orphan)
+		hash, err := hashFile(fp, c.db.Config.MaxFileSize)
+		if err != nil {
+			return err
+		}
+		issue := Issue{Kind: IssueOrphanFile, Hash: hash, Path: orphan, Detail: "not in database"}
+		original, ok := hashes[hash]
+		switch {
+		case !ok:
+		case c.verified[original.Path]:
+			issue.Detail = fmt.Sprintf("duplicate of %q", original.Path)
+			if c.opts.Repair {
+				if err := os.Remove(fp); err != nil {
+					return err
+				}
+				issue.Repaired = true
+			}
+		default:
+			i, missing := c.missing[hash]
+			if !missing {
+				issue.Detail = fmt.Sprintf("copy of %q, which doesn't match its hash", original.Path)
+				break
+			}
+			issue.Detail = fmt.Sprintf("copy of missing %q", original.Path)
+			if c.opts.Repair {
+				c.report.Issues[i].Detail += fmt.Sprintf(": found at %q", orphan)
+				c.report.Issues[i].Repaired = true
+				original.Path = orphan
+				c.verified[orphan] = true
+				delete(c.missing, hash)
+				issue.Repaired = true
+			}
+		}
+		c.add(issue)
+	}
+	return nil
+}
+
+func validTerm(term string) bool {
+	for _, t := range ExamTerms {
+		if term == t {
+			return true
+		}
+	}
+	return false
+}
+
+// hashFile returns the hash of the file the same way as File.ComputeHash.
+func hashFile(fp string, maxSize int64) (string, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hasher := sha1.New()
+	if _, err := io.Copy(hasher, io.LimitReader(f, maxSize)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
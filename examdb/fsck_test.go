@@ -0,0 +1,192 @@
+package examdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ubccsss/exams/config"
+)
+
+func writeExamFile(t *testing.T, dir, p, content string) {
+	fp := path.Join(dir, p)
+	if err := os.MkdirAll(path.Dir(fp), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFsck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "examdb-fsck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.Default()
+	cfg.ExamsDir = dir
+
+	writeExamFile(t, dir, "cs110/2016/good.pdf", "good")
+	writeExamFile(t, dir, "cs110/misplaced.pdf", "misplaced")
+	writeExamFile(t, dir, "cs110/2016/changed.pdf", "changed")
+	writeExamFile(t, dir, "cs110/2016/copy.pdf", "good")
+	writeExamFile(t, dir, "cs110/2016/unknown.pdf", "unknown")
+
+	good := &File{Path: "cs110/2016/good.pdf", Course: "cs110", Year: 2016, Term: TermW1, HandClassified: true}
+	misplaced := &File{Path: "cs110/misplaced.pdf", Course: "cs110", Year: 2016, Term: "w2 ", HandClassified: true}
+	changed := &File{Path: "cs110/2016/changed.pdf", Course: "cs110", Year: 2016, Term: TermS, HandClassified: true, Hash: "stale"}
+	missing := &File{Path: "cs999/1900/missing.pdf", Course: "cs999", Year: 1900, Term: TermS, HandClassified: true, Hash: "missing"}
+	for _, f := range []*File{good, misplaced} {
+		if err := f.ComputeHash(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	duplicate := *good
+
+	db := MakeDatabase(cfg)
+	db.Courses["cs110"] = &Course{Code: "cs110"}
+	db.Files = []*File{good, misplaced, changed, missing, &duplicate}
+
+	report, err := db.Fsck(FsckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]int{}
+	for _, issue := range report.Issues {
+		if issue.Repaired {
+			t.Errorf("issue shouldn't be repaired without repair: %s", issue)
+		}
+		kinds[issue.Kind]++
+	}
+	want := map[string]int{
+		IssueDuplicateHash: 1,
+		IssueDuplicatePath: 1,
+		IssueMissingCourse: 1,
+		IssueInvalidTerm:   1,
+		IssueInvalidYear:   1,
+		IssueMissingFile:   1,
+		IssueHashMismatch:  1,
+		IssueWrongDir:      1,
+		IssueOrphanFile:    2,
+	}
+	for kind, n := range want {
+		if kinds[kind] != n {
+			t.Errorf("expected %d %s issues; got %d: %+v", n, kind, kinds[kind], report.Issues)
+		}
+	}
+	if len(db.Files) != 5 {
+		t.Errorf("checking shouldn't modify the database")
+	}
+
+	report, err = db.Fsck(FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Repaired != 5 {
+		t.Errorf("expected 5 repaired issues; got %d: %+v", report.Repaired, report.Issues)
+	}
+	if len(db.Files) != 4 {
+		t.Errorf("expected duplicate entry to be dropped; got %d files", len(db.Files))
+	}
+	if misplaced.Path != "cs110/2016/misplaced.pdf" || misplaced.Term != TermW2 {
+		t.Errorf("expected misplaced file to be moved and term normalized; got %q %q", misplaced.Path, misplaced.Term)
+	}
+	if _, ok := db.Courses["cs999"]; !ok {
+		t.Errorf("expected missing course to be created")
+	}
+	if _, err := os.Stat(path.Join(dir, "cs110/2016/copy.pdf")); !os.IsNotExist(err) {
+		t.Errorf("expected orphaned copy to be removed; got %v", err)
+	}
+	if _, err := os.Stat(path.Join(dir, "cs110/2016/unknown.pdf")); err != nil {
+		t.Errorf("unknown orphan shouldn't be removed: %v", err)
+	}
+
+	// Only the issues that can't be repaired remain.
+	report, err = db.Fsck(FsckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := report.Unrepaired(); n != 4 {
+		t.Errorf("expected 4 remaining issues; got %d: %+v", n, report.Issues)
+	}
+}
+
+func TestFsckRelinksMovedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "examdb-fsck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.Default()
+	cfg.ExamsDir = dir
+
+	writeExamFile(t, dir, "cs110/2016/moved.pdf", "moved")
+	moved := &File{Path: "cs110/2016/moved.pdf", Course: "cs110", Year: 2016, Term: TermW1, HandClassified: true}
+	if err := moved.ComputeHash(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path.Join(dir, "cs110/2016/moved.pdf"), path.Join(dir, "cs110/2016/renamed.pdf")); err != nil {
+		t.Fatal(err)
+	}
+
+	db := MakeDatabase(cfg)
+	db.Courses["cs110"] = &Course{Code: "cs110"}
+	db.Files = []*File{moved}
+
+	report, err := db.Fsck(FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Repaired != 2 || report.Unrepaired() != 0 {
+		t.Errorf("expected the missing file and orphan to be repaired; got %+v", report.Issues)
+	}
+	if moved.Path != "cs110/2016/renamed.pdf" {
+		t.Errorf("expected the file to point at its copy; got %q", moved.Path)
+	}
+	if _, err := os.Stat(path.Join(dir, "cs110/2016/renamed.pdf")); err != nil {
+		t.Errorf("the only copy of a file shouldn't be removed: %v", err)
+	}
+}
+
+func TestFsckKeepsFilesInAliasDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "examdb-fsck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.Default()
+	cfg.ExamsDir = dir
+
+	writeExamFile(t, dir, "cs110/2016/final.pdf", "final")
+	writeExamFile(t, dir, "cs110/final.pdf", "misplaced")
+	final := &File{Path: "cs110/2016/final.pdf", Course: "cpsc 110", Year: 2016, Term: TermW1, HandClassified: true}
+	misplaced := &File{Path: "cs110/final.pdf", Course: "cpsc 110", Year: 2016, Term: TermW2, HandClassified: true}
+	for _, f := range []*File{final, misplaced} {
+		if err := f.ComputeHash(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := MakeDatabase(cfg)
+	db.Courses["cpsc 110"] = &Course{Code: "cpsc 110", Aliases: []string{"cs110"}}
+	db.Files = []*File{final, misplaced}
+
+	report, err := db.Fsck(FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != IssueWrongDir || report.Issues[0].Path != "cs110/final.pdf" {
+		t.Errorf("expected only the file outside of a year directory to be in the wrong directory; got %+v", report.Issues)
+	}
+	if final.Path != "cs110/2016/final.pdf" {
+		t.Errorf("files in the directory of an alias shouldn't be moved; got %q", final.Path)
+	}
+	if misplaced.Path != "cpsc 110/2016/final.pdf" {
+		t.Errorf("expected misplaced file to be moved to the canonical directory; got %q", misplaced.Path)
+	}
+}
@@ -0,0 +1,159 @@
+package examdb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Exam kinds
+const (
+	KindFinal      = "final"
+	KindMidterm    = "midterm"
+	KindQuiz       = "quiz"
+	KindAssignment = "assignment"
+)
+
+// ExamKinds are all the kinds of exams that a file can be.
+var ExamKinds = []string{KindFinal, KindMidterm, KindQuiz, KindAssignment}
+
+// Label is the structured description of what a file is. The display name of
+// a file is derived from it.
+type Label struct {
+	Kind       string `json:",omitempty"`
+	Number     int    `json:",omitempty"`
+	IsSample   bool   `json:",omitempty"`
+	IsSolution bool   `json:",omitempty"`
+	Section    string `json:",omitempty"`
+}
+
+var (
+	labelRegexp   = regexp.MustCompile(`^(?:(sample|practice) )?(final|midterm|quiz|assignment)(?: exam)?(?: #?(\d+))?(?: section (\w+))?(?: \((solution)\))?$`)
+	sectionRegexp = regexp.MustCompile(`^\w+$`)
+)
+
+// ParseLabel parses a display name such as "Sample Midterm 2 (Solution)" into
+// a label.
+func ParseLabel(name string) (Label, error) {
+	normalized := strings.ToLower(strings.Join(strings.Fields(name), " "))
+	m := labelRegexp.FindStringSubmatch(normalized)
+	if m == nil {
+		return Label{}, errors.Errorf("invalid label %q", name)
+	}
+	l := Label{
+		IsSample:   len(m[1]) > 0,
+		Kind:       m[2],
+		Section:    strings.ToUpper(m[4]),
+		IsSolution: len(m[5]) > 0,
+	}
+	if len(m[3]) > 0 {
+		n, err := strconv.Atoi(m[3])
+		if err != nil {
+			return Label{}, errors.Wrapf(err, "invalid label %q", name)
+		}
+		l.Number = n
+	}
+	return l, nil
+}
+
+// Validate returns an error if the label isn't valid.
+func (l Label) Validate() error {
+	valid := false
+	for _, kind := range ExamKinds {
+		if l.Kind == kind {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return errors.Errorf("invalid kind %q", l.Kind)
+	}
+	if l.Number < 0 {
+		return errors.Errorf("invalid number %d", l.Number)
+	}
+	if len(l.Section) > 0 && !sectionRegexp.MatchString(l.Section) {
+		return errors.Errorf("invalid section %q", l.Section)
+	}
+	return nil
+}
+
+// KindName returns the display name of the kind, i.e. "Midterm".
+func (l Label) KindName() string {
+	return strings.Title(l.Kind)
+}
+
+// String returns the display name of the label, i.e. "Sample Midterm 2
+// (Solution)".
+func (l Label) String() string {
+	var bits []string
+	if l.IsSample {
+		bits = append(bits, "Sample")
+	}
+	bits = append(bits, l.KindName())
+	if l.Number > 0 {
+		bits = append(bits, strconv.Itoa(l.Number))
+	}
+	if len(l.Section) > 0 {
+		bits = append(bits, "Section", l.Section)
+	}
+	if l.IsSolution {
+		bits = append(bits, "(Solution)")
+	}
+	return strings.Join(bits, " ")
+}
+
+// SetLabel sets the label of the file and derives the name from it.
+func (f *File) SetLabel(l Label) {
+	f.Label = l
+	f.Name = l.String()
+}
+
+// examLabels returns the names of the most common labels. The first sixteen
+// are in the same order as the original fixed list so their indexes are
+// stable.
+func examLabels() []string {
+	var labels []Label
+	for _, l := range []Label{
+		{Kind: KindFinal},
+		{Kind: KindMidterm},
+		{Kind: KindMidterm, Number: 1},
+		{Kind: KindMidterm, Number: 2},
+		{Kind: KindMidterm, Number: 3},
+		{Kind: KindQuiz, Number: 1},
+		{Kind: KindQuiz, Number: 2},
+		{Kind: KindQuiz, Number: 3},
+		{Kind: KindQuiz, Number: 4},
+		{Kind: KindQuiz, Number: 5},
+	} {
+		for _, sample := range []bool{false, true} {
+			for _, solution := range []bool{false, true} {
+				l.IsSample = sample
+				l.IsSolution = solution
+				labels = append(labels, l)
+			}
+		}
+	}
+	names := make([]string, len(labels))
+	for i, l := range labels {
+		names[i] = l.String()
+	}
+	return names
+}
+
+// labelFixReason returns why the label of a file needs to be fixed or "" if
+// it's fine.
+func labelFixReason(f *File) string {
+	if len(f.Kind) == 0 {
+		return "invalid file name"
+	}
+	if err := f.Label.Validate(); err != nil {
+		return fmt.Sprintf("invalid label: %s", err)
+	}
+	if f.Name != f.Label.String() {
+		return "name doesn't match label"
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package examdb
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/ubccsss/exams/config"
+)
+
+func TestParseLabel(t *testing.T) {
+	cases := []struct {
+		name string
+		want Label
+		err  bool
+	}{
+		{name: "Final", want: Label{Kind: KindFinal}},
+		{name: "Sample Midterm 2 (Solution)", want: Label{Kind: KindMidterm, Number: 2, IsSample: true, IsSolution: true}},
+		{name: "Practice  Quiz 3", want: Label{Kind: KindQuiz, Number: 3, IsSample: true}},
+		{name: "Midterm Exam #1", want: Label{Kind: KindMidterm, Number: 1}},
+		{name: "midterm 1 section 201 (solution)", want: Label{Kind: KindMidterm, Number: 1, Section: "201", IsSolution: true}},
+		{name: "Assignment 4", want: Label{Kind: KindAssignment, Number: 4}},
+		{name: "Final Review", err: true},
+		{name: "(Solution)", err: true},
+		{name: "", err: true},
+	}
+
+	for i, c := range cases {
+		out, err := ParseLabel(c.name)
+		if (err != nil) != c.err {
+			t.Errorf("%d. ParseLabel(%q) error = %v; want error %t", i, c.name, err, c.err)
+			continue
+		}
+		if !reflect.DeepEqual(out, c.want) {
+			t.Errorf("%d. ParseLabel(%q) = %#v; not %#v", i, c.name, out, c.want)
+		}
+	}
+}
+
+func TestExamLabelsRoundTrip(t *testing.T) {
+	for i, name := range ExamLabels {
+		l, err := ParseLabel(name)
+		if err != nil {
+			t.Errorf("%d. %s", i, err)
+			continue
+		}
+		if err := l.Validate(); err != nil {
+			t.Errorf("%d. %q: %s", i, name, err)
+		}
+		if out := l.String(); out != name {
+			t.Errorf("%d. ParseLabel(%q).String() = %q", i, name, out)
+		}
+	}
+
+	if ExamLabels[15] != "Sample Midterm 2 (Solution)" {
+		t.Errorf("ExamLabels[15] = %q; expected the original order to be kept", ExamLabels[15])
+	}
+}
+
+func TestLabelValidate(t *testing.T) {
+	cases := []struct {
+		l     Label
+		valid bool
+	}{
+		{Label{Kind: KindFinal}, true},
+		{Label{Kind: KindQuiz, Number: 5, Section: "L1A"}, true},
+		{Label{}, false},
+		{Label{Kind: "exam"}, false},
+		{Label{Kind: KindMidterm, Number: -1}, false},
+		{Label{Kind: KindMidterm, Section: "1 2"}, false},
+	}
+
+	for i, c := range cases {
+		if err := c.l.Validate(); (err == nil) != c.valid {
+			t.Errorf("%d. %#v.Validate() = %v; want valid %t", i, c.l, err, c.valid)
+		}
+	}
+}
+
+func TestMigrateLabels(t *testing.T) {
+	db := MakeDatabase(config.Default())
+	db.Files = []*File{
+		{Name: "sample midterm 1 (solution)", HandClassified: true, Term: TermW1, Year: 2016},
+		{Name: "Final Review", HandClassified: true, Term: TermW1, Year: 2016},
+		{Inferred: &File{Name: "Quiz 2"}},
+	}
+
+	if n, err := migrateLabels(db, ioutil.Discard); err != nil || n != 2 {
+		t.Errorf("migrateLabels() = %d, %v; not 2", n, err)
+	}
+	if n, err := migrateLabels(db, ioutil.Discard); err != nil || n != 0 {
+		t.Errorf("migrateLabels() should be idempotent; migrated %d, %v", n, err)
+	}
+
+	if got := db.Files[0].Name; got != "Sample Midterm 1 (Solution)" {
+		t.Errorf("expected name to be derived from label; got %q", got)
+	}
+	if got, want := db.Files[2].Inferred.Label, (Label{Kind: KindQuiz, Number: 2}); got != want {
+		t.Errorf("inferred label = %#v; not %#v", got, want)
+	}
+
+	reasons := db.NeedFix()
+	if len(reasons) != 1 || reasons[0].File != db.Files[1] {
+		t.Fatalf("expected only the unparsable file to need fixing; got %+v", reasons)
+	}
+}
@@ -0,0 +1,163 @@
+package examdb
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ResolveCourse returns the code of the course that the code or one of its
+// aliases refers to. If there isn't one, the canonical form of the code is
+// returned with false.
+func (db *Database) ResolveCourse(code string) (string, bool) {
+	db.Mu.RLock()
+	defer db.Mu.RUnlock()
+
+	return db.resolveCourseLocked(code)
+}
+
+func (db *Database) resolveCourseLocked(code string) (string, bool) {
+	canonical := CanonicalCourseCode(db.Config, code)
+	if _, ok := db.Courses[canonical]; ok {
+		return canonical, true
+	}
+	for _, c := range db.Courses {
+		for _, alias := range c.Aliases {
+			if CanonicalCourseCode(db.Config, alias) == canonical {
+				return c.Code, true
+			}
+		}
+	}
+	return canonical, false
+}
+
+// SetCourseLinks replaces the aliases, predecessors and successors of a
+// course. The other side of every predecessor and successor relationship is
+// updated to match.
+func (db *Database) SetCourseLinks(code string, aliases, predecessors, successors []string) error {
+	db.Mu.Lock()
+	defer db.Mu.Unlock()
+
+	c, ok := db.Courses[code]
+	if !ok {
+		return errors.Errorf("unknown course %q", code)
+	}
+
+	var canonicalAliases []string
+	for _, alias := range aliases {
+		alias = CanonicalCourseCode(db.Config, alias)
+		if len(alias) == 0 || containsString(canonicalAliases, alias) {
+			continue
+		}
+		if alias == c.Code {
+			return errors.Errorf("%q can't be an alias of itself", alias)
+		}
+		if _, ok := db.Courses[alias]; ok {
+			return errors.Errorf("alias %q is already a course, move its files and remove it first", alias)
+		}
+		for _, other := range db.Courses {
+			if other != c && containsString(other.Aliases, alias) {
+				return errors.Errorf("%q is already an alias of %q", alias, other.Code)
+			}
+		}
+		canonicalAliases = append(canonicalAliases, alias)
+	}
+
+	resolve := func(codes []string) ([]string, error) {
+		var resolved []string
+		for _, code := range codes {
+			if len(code) == 0 {
+				continue
+			}
+			linked, ok := db.resolveCourseLocked(code)
+			if !ok {
+				return nil, errors.Errorf("unknown course %q", code)
+			}
+			if linked == c.Code {
+				return nil, errors.Errorf("%q can't be linked to itself", code)
+			}
+			if !containsString(resolved, linked) {
+				resolved = append(resolved, linked)
+			}
+		}
+		return resolved, nil
+	}
+	preds, err := resolve(predecessors)
+	if err != nil {
+		return errors.Wrap(err, "predecessors")
+	}
+	succs, err := resolve(successors)
+	if err != nil {
+		return errors.Wrap(err, "successors")
+	}
+	for _, pred := range preds {
+		if containsString(succs, pred) {
+			return errors.Errorf("%q can't be both a predecessor and a successor", pred)
+		}
+	}
+
+	c.Aliases = canonicalAliases
+	c.Predecessors = preds
+	c.Successors = succs
+	for _, other := range db.Courses {
+		if other == c {
+			continue
+		}
+		other.Successors = setMembership(other.Successors, c.Code, containsString(preds, other.Code))
+		other.Predecessors = setMembership(other.Predecessors, c.Code, containsString(succs, other.Code))
+	}
+	return nil
+}
+
+// setMembership adds or removes s from the list.
+func setMembership(list []string, s string, member bool) []string {
+	if member {
+		if !containsString(list, s) {
+			list = append(list, s)
+		}
+		return list
+	}
+	var out []string
+	for _, item := range list {
+		if item != s {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// PreviousCourses returns the codes of all courses that the course replaced,
+// nearest first.
+func (db *Database) PreviousCourses(code string) []string {
+	return db.walkCourses(code, func(c *Course) []string { return c.Predecessors })
+}
+
+// NextCourses returns the codes of all courses that replaced the course,
+// nearest first.
+func (db *Database) NextCourses(code string) []string {
+	return db.walkCourses(code, func(c *Course) []string { return c.Successors })
+}
+
+// walkCourses does a breadth first walk of the course links.
+func (db *Database) walkCourses(code string, next func(c *Course) []string) []string {
+	db.Mu.RLock()
+	defer db.Mu.RUnlock()
+
+	var codes []string
+	seen := map[string]bool{code: true}
+	queue := []string{code}
+	for len(queue) > 0 {
+		c, ok := db.Courses[queue[0]]
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+		for _, linked := range next(c) {
+			if seen[linked] {
+				continue
+			}
+			seen[linked] = true
+			codes = append(codes, linked)
+			queue = append(queue, linked)
+		}
+	}
+	return codes
+}
@@ -0,0 +1,107 @@
+package examdb
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/ubccsss/exams/config"
+)
+
+func linkTestDB() *Database {
+	return &Database{
+		Config: config.Default(),
+		Courses: map[string]*Course{
+			"cpsc 259": {Code: "cpsc 259"},
+			"cpsc 260": {Code: "cpsc 260"},
+			"cpsc 160": {Code: "cpsc 160"},
+			"math 100": {Code: "math 100"},
+		},
+	}
+}
+
+func TestSetCourseLinks(t *testing.T) {
+	db := linkTestDB()
+	if err := db.SetCourseLinks("cpsc 259", []string{"EECE 259", "eece259"}, []string{"cs260"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetCourseLinks("cpsc 260", nil, []string{"cpsc 160"}, []string{"cpsc 259"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"eece 259"}; !reflect.DeepEqual(db.Courses["cpsc 259"].Aliases, want) {
+		t.Errorf("aliases = %q; not %q", db.Courses["cpsc 259"].Aliases, want)
+	}
+	if want := []string{"cpsc 260"}; !reflect.DeepEqual(db.Courses["cpsc 160"].Successors, want) {
+		t.Errorf("successors of cpsc 160 = %q; not %q", db.Courses["cpsc 160"].Successors, want)
+	}
+	if want := []string{"cpsc 260", "cpsc 160"}; !reflect.DeepEqual(db.PreviousCourses("cpsc 259"), want) {
+		t.Errorf("PreviousCourses = %q; not %q", db.PreviousCourses("cpsc 259"), want)
+	}
+	if want := []string{"cpsc 260", "cpsc 259"}; !reflect.DeepEqual(db.NextCourses("cpsc 160"), want) {
+		t.Errorf("NextCourses = %q; not %q", db.NextCourses("cpsc 160"), want)
+	}
+
+	// Removing a link removes the other side too.
+	if err := db.SetCourseLinks("cpsc 259", []string{"eece 259"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if succs := db.Courses["cpsc 260"].Successors; len(succs) != 0 {
+		t.Errorf("expected successors of cpsc 260 to be removed; got %q", succs)
+	}
+}
+
+func TestSetCourseLinksInvalid(t *testing.T) {
+	cases := []struct {
+		code                       string
+		aliases, preds, successors []string
+	}{
+		{"cpsc 999", nil, nil, nil},
+		{"cpsc 259", []string{"cpsc 259"}, nil, nil},
+		{"cpsc 259", []string{"math 100"}, nil, nil},
+		{"cpsc 259", nil, []string{"cpsc 999"}, nil},
+		{"cpsc 259", nil, []string{"cs259"}, nil},
+		{"cpsc 259", nil, []string{"cpsc 260"}, []string{"cpsc 260"}},
+	}
+	for i, c := range cases {
+		db := linkTestDB()
+		if err := db.SetCourseLinks(c.code, c.aliases, c.preds, c.successors); err == nil {
+			t.Errorf("%d. expected error", i)
+		}
+	}
+
+	db := linkTestDB()
+	if err := db.SetCourseLinks("cpsc 259", []string{"eece 259"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetCourseLinks("cpsc 260", []string{"eece 259"}, nil, nil); err == nil {
+		t.Errorf("expected error reusing an alias")
+	}
+}
+
+func TestResolveCourse(t *testing.T) {
+	db := linkTestDB()
+	db.Courses["cpsc 259"].Aliases = []string{"eece 259"}
+
+	cases := []struct {
+		code string
+		want string
+		ok   bool
+	}{
+		{"CPSC 259", "cpsc 259", true},
+		{"cs260", "cpsc 260", true},
+		{"EECE259", "cpsc 259", true},
+		{"eece 260", "eece 260", false},
+	}
+	for i, c := range cases {
+		out, ok := db.ResolveCourse(c.code)
+		if out != c.want || ok != c.ok {
+			t.Errorf("%d. ResolveCourse(%q) = %q, %t; not %q, %t", i, c.code, out, ok, c.want, c.ok)
+		}
+	}
+
+	db.AddCourse(ioutil.Discard, "EECE 259", "Data Structures and Algorithms")
+	if _, ok := db.Courses["eece 259"]; ok {
+		t.Errorf("AddCourse shouldn't add aliases as courses")
+	}
+}
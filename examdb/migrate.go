@@ -0,0 +1,282 @@
+package examdb
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Migration upgrades the database schema from Version-1 to Version.
+type Migration struct {
+	Version int
+	Desc    string
+	// Migrate applies the migration to the database with Mu held. It writes a
+	// line to w for every change and returns the number of changes.
+	Migrate func(db *Database, w io.Writer) (int, error)
+}
+
+// Migrations are all of the migrations in order. New migrations must be
+// appended with the next version.
+var Migrations = []Migration{
+	{1, "strip static/ prefixes from file paths", migrateStripStaticPrefix},
+	{2, "move terms out of file names and normalize names", migrateNameTerms},
+	{3, "derive structured labels from file names", migrateLabels},
+	{4, "canonicalize course codes and record legacy aliases", migrateCourseCodes},
+	{5, "normalize session years and terms", migrateSessions},
+}
+
+// SchemaVersion is the current version of the database schema.
+var SchemaVersion = len(Migrations)
+
+// MigrationResult is the outcome of a single applied migration.
+type MigrationResult struct {
+	Version int
+	Desc    string
+	Changes int
+}
+
+// NeedsMigration returns whether the database is older than the current
+// schema.
+func (db *Database) NeedsMigration() bool {
+	db.Mu.RLock()
+	defer db.Mu.RUnlock()
+
+	return db.SchemaVersion < SchemaVersion
+}
+
+// Migrate applies all migrations newer than the schema version of the database
+// in order. Details of each change are written to w.
+func (db *Database) Migrate(w io.Writer) ([]MigrationResult, error) {
+	db.Mu.Lock()
+	defer db.Mu.Unlock()
+
+	if db.SchemaVersion > SchemaVersion {
+		return nil, errors.Errorf("database schema version %d is newer than supported version %d", db.SchemaVersion, SchemaVersion)
+	}
+
+	var results []MigrationResult
+	for _, m := range Migrations {
+		if m.Version <= db.SchemaVersion {
+			continue
+		}
+		fmt.Fprintf(w, "Migration %d: %s\n", m.Version, m.Desc)
+		changes, err := m.Migrate(db, w)
+		if err != nil {
+			return results, errors.Wrapf(err, "migration %d", m.Version)
+		}
+		db.SchemaVersion = m.Version
+		results = append(results, MigrationResult{
+			Version: m.Version,
+			Desc:    m.Desc,
+			Changes: changes,
+		})
+	}
+	return results, nil
+}
+
+func migrateStripStaticPrefix(db *Database, w io.Writer) (int, error) {
+	count := 0
+	for _, f := range db.Files {
+		p := strings.TrimPrefix(f.Path, "static/exams/")
+		p = strings.TrimPrefix(p, "static/")
+		if p == f.Path {
+			continue
+		}
+		fmt.Fprintf(w, "  %s: path %q -> %q\n", f.Hash, f.Path, p)
+		f.Path = p
+		count++
+	}
+	return count, nil
+}
+
+var nameTerms = map[string]string{
+	"(Term 1)": TermW1,
+	"(Term 2)": TermW2,
+	"(Summer)": TermS,
+}
+
+var nameReplacements = map[string]string{
+	"Practice Midterm":  "Sample Midterm",
+	"Practice Final":    "Sample Final",
+	"Final Sample":      "Sample Final",
+	"Midterm 1 Sample":  "Sample Midterm 1",
+	"Midterm Sample":    "Sample Midterm",
+	"Midterm 2 Sample":  "Sample Midterm 2",
+	"Midterm I Sample":  "Sample Midterm 1",
+	"Midterm II Sample": "Sample Midterm 2",
+	"Practice Quiz":     "Sample Quiz",
+}
+
+var whitespaceRegexp = regexp.MustCompile(`\s\s+`)
+
+func removeDuplicateWhitespace(str string) string {
+	return whitespaceRegexp.ReplaceAllString(strings.TrimSpace(str), " ")
+}
+
+func migrateNameTerms(db *Database, w io.Writer) (int, error) {
+	count := 0
+	for _, f := range db.Files {
+		name := f.Name
+		term := f.Term
+		for pattern, t := range nameTerms {
+			if strings.Contains(name, pattern) {
+				name = strings.Replace(name, pattern, "", -1)
+				term = t
+			}
+		}
+		for pattern, replacement := range nameReplacements {
+			name = strings.Replace(name, pattern, replacement, -1)
+		}
+		name = removeDuplicateWhitespace(name)
+		if name == f.Name && term == f.Term {
+			continue
+		}
+		if name != f.Name {
+			fmt.Fprintf(w, "  %s: name %q -> %q\n", f.Hash, f.Name, name)
+		}
+		if term != f.Term {
+			fmt.Fprintf(w, "  %s: term %q -> %q\n", f.Hash, f.Term, term)
+		}
+		f.Name = name
+		f.Term = term
+		count++
+	}
+	return count, nil
+}
+
+// migrateLabels parses the names of all files that don't have a label yet and
+// sets their labels.
+func migrateLabels(db *Database, w io.Writer) (int, error) {
+	count := 0
+	migrate := func(hash string, f *File) {
+		if f == nil || len(f.Kind) > 0 || len(f.Name) == 0 {
+			return
+		}
+		l, err := ParseLabel(f.Name)
+		if err != nil {
+			return
+		}
+		if name := l.String(); name != f.Name {
+			fmt.Fprintf(w, "  %s: name %q -> %q\n", f.Hash, f.Name, name)
+		}
+		f.SetLabel(l)
+		count++
+	}
+	for _, f := range db.Files {
+		migrate(f.Hash, f)
+		migrate(f.Hash, f.Inferred)
+	}
+	return count, nil
+}
+
+// migrateCourseCodes renames courses to their canonical codes, keeping the old
+// codes as aliases, and updates the courses of all files. Files aren't moved so
+// their URLs keep working, fsck accepts files in the directories of aliases and
+// new files are added to the new course directories.
+func migrateCourseCodes(db *Database, w io.Writer) (int, error) {
+	if db.Config == nil {
+		return 0, errors.New("the department config is needed to canonicalize course codes")
+	}
+	if db.Courses == nil {
+		db.Courses = map[string]*Course{}
+	}
+
+	var codes []string
+	for code := range db.Courses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	count := 0
+	for _, code := range codes {
+		canonical := CanonicalCourseCode(db.Config, code)
+		if canonical == code {
+			continue
+		}
+		c := db.Courses[code]
+		delete(db.Courses, code)
+		target, ok := db.Courses[canonical]
+		if !ok {
+			target = &Course{Code: canonical}
+			db.Courses[canonical] = target
+		}
+		if len(target.Desc) == 0 {
+			target.Desc = c.Desc
+		}
+		for _, alias := range append([]string{code}, c.Aliases...) {
+			if !containsString(target.Aliases, alias) {
+				target.Aliases = append(target.Aliases, alias)
+			}
+		}
+		fmt.Fprintf(w, "  course %q -> %q\n", code, canonical)
+		count++
+	}
+
+	migrate := func(hash string, f *File) {
+		if f == nil {
+			return
+		}
+		canonical := CanonicalCourseCode(db.Config, f.Course)
+		if canonical == f.Course {
+			return
+		}
+		fmt.Fprintf(w, "  %s: course %q -> %q\n", hash, f.Course, canonical)
+		f.Course = canonical
+		count++
+	}
+	for _, f := range db.Files {
+		migrate(f.Hash, f)
+		migrate(f.Hash, f.Inferred)
+	}
+	return count, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Years outside of this range are typos or placeholders.
+const (
+	minSessionYear = 1900
+	maxSessionYear = 2100
+)
+
+func migrateSessions(db *Database, w io.Writer) (int, error) {
+	count := 0
+	migrate := func(hash string, f *File) {
+		if f == nil {
+			return
+		}
+		s := f.Session()
+		if len(s.Term) > 0 {
+			term, ok := NormalizeTerm(s.Term)
+			if !ok {
+				term = TermUnknown
+			}
+			s.Term = term
+		}
+		if s.Year != 0 && (s.Year < minSessionYear || s.Year > maxSessionYear) {
+			s.Year = 0
+		}
+		if s == f.Session() {
+			return
+		}
+		fmt.Fprintf(w, "  %s: session %d %q -> %d %q\n", hash, f.Year, f.Term, s.Year, s.Term)
+		f.SetSession(s)
+		count++
+	}
+	for _, f := range db.Files {
+		migrate(f.Hash, f)
+		migrate(f.Hash, f.Inferred)
+	}
+	return count, nil
+}
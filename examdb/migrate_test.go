@@ -0,0 +1,103 @@
+package examdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ubccsss/exams/config"
+)
+
+func TestMigrationsOrdered(t *testing.T) {
+	for i, m := range Migrations {
+		if m.Version != i+1 {
+			t.Errorf("%d. migration %q has version %d; expected %d", i, m.Desc, m.Version, i+1)
+		}
+		if m.Migrate == nil {
+			t.Errorf("%d. migration %q has no Migrate func", i, m.Desc)
+		}
+	}
+	if SchemaVersion != len(Migrations) {
+		t.Errorf("SchemaVersion = %d; expected %d", SchemaVersion, len(Migrations))
+	}
+	if db := MakeDatabase(config.Default()); db.NeedsMigration() {
+		t.Errorf("new databases shouldn't need migrating")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := &Database{
+		Config: config.Default(),
+		Courses: map[string]*Course{
+			"cs110":    {Code: "cs110", Desc: "Computation, Programs, and Programming"},
+			"cpsc 110": {Code: "cpsc 110"},
+			"math 100": {Code: "math 100"},
+		},
+		Files: []*File{
+			{Hash: "a", Name: "Practice Midterm  (Term 2)", Path: "static/exams/cs110/a.pdf", Course: "cs110"},
+			{Hash: "b", Name: "Final", Term: TermW1, Path: "cs110/b.pdf", Course: "cs110"},
+			{Hash: "c", Name: "Final", Year: 9999, Term: "wt2", Path: "cs110/c.pdf", Course: "cs110", Inferred: &File{Term: "Summer 1"}},
+		},
+	}
+	if !db.NeedsMigration() {
+		t.Fatalf("expected database to need migrating")
+	}
+
+	var buf bytes.Buffer
+	results, err := db.Migrate(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(Migrations) {
+		t.Fatalf("expected %d results; got %+v", len(Migrations), results)
+	}
+	if db.SchemaVersion != SchemaVersion {
+		t.Errorf("SchemaVersion = %d; not %d", db.SchemaVersion, SchemaVersion)
+	}
+	if !strings.Contains(buf.String(), "Migration 1:") {
+		t.Errorf("expected migration details to be written; got %q", buf.String())
+	}
+
+	a := db.Files[0]
+	if a.Path != "cs110/a.pdf" {
+		t.Errorf("path = %q", a.Path)
+	}
+	if a.Name != "Sample Midterm" || a.Term != TermW2 {
+		t.Errorf("name, term = %q, %q", a.Name, a.Term)
+	}
+	if want := (Label{Kind: KindMidterm, IsSample: true}); a.Label != want {
+		t.Errorf("label = %#v; not %#v", a.Label, want)
+	}
+	if a.Course != "cpsc 110" {
+		t.Errorf("course = %q", a.Course)
+	}
+	if _, ok := db.Courses["cs110"]; ok || len(db.Courses) != 2 {
+		t.Errorf("expected legacy course to be merged; got %+v", db.Courses)
+	}
+	if c := db.Courses["cpsc 110"]; c.Desc == "" || !reflect.DeepEqual(c.Aliases, []string{"cs110"}) {
+		t.Errorf("canonical course = %+v", c)
+	}
+
+	c := db.Files[2]
+	if c.Year != 0 || c.Term != TermW2 || c.Inferred.Term != TermS1 {
+		t.Errorf("session = %d %q, inferred %q", c.Year, c.Term, c.Inferred.Term)
+	}
+
+	// Migrating again is a no-op.
+	results, err = db.Migrate(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no migrations to be applied; got %+v", results)
+	}
+}
+
+func TestMigrateNewerSchema(t *testing.T) {
+	db := &Database{SchemaVersion: SchemaVersion + 1}
+	if _, err := db.Migrate(ioutil.Discard); err == nil {
+		t.Errorf("expected error migrating a newer schema")
+	}
+}
@@ -0,0 +1,179 @@
+package examdb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Session is an academic session and a term within it. For the winter session
+// Year is the year it starts in, so the W2 term of the 2016 session runs from
+// January to April 2017. For the summer session Year is the calendar year, as
+// UBC numbers them, so 2017S runs from May to August 2017.
+//
+// Session uses the same Year and Term as File so Year always means the same
+// thing no matter where a file came from.
+type Session struct {
+	Year int
+	Term string
+}
+
+// Session returns the session the file is from.
+func (f File) Session() Session {
+	return Session{Year: f.Year, Term: f.Term}
+}
+
+// SetSession sets the year and term of the file.
+func (f *File) SetSession(s Session) {
+	f.Year = s.Year
+	f.Term = s.Term
+}
+
+// termMonths are the months each term runs during.
+var termMonths = map[string][2]time.Month{
+	TermW1: {time.September, time.December},
+	TermW2: {time.January, time.April},
+	TermS:  {time.May, time.August},
+	TermS1: {time.May, time.June},
+	TermS2: {time.July, time.August},
+}
+
+// SessionFromDate returns the session that t is during. Dates in the summer
+// can't tell the two summer terms apart reliably, so they're in TermS.
+func SessionFromDate(t time.Time) Session {
+	switch m := t.Month(); {
+	case m <= time.April:
+		return Session{Year: t.Year() - 1, Term: TermW2}
+	case m <= time.August:
+		return Session{Year: t.Year(), Term: TermS}
+	default:
+		return Session{Year: t.Year(), Term: TermW1}
+	}
+}
+
+var termAliases = map[string]string{
+	"W1":      TermW1,
+	"WT1":     TermW1,
+	"T1":      TermW1,
+	"TERM1":   TermW1,
+	"WINTER1": TermW1,
+	"W2":      TermW2,
+	"WT2":     TermW2,
+	"T2":      TermW2,
+	"TERM2":   TermW2,
+	"WINTER2": TermW2,
+	"S":       TermS,
+	"SUMMER":  TermS,
+	"S1":      TermS1,
+	"ST1":     TermS1,
+	"SUMMER1": TermS1,
+	"S2":      TermS2,
+	"ST2":     TermS2,
+	"SUMMER2": TermS2,
+	"UNKNOWN": TermUnknown,
+	"UNDATED": TermUnknown,
+}
+
+// NormalizeTerm returns the canonical form of a term, e.g. "WT2" and "term 2"
+// are both W2. Empty and unrecognized terms are returned with false.
+func NormalizeTerm(term string) (string, bool) {
+	key := strings.ToUpper(strings.Join(strings.Fields(term), ""))
+	if len(key) == 0 {
+		return "", false
+	}
+	t, ok := termAliases[key]
+	return t, ok
+}
+
+var (
+	sessionRegexp     = regexp.MustCompile(`^(\d{4})\s*-?\s*([A-Za-z]+\s*\d?)?$`)
+	termFirstRegexp   = regexp.MustCompile(`^([A-Za-z]+\s*\d?)\s*-?\s*(\d{4})$`)
+	sessionDateLayout = []string{
+		"2006 January",
+		"January 2006",
+		"2006 Jan",
+		"Jan 2006",
+		"January, 2006",
+		"2006-01",
+	}
+)
+
+// ParseSession parses the session formats used by our sources: "2016W2",
+// "2016WT2" from UBC Math, "2017S1", "W2 2016", a bare year with an unknown
+// term and dates like "2017 January" from UBC Law.
+func ParseSession(s string) (Session, error) {
+	s = strings.TrimSpace(s)
+
+	var year, term string
+	if m := sessionRegexp.FindStringSubmatch(s); m != nil {
+		year, term = m[1], m[2]
+	} else if m := termFirstRegexp.FindStringSubmatch(s); m != nil {
+		year, term = m[2], m[1]
+	}
+	if len(year) > 0 {
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			return Session{}, errors.Wrapf(err, "session %q", s)
+		}
+		if len(term) == 0 {
+			return Session{Year: y, Term: TermUnknown}, nil
+		}
+		if t, ok := NormalizeTerm(term); ok {
+			return Session{Year: y, Term: t}, nil
+		}
+	}
+
+	for _, layout := range sessionDateLayout {
+		if t, err := time.Parse(layout, s); err == nil {
+			return SessionFromDate(t), nil
+		}
+	}
+	return Session{}, errors.Errorf("invalid session %q", s)
+}
+
+// IsZero returns whether the session is unset.
+func (s Session) IsZero() bool {
+	return s.Year == 0 && (len(s.Term) == 0 || s.Term == TermUnknown)
+}
+
+// String formats the session like UBC does, e.g. "2016W2". Unknown parts are
+// left out.
+func (s Session) String() string {
+	_, knownTerm := termMonths[s.Term]
+	switch {
+	case s.Year == 0 && knownTerm:
+		return s.Term
+	case s.Year == 0:
+		return ""
+	case !knownTerm:
+		return strconv.Itoa(s.Year)
+	}
+	return fmt.Sprintf("%d%s", s.Year, s.Term)
+}
+
+// Months returns when the term of the session runs, e.g. "Jan–Apr 2017", or
+// "" if it isn't known.
+func (s Session) Months() string {
+	months, ok := termMonths[s.Term]
+	if s.Year == 0 || !ok {
+		return ""
+	}
+	year := s.Year
+	if s.Term == TermW2 {
+		year++
+	}
+	return fmt.Sprintf("%s–%s %d", months[0].String()[:3], months[1].String()[:3], year)
+}
+
+// Display returns the session with when it ran, e.g. "2016W2 (Jan–Apr 2017)".
+func (s Session) Display() string {
+	months := s.Months()
+	if len(months) == 0 {
+		return s.String()
+	}
+	return fmt.Sprintf("%s (%s)", s, months)
+}
@@ -0,0 +1,103 @@
+package examdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSession(t *testing.T) {
+	cases := []struct {
+		s       string
+		want    Session
+		wantErr bool
+	}{
+		{"2016W2", Session{2016, TermW2}, false},
+		{"2016WT1", Session{2016, TermW1}, false},
+		{"2016 wt2", Session{2016, TermW2}, false},
+		{"2017S", Session{2017, TermS}, false},
+		{"2017S1", Session{2017, TermS1}, false},
+		{"2017-S2", Session{2017, TermS2}, false},
+		{"W2 2016", Session{2016, TermW2}, false},
+		{"Term 1 2016", Session{2016, TermW1}, false},
+		{"2016", Session{2016, TermUnknown}, false},
+		{"2017 January", Session{2016, TermW2}, false},
+		{"April 2017", Session{2016, TermW2}, false},
+		{"2017 June", Session{2017, TermS}, false},
+		{"Dec 2016", Session{2016, TermW1}, false},
+		{"", Session{}, true},
+		{"2016X3", Session{}, true},
+		{"sometime", Session{}, true},
+	}
+	for i, c := range cases {
+		out, err := ParseSession(c.s)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%d. ParseSession(%q) error = %v", i, c.s, err)
+			continue
+		}
+		if out != c.want {
+			t.Errorf("%d. ParseSession(%q) = %+v; not %+v", i, c.s, out, c.want)
+		}
+	}
+}
+
+func TestSessionFromDate(t *testing.T) {
+	cases := []struct {
+		month time.Month
+		want  Session
+	}{
+		{time.January, Session{2016, TermW2}},
+		{time.April, Session{2016, TermW2}},
+		{time.May, Session{2017, TermS}},
+		{time.August, Session{2017, TermS}},
+		{time.September, Session{2017, TermW1}},
+		{time.December, Session{2017, TermW1}},
+	}
+	for _, c := range cases {
+		out := SessionFromDate(time.Date(2017, c.month, 1, 0, 0, 0, 0, time.UTC))
+		if out != c.want {
+			t.Errorf("SessionFromDate(%s 2017) = %+v; not %+v", c.month, out, c.want)
+		}
+	}
+}
+
+func TestSessionDisplay(t *testing.T) {
+	cases := []struct {
+		s    Session
+		want string
+	}{
+		{Session{}, ""},
+		{Session{0, TermW1}, "W1"},
+		{Session{2016, TermUnknown}, "2016"},
+		{Session{2016, ""}, "2016"},
+		{Session{2016, TermW1}, "2016W1 (Sep–Dec 2016)"},
+		{Session{2016, TermW2}, "2016W2 (Jan–Apr 2017)"},
+		{Session{2017, TermS}, "2017S (May–Aug 2017)"},
+		{Session{2017, TermS2}, "2017S2 (Jul–Aug 2017)"},
+	}
+	for _, c := range cases {
+		if out := c.s.Display(); out != c.want {
+			t.Errorf("%+v.Display() = %q; not %q", c.s, out, c.want)
+		}
+	}
+}
+
+func TestNormalizeTerm(t *testing.T) {
+	cases := map[string]string{
+		"w1":       TermW1,
+		"WT2":      TermW2,
+		"Term 2":   TermW2,
+		"summer":   TermS,
+		"Summer 2": TermS2,
+		"unknown":  TermUnknown,
+	}
+	for term, want := range cases {
+		if out, ok := NormalizeTerm(term); !ok || out != want {
+			t.Errorf("NormalizeTerm(%q) = %q, %t; not %q", term, out, ok, want)
+		}
+	}
+	for _, term := range []string{"", "W3", "fall"} {
+		if out, ok := NormalizeTerm(term); ok {
+			t.Errorf("NormalizeTerm(%q) = %q; expected it to be invalid", term, out)
+		}
+	}
+}
@@ -2,11 +2,15 @@ package generators
 
 import (
 	"bytes"
+	"fmt"
+	"html"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/russross/blackfriday"
@@ -25,10 +29,67 @@ func fileTree(files []*examdb.File) map[int][]*examdb.File {
 	return m
 }
 
+// courseFile is a file embedded as JSON in course pages so they can be
+// filtered client side.
+type courseFile struct {
+	Name string
+	URL  string
+	Year int
+	Term string
+	// Session is the year and term for display, e.g. "2016W2 (Jan–Apr 2017)".
+	Session  string
+	Kind     string
+	Sample   bool
+	Solution bool
+	// YearOrder and TermOrder are the positions of the file when sorted by
+	// year and by term so the page can sort the same way the server does.
+	YearOrder int
+	TermOrder int
+}
+
+// courseFileData returns the files of a course in the same order as the static
+// tables, which are grouped by year and then sorted by term.
+func courseFileData(root string, years []int, tree map[int][]*examdb.File) []courseFile {
+	var files []*examdb.File
+	for _, year := range years {
+		files = append(files, tree[year]...)
+	}
+
+	byTerm := make([]*examdb.File, len(files))
+	copy(byTerm, files)
+	sort.Sort(examdb.FileByTerm(byTerm))
+	termOrder := map[*examdb.File]int{}
+	for i, f := range byTerm {
+		termOrder[f] = i
+	}
+
+	data := make([]courseFile, 0, len(files))
+	for i, f := range files {
+		kind := f.KindName()
+		if len(kind) == 0 {
+			kind = "Other"
+		}
+		data = append(data, courseFile{
+			Name:      f.Name,
+			URL:       root + pathToURL(f.Path),
+			Year:      f.Year,
+			Term:      f.Term,
+			Session:   f.Session().Display(),
+			Kind:      kind,
+			Sample:    f.IsSample,
+			Solution:  f.IsSolution,
+			YearOrder: i,
+			TermOrder: termOrder[f],
+		})
+	}
+	return data
+}
+
 // Course generates a course.
 func (g *Generator) Course(c *examdb.Course) error {
-	// Don't generate courses for unclassified files.
-	if len(c.Code) == 0 {
+	// Don't generate courses for unclassified files or departments that
+	// aren't public.
+	if len(c.Code) == 0 || !g.cfg.PublicDepartment(c.Department()) {
 		return nil
 	}
 
@@ -38,7 +99,10 @@ func (g *Generator) Course(c *examdb.Course) error {
 	}
 
 	files := g.courseFiles[c.Code]
-	potentialFiles := g.coursePotentialFiles[c.Code]
+	var potentialFiles []*examdb.File
+	if !g.excludePotential {
+		potentialFiles = g.coursePotentialFiles[c.Code]
+	}
 	sort.Sort(examdb.FileByYearTermName(potentialFiles))
 
 	fileNames := map[string]string{}
@@ -64,6 +128,15 @@ func (g *Generator) Course(c *examdb.Course) error {
 		PotentialFiles []*examdb.File
 		CompletedML    []*examdb.File
 		PendingML      []*examdb.File
+		Files          []courseFile
+		Previous       []linkedCourse
+		Next           []linkedCourse
+		Listing        *examdb.CatalogEntry
+		FirstListed    int
+		LastListed     int
+		Root           string
+		Static         bool
+		Labels         []string
 	}{
 		Course:         c,
 		PotentialFiles: potentialFiles,
@@ -72,6 +145,35 @@ func (g *Generator) Course(c *examdb.Course) error {
 		FileNames:      fileNames,
 		CompletedML:    completedML,
 		PendingML:      pendingML,
+		Previous:       g.linkedCourses(g.db.PreviousCourses(c.Code), true),
+		Next:           g.linkedCourses(g.db.NextCourses(c.Code), false),
+		Static:         g.static,
+		Labels:         examdb.ExamLabels,
+	}
+	if len(c.Catalog) > 0 {
+		data.Listing = c.LatestCatalog()
+		data.FirstListed = c.Catalog[0].Year
+		data.LastListed = data.Listing.Year
+	}
+	if g.static {
+		data.Root = ".."
+	}
+	data.Files = courseFileData(data.Root, data.YearSections, data.Years)
+
+	if err := g.feed(dir, strings.ToUpper(c.Code)+" Exams", files); err != nil {
+		return err
+	}
+	if err := g.courseAliases(c); err != nil {
+		return err
+	}
+
+	fp := path.Join(dir, "index.html")
+	hash, err := g.pageHash(data, "course.md", "course_filter.html")
+	if err != nil {
+		return err
+	}
+	if !g.pageChanged(fp, hash) {
+		return nil
 	}
 
 	var buf bytes.Buffer
@@ -92,10 +194,72 @@ func (g *Generator) Course(c *examdb.Course) error {
 	if err != nil {
 		return err
 	}
-	styled := g.renderTemplateExam(c.Code, htmlStr)
-	if err := ioutil.WriteFile(path.Join(dir, "index.html"), []byte(styled), 0755); err != nil {
+	styled, err := g.renderTemplateExam("..", c.Code, htmlStr)
+	if err != nil {
 		return err
 	}
+	if err := ioutil.WriteFile(fp, []byte(styled), 0755); err != nil {
+		return err
+	}
+	g.pageWritten(fp, hash)
+	return nil
+}
+
+// linkedCourse is a course that a course replaced or was replaced by.
+type linkedCourse struct {
+	Code  string
+	Name  string
+	Files []*examdb.File
+}
+
+// linkedCourses returns the courses with the codes, including their files if
+// withFiles is set.
+func (g *Generator) linkedCourses(codes []string, withFiles bool) []linkedCourse {
+	var courses []linkedCourse
+	for _, code := range codes {
+		c := linkedCourse{Code: code, Name: strings.ToUpper(code)}
+		if withFiles {
+			c.Files = append(c.Files, g.courseFiles[code]...)
+			sort.Sort(examdb.FileByYearTermName(c.Files))
+		}
+		courses = append(courses, c)
+	}
+	return courses
+}
+
+const aliasRedirect = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%[1]s</title>
+<link rel="canonical" href="%[2]s">
+<meta http-equiv="refresh" content="0; url=%[2]s">
+</head>
+<body>This page has moved to <a href="%[2]s">%[1]s</a>.</body>
+</html>
+`
+
+// courseAliases writes pages redirecting the legacy codes of a course to its
+// page so old links keep working.
+func (g *Generator) courseAliases(c *examdb.Course) error {
+	target := "../" + url.PathEscape(c.Code) + "/"
+	page := []byte(fmt.Sprintf(aliasRedirect, html.EscapeString(strings.ToUpper(c.Code)), html.EscapeString(target)))
+	for _, alias := range c.Aliases {
+		if len(alias) == 0 || alias == c.Code {
+			continue
+		}
+		dir := path.Join(g.examsDir, alias)
+		fp := path.Join(dir, "index.html")
+		if old, err := ioutil.ReadFile(fp); err == nil && bytes.Equal(old, page) {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(fp, page, 0755); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -115,14 +279,28 @@ func (g *Generator) indexCourseFiles() {
 			continue
 		}
 
-		var predicted string
-		if f.Inferred != nil {
-			predicted = f.Inferred.Course
-		} else {
-			predicted = ml.ExtractCourse(g.db, f)
-		}
+		predicted := g.fileCourse(f)
 		potential[predicted] = append(potential[predicted], f)
 	}
 	g.courseFiles = classified
 	g.coursePotentialFiles = potential
 }
+
+// FileCourse returns the code of the course whose page the file is displayed
+// on.
+func (g *Generator) FileCourse(f *examdb.File) string {
+	g.db.Mu.RLock()
+	defer g.db.Mu.RUnlock()
+
+	return g.fileCourse(f)
+}
+
+func (g *Generator) fileCourse(f *examdb.File) string {
+	if f.HandClassified {
+		return f.Course
+	}
+	if f.Inferred != nil {
+		return f.Inferred.Course
+	}
+	return ml.ExtractCourse(g.db, f)
+}
@@ -2,6 +2,7 @@ package generators
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
@@ -11,67 +12,221 @@ import (
 	"github.com/pkg/errors"
 	"github.com/russross/blackfriday"
 	"github.com/ubccsss/exams/config"
+	"github.com/ubccsss/exams/examdb"
 )
 
-// Database generates an index of all courses.
-func (g *Generator) Database() error {
-	dir := g.examsDir
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return errors.Wrapf(err, "mkdirall %q", dir)
-	}
+type levelCourse struct {
+	Name               string
+	Desc               string
+	FileCount          int
+	PotentialFileCount int
+}
+type levelCourses map[string]levelCourse
+type courseLevels map[string]levelCourses
+
+// departmentLink is a link to a department landing page.
+type departmentLink struct {
+	Code string
+	Name string
+	Path string
+}
+
+// departmentDir is the directory of the department's landing page relative to
+// the root of the site. It's kept out of the course directories since
+// department and course codes can collide.
+func departmentDir(d config.Department) string {
+	return path.Join("departments", strings.ToLower(d.Code))
+}
 
-	type course struct {
-		Name               string
-		Desc               string
-		FileCount          int
-		PotentialFileCount int
+// publicDepartments returns links to the landing pages of all public
+// departments.
+func (g *Generator) publicDepartments() []departmentLink {
+	var links []departmentLink
+	for _, d := range g.cfg.Departments {
+		if !d.Public {
+			continue
+		}
+		links = append(links, departmentLink{
+			Code: d.Code,
+			Name: d.Name,
+			Path: departmentDir(d) + "/",
+		})
 	}
-	type courses map[string]course
-	type level map[string]courses
+	return links
+}
 
-	l := level{}
+// courseLevels groups the courses matching include by year level and returns
+// them with the classified files of those courses.
+func (g *Generator) courseLevels(include func(c *examdb.Course) bool) (courseLevels, []*examdb.File) {
+	l := courseLevels{}
+	var files []*examdb.File
 	fileCounts := g.db.CourseFileCount()
+
+	g.db.Mu.RLock()
+	defer g.db.Mu.RUnlock()
+
 	for _, c := range g.db.Courses {
-		if !config.DisplayDepartment[c.Department()] {
+		if !include(c) {
 			continue
 		}
+		files = append(files, g.courseFiles[c.Code]...)
 
 		cl := c.YearLevel()
 		cs, ok := l[cl]
 		if !ok {
-			cs = courses{}
+			cs = levelCourses{}
 			l[cl] = cs
 		}
 		count := fileCounts[c.Code]
-		cs[c.Code] = course{
+		cs[c.Code] = levelCourse{
 			Name:               strings.ToUpper(c.Code),
 			Desc:               c.Desc,
 			FileCount:          count.HandClassified,
 			PotentialFileCount: count.Potential,
 		}
 	}
+	return l, files
+}
 
-	var buf bytes.Buffer
-	if err := Templates.ExecuteTemplate(&buf, "index.md", l); err != nil {
+// Database generates an index of all courses.
+func (g *Generator) Database() error {
+	dir := g.examsDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "mkdirall %q", dir)
+	}
+
+	l, feedFiles := g.courseLevels(func(c *examdb.Course) bool {
+		return g.cfg.PublicDepartment(c.Department())
+	})
+
+	data := struct {
+		Levels        courseLevels
+		Departments   []departmentLink
+		ShowPotential bool
+		Root          string
+	}{
+		Levels:        l,
+		Departments:   g.publicDepartments(),
+		ShowPotential: !g.excludePotential,
+		Root:          ".",
+	}
+
+	if err := g.feed(dir, "UBC Exams Database", feedFiles); err != nil {
+		return errors.Wrap(err, "feed")
+	}
+
+	fp := path.Join(dir, "index.html")
+	hash, err := g.pageHash(data, "index.md", "course_levels.md")
+	if err != nil {
 		return err
 	}
-	html := blackfriday.MarkdownCommon(buf.Bytes())
-	buf.Reset()
-	if _, err := buf.Write(html); err != nil {
+	if !g.pageChanged(fp, hash) {
+		return nil
+	}
+
+	htmlStr, err := renderMarkdown("index.md", data)
+	if err != nil {
 		return err
 	}
-	doc, err := goquery.NewDocumentFromReader(&buf)
+	styled, err := g.renderTemplate(".", "Exams Database", htmlStr)
 	if err != nil {
 		return err
 	}
-	addStyleClasses(doc)
-	htmlStr, err := doc.Html()
+	if err := ioutil.WriteFile(fp, []byte(styled), 0755); err != nil {
+		return err
+	}
+	g.pageWritten(fp, hash)
+	return nil
+}
+
+// Departments generates the landing pages of all public departments.
+func (g *Generator) Departments() error {
+	for _, d := range g.cfg.Departments {
+		if !d.Public {
+			continue
+		}
+		if err := g.Department(d); err != nil {
+			return errors.Wrapf(err, "department %s", d.Code)
+		}
+	}
+	return nil
+}
+
+// Department generates the landing page and feed of a department.
+func (g *Generator) Department(d config.Department) error {
+	dir := path.Join(g.examsDir, departmentDir(d))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "mkdirall %q", dir)
+	}
+
+	l, feedFiles := g.courseLevels(func(c *examdb.Course) bool {
+		dept := g.cfg.Department(c.Department())
+		return dept != nil && dept.Code == d.Code
+	})
+
+	data := struct {
+		config.Department
+		Levels        courseLevels
+		ShowPotential bool
+		Root          string
+	}{
+		Department:    d,
+		Levels:        l,
+		ShowPotential: !g.excludePotential,
+		Root:          "../..",
+	}
+
+	title := d.Name
+	if len(title) == 0 {
+		title = d.Code
+	}
+	if err := g.feed(dir, "UBC "+title+" Exams", feedFiles); err != nil {
+		return errors.Wrap(err, "feed")
+	}
+
+	fp := path.Join(dir, "index.html")
+	hash, err := g.pageHash(data, "department.md", "course_levels.md")
 	if err != nil {
 		return err
 	}
-	styled := g.renderTemplate("Exams Database", htmlStr)
-	if err := ioutil.WriteFile(path.Join(dir, "index.html"), []byte(styled), 0755); err != nil {
+	if !g.pageChanged(fp, hash) {
+		return nil
+	}
+
+	htmlStr, err := renderMarkdown("department.md", data)
+	if err != nil {
+		return err
+	}
+	styled, err := g.renderTemplate("../..", title+" Exams", fmt.Sprintf(
+		`<ol class="breadcrumb"><li><a href="../..">Exams Database</a></li>
+		<li class="active">%s</li>
+		</ol>
+		%s`, title, htmlStr))
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(fp, []byte(styled), 0755); err != nil {
 		return err
 	}
+	g.pageWritten(fp, hash)
 	return nil
 }
+
+// renderMarkdown executes the markdown template and converts it to HTML.
+func renderMarkdown(name string, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := Templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	html := blackfriday.MarkdownCommon(buf.Bytes())
+	buf.Reset()
+	if _, err := buf.Write(html); err != nil {
+		return "", err
+	}
+	doc, err := goquery.NewDocumentFromReader(&buf)
+	if err != nil {
+		return "", err
+	}
+	addStyleClasses(doc)
+	return doc.Html()
+}
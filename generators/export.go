@@ -0,0 +1,227 @@
+package generators
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ubccsss/exams/examdb"
+)
+
+// ExportOptions configures a static export of the site.
+type ExportOptions struct {
+	// BaseURL is the URL the exported site will be hosted at. It's used for
+	// the absolute URLs in sitemap.xml.
+	BaseURL string
+	// ExcludePotential excludes potential and ML inferred files.
+	ExcludePotential bool
+}
+
+// Export renders the public site into dir with relative links so it can be
+// hosted on any static host without the Go server.
+func (g *Generator) Export(dir string, opts ExportOptions) error {
+	start := time.Now()
+
+	base, err := url.Parse(opts.BaseURL)
+	if err != nil {
+		return errors.Wrapf(err, "base url %q", opts.BaseURL)
+	}
+	// Without a trailing slash the last directory would be dropped when
+	// resolving URLs against the base.
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	e := &Generator{
+		db:               g.db,
+		cfg:              g.cfg,
+		examsDir:         dir,
+		baseURL:          base.String(),
+		static:           true,
+		excludePotential: opts.ExcludePotential,
+		pageHashes:       map[string]string{},
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := e.All(); err != nil {
+		return err
+	}
+
+	var files []*examdb.File
+	for _, f := range e.exportedFiles() {
+		if err := copyFile(f.PathOnDisk(g.cfg), path.Join(dir, f.Path)); os.IsNotExist(err) {
+			log.Printf("Skipping missing file %s", f)
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "copying %s", f)
+		}
+		files = append(files, f)
+	}
+
+	for _, asset := range layoutAssets {
+		dst := path.Join(dir, asset)
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := copyFile(path.Join(g.examsDir, asset), dst); err != nil {
+			log.Printf("Failed to copy layout asset %q: %s", asset, err)
+		}
+	}
+
+	if err := e.writeSitemap(base, files); err != nil {
+		return errors.Wrap(err, "sitemap")
+	}
+	if err := e.writeManifest(files); err != nil {
+		return errors.Wrap(err, "manifest")
+	}
+
+	log.Printf("Exported %d files to %q in %s.", len(files), dir, time.Since(start))
+	return nil
+}
+
+// exportedFiles returns all files on disk that are linked to from the
+// generated course pages of public departments.
+func (g *Generator) exportedFiles() []*examdb.File {
+	sources := []map[string][]*examdb.File{g.courseFiles}
+	if !g.excludePotential {
+		sources = append(sources, g.coursePotentialFiles)
+	}
+
+	g.db.Mu.RLock()
+	defer g.db.Mu.RUnlock()
+
+	var files []*examdb.File
+	for _, source := range sources {
+		for code, courseFiles := range source {
+			c, ok := g.db.Courses[code]
+			if !ok || len(code) == 0 || !g.cfg.PublicDepartment(c.Department()) {
+				continue
+			}
+			for _, f := range courseFiles {
+				if f.NotAnExam || len(f.Path) == 0 {
+					continue
+				}
+				files = append(files, f)
+			}
+		}
+	}
+	sort.Sort(examdb.FileByName(files))
+	return files
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemap struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// writeSitemap writes sitemap.xml with the index, the pages of public
+// departments and their courses and all files.
+func (g *Generator) writeSitemap(base *url.URL, files []*examdb.File) error {
+	pages := []string{""}
+	for _, code := range g.db.DisplayCourses() {
+		if len(code) > 0 {
+			pages = append(pages, code+"/")
+		}
+	}
+	for _, d := range g.publicDepartments() {
+		pages = append(pages, d.Path)
+	}
+
+	sort.Strings(pages)
+	for _, f := range files {
+		pages = append(pages, f.Path)
+	}
+
+	s := sitemap{
+		XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9",
+	}
+	for _, page := range pages {
+		loc := base.ResolveReference(&url.URL{Path: page})
+		s.URLs = append(s.URLs, sitemapURL{Loc: loc.String()})
+	}
+
+	raw, err := xml.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	raw = append([]byte(xml.Header), raw...)
+	return ioutil.WriteFile(path.Join(g.examsDir, "sitemap.xml"), raw, 0644)
+}
+
+// manifestFile is a single file in manifest.json.
+type manifestFile struct {
+	Path      string
+	Hash      string
+	Course    string
+	Name      string `json:",omitempty"`
+	Year      int    `json:",omitempty"`
+	Term      string `json:",omitempty"`
+	Potential bool   `json:",omitempty"`
+}
+
+// writeManifest writes manifest.json which lists all exported files.
+func (g *Generator) writeManifest(files []*examdb.File) error {
+	var manifest []manifestFile
+	for _, f := range files {
+		m := manifestFile{
+			Path:   f.Path,
+			Hash:   f.Hash,
+			Course: f.Course,
+			Name:   f.Name,
+			Year:   f.Year,
+			Term:   f.Term,
+		}
+		if f.IsPotential() {
+			m.Potential = true
+			if f.Inferred != nil {
+				m.Course = f.Inferred.Course
+				m.Name = f.Inferred.Name
+				m.Year = f.Inferred.Year
+				m.Term = f.Inferred.Term
+			}
+		}
+		manifest = append(manifest, m)
+	}
+
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(g.examsDir, "manifest.json"), raw, 0644)
+}
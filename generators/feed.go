@@ -0,0 +1,136 @@
+package generators
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ubccsss/exams/examdb"
+)
+
+// feedLength is the max number of entries in a feed.
+const feedLength = 50
+
+// feedFile is the name of the feed in the root and each course directory.
+const feedFile = "feed.atom"
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// classifiedFiles returns the hand classified exams with a classification time
+// ordered from most to least recently classified.
+func classifiedFiles(files []*examdb.File) []*examdb.File {
+	var classified []*examdb.File
+	for _, f := range files {
+		if !f.HandClassified || f.NotAnExam || f.Classified.IsZero() || len(f.Path) == 0 {
+			continue
+		}
+		classified = append(classified, f)
+	}
+	sort.SliceStable(classified, func(i, j int) bool {
+		return classified[i].Classified.After(classified[j].Classified)
+	})
+	if len(classified) > feedLength {
+		classified = classified[:feedLength]
+	}
+	return classified
+}
+
+// siteURL resolves the path relative to the root of the site.
+func (g *Generator) siteURL(p string) string {
+	base, err := url.Parse(g.baseURL)
+	if err != nil {
+		return p
+	}
+	return base.ResolveReference(&url.URL{Path: p}).String()
+}
+
+// feed writes an Atom feed of the most recently classified files to dir.
+func (g *Generator) feed(dir, title string, files []*examdb.File) error {
+	selfPath := strings.TrimPrefix(path.Join(strings.TrimPrefix(dir, g.examsDir), feedFile), "/")
+	pagePath := path.Dir(selfPath) + "/"
+	if pagePath == "./" {
+		pagePath = ""
+	}
+
+	f := atomFeed{
+		Title: title,
+		ID:    g.siteURL(selfPath),
+		Links: []atomLink{
+			{Href: g.siteURL(selfPath), Rel: "self"},
+			{Href: g.siteURL(pagePath), Rel: "alternate", Type: "text/html"},
+		},
+		Author: atomAuthor{Name: "UBC Computer Science Student Society"},
+	}
+
+	var updated time.Time
+	for _, file := range classifiedFiles(files) {
+		if file.Classified.After(updated) {
+			updated = file.Classified
+		}
+		f.Entries = append(f.Entries, atomEntry{
+			Title:   feedEntryTitle(file),
+			ID:      "urn:sha1:" + file.Hash,
+			Link:    atomLink{Href: g.siteURL(file.Path)},
+			Updated: file.Classified.UTC().Format(time.RFC3339),
+			Summary: fmt.Sprintf("%s was added to %s.", file.Name, strings.ToUpper(file.Course)),
+		})
+	}
+	f.Updated = updated.UTC().Format(time.RFC3339)
+
+	raw, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	raw = append([]byte(xml.Header), raw...)
+
+	fp := path.Join(dir, feedFile)
+	if old, err := ioutil.ReadFile(fp); err == nil && bytes.Equal(old, raw) {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fp, raw, 0644)
+}
+
+func feedEntryTitle(f *examdb.File) string {
+	bits := []string{strings.ToUpper(f.Course)}
+	if session := f.Session().String(); len(session) > 0 {
+		bits = append(bits, session)
+	}
+	bits = append(bits, f.Name)
+	return strings.Join(bits, " ")
+}
@@ -1,38 +1,70 @@
 package generators
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"path"
 	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/pkg/errors"
+	"github.com/ubccsss/exams/config"
 	"github.com/ubccsss/exams/examdb"
 	"github.com/ubccsss/exams/workers"
 )
 
+// pageHashesFile is the file next to the database that the page hashes are
+// saved in so later runs don't rewrite unchanged pages either.
+const pageHashesFile = "pagehashes.json"
+
 // Generator contains all generators.
 type Generator struct {
 	db                   *examdb.Database
+	cfg                  *config.Config
 	courseFiles          map[string][]*examdb.File
 	coursePotentialFiles map[string][]*examdb.File
 	layout               string
-	layoutOnce           sync.Once
+	layoutModTime        time.Time
+	layoutMu             sync.Mutex
 	examsDir             string
+	// baseURL is the absolute URL of the site used for links in feeds.
+	baseURL string
+
+	// static is set when rendering a standalone copy of the site with relative
+	// links and no upload form.
+	static bool
+	// excludePotential hides potential and ML inferred files.
+	excludePotential bool
+
+	// pageHashes maps the path of a generated page to the hash of the inputs
+	// it was last rendered from.
+	pageHashes   map[string]string
+	pageHashesMu sync.RWMutex
+	// pageHashesPath is where the page hashes are saved, or empty if they
+	// aren't.
+	pageHashesPath string
 }
 
 // MakeGenerator creates a new generator and loads all data required.
-func MakeGenerator(db *examdb.Database, examsDir string) (*Generator, error) {
+func MakeGenerator(db *examdb.Database, cfg *config.Config) (*Generator, error) {
+	if err := LoadTemplates(cfg.TemplateDir); err != nil {
+		return nil, err
+	}
 	g := &Generator{
-		db:       db,
-		examsDir: examsDir,
+		db:             db,
+		cfg:            cfg,
+		examsDir:       cfg.ExamsDir,
+		baseURL:        cfg.SiteURL,
+		pageHashes:     map[string]string{},
+		pageHashesPath: path.Join(path.Dir(cfg.DBFile), pageHashesFile),
 	}
-
-	go func() {
-		if err := g.fetchLayout(); err != nil {
-			log.Println(err)
-		}
-	}()
+	g.loadPageHashes()
 
 	return g, nil
 }
@@ -54,6 +86,10 @@ func (g *Generator) All() error {
 			dbErr = errors.Wrap(err, "database")
 			return
 		}
+		if err := g.Departments(); err != nil {
+			dbErr = errors.Wrap(err, "departments")
+			return
+		}
 		log.Printf("Generated index in %s.", time.Since(start))
 	}()
 
@@ -90,7 +126,159 @@ func (g *Generator) All() error {
 
 	log.Printf("Generated in %s. Course pages in %s.", time.Since(start), time.Since(startCourses))
 
-	return nil
+	return g.savePageHashes()
+}
+
+// Courses regenerates the index, the department pages and the pages for the
+// specified course codes. Legacy codes like cs221 are resolved to the course
+// they refer to.
+// Empty course codes are ignored.
+func (g *Generator) Courses(codes ...string) error {
+	start := time.Now()
+	g.indexCourseFiles()
+
+	if err := g.Database(); err != nil {
+		return errors.Wrap(err, "database")
+	}
+	if err := g.Departments(); err != nil {
+		return errors.Wrap(err, "departments")
+	}
+
+	// Pages of the courses that replaced a course show its files too.
+	seen := map[string]bool{}
+	var expanded []string
+	for _, code := range codes {
+		code, _ = g.db.ResolveCourse(code)
+		for _, code := range append([]string{code}, g.db.NextCourses(code)...) {
+			if !seen[code] {
+				seen[code] = true
+				expanded = append(expanded, code)
+			}
+		}
+	}
+
+	for _, code := range expanded {
+		if len(code) == 0 {
+			continue
+		}
+
+		g.db.Mu.RLock()
+		course, ok := g.db.Courses[code]
+		g.db.Mu.RUnlock()
+		// The other courses are still generated, e.g. after a file was
+		// classified under a course that was removed since.
+		if !ok {
+			log.Printf("Skipping unknown course %q", code)
+			continue
+		}
+
+		if err := g.Course(course); err != nil {
+			return errors.Wrapf(err, "course %+v", course)
+		}
+	}
+
+	log.Printf("Generated %q in %s.", codes, time.Since(start))
+
+	return g.savePageHashes()
+}
+
+// Invalidate forgets all page hashes so the next generation rewrites every
+// page.
+func (g *Generator) Invalidate() {
+	g.pageHashesMu.Lock()
+	defer g.pageHashesMu.Unlock()
+
+	g.pageHashes = map[string]string{}
+}
+
+// pageHash returns a hash of the layout, templates and data used to render a
+// page.
+func (g *Generator) pageHash(data interface{}, templateNames ...string) (string, error) {
+	layout, err := g.loadLayout()
+	if err != nil {
+		return "", err
+	}
+	hasher := sha1.New()
+	for _, templateName := range templateNames {
+		fi, err := os.Stat(path.Join(g.cfg.TemplateDir, templateName))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hasher, "%s %d\n", templateName, fi.ModTime().UnixNano())
+	}
+	hasher.Write([]byte(layout))
+	if err := json.NewEncoder(hasher).Encode(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// pageChanged returns whether the page at fp needs to be rewritten since it
+// was last rendered from different inputs or is missing on disk.
+func (g *Generator) pageChanged(fp, hash string) bool {
+	g.pageHashesMu.RLock()
+	old, ok := g.pageHashes[fp]
+	g.pageHashesMu.RUnlock()
+
+	if !ok || old != hash {
+		return true
+	}
+	if _, err := os.Stat(fp); err != nil {
+		return true
+	}
+	return false
+}
+
+// pageWritten records that the page at fp was rendered from inputs with hash.
+func (g *Generator) pageWritten(fp, hash string) {
+	g.pageHashesMu.Lock()
+	defer g.pageHashesMu.Unlock()
+
+	g.pageHashes[fp] = hash
+}
+
+// loadPageHashes loads the page hashes saved by an earlier run. If they can't
+// be loaded every page is rewritten.
+func (g *Generator) loadPageHashes() {
+	raw, err := ioutil.ReadFile(g.pageHashesPath)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		log.Printf("Failed to load page hashes: %s", err)
+		return
+	}
+
+	g.pageHashesMu.Lock()
+	defer g.pageHashesMu.Unlock()
+
+	if err := json.Unmarshal(raw, &g.pageHashes); err != nil {
+		log.Printf("Failed to load page hashes: %s", err)
+		g.pageHashes = map[string]string{}
+	}
+}
+
+// savePageHashes saves the page hashes for later runs.
+func (g *Generator) savePageHashes() error {
+	if len(g.pageHashesPath) == 0 {
+		return nil
+	}
+
+	g.pageHashesMu.RLock()
+	raw, err := json.Marshal(g.pageHashes)
+	g.pageHashesMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(g.pageHashesPath), 0755); err != nil {
+		return err
+	}
+	// Like the database, it's written to a temporary file first so a crash
+	// can't leave it partially written.
+	tmp := g.pageHashesPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, g.pageHashesPath)
 }
 
 func addStyleClasses(sel *goquery.Document) {
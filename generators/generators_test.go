@@ -0,0 +1,159 @@
+package generators
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ubccsss/exams/config"
+	"github.com/ubccsss/exams/examdb"
+)
+
+// testGenerator makes a generator for the database that writes to a
+// temporary directory, which the returned function removes.
+func testGenerator(t *testing.T, db *examdb.Database) (*Generator, func()) {
+	dir, err := ioutil.TempDir("", "generators")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.TemplateDir = "../templates"
+	cfg.LayoutFile = "../templates/layout.html"
+	cfg.ExamsDir = dir
+	cfg.DBFile = path.Join(dir, "exams.json")
+	db.Config = cfg
+
+	g, err := MakeGenerator(db, cfg)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return g, func() { os.RemoveAll(dir) }
+}
+
+func TestCoursesLegacyCode(t *testing.T) {
+	db := &examdb.Database{
+		Courses: map[string]*examdb.Course{
+			"cpsc 221": {Code: "cpsc 221"},
+		},
+	}
+	g, cleanup := testGenerator(t, db)
+	defer cleanup()
+
+	if err := g.Courses("cs221"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(g.examsDir, "cpsc 221", "index.html")); err != nil {
+		t.Errorf("course page for cs221 wasn't generated: %s", err)
+	}
+	if err := g.Courses("cs999", "cs221"); err != nil {
+		t.Errorf("unknown courses should be skipped; got %s", err)
+	}
+}
+
+func TestCourseUploadLabels(t *testing.T) {
+	db := &examdb.Database{
+		Courses: map[string]*examdb.Course{
+			"cpsc 110": {Code: "cpsc 110"},
+		},
+	}
+	g, cleanup := testGenerator(t, db)
+	defer cleanup()
+
+	if err := g.Course(db.Courses["cpsc 110"]); err != nil {
+		t.Fatal(err)
+	}
+	page, err := ioutil.ReadFile(path.Join(g.examsDir, "cpsc 110", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range examdb.ExamLabels {
+		if !strings.Contains(string(page), "<option>"+label+"</option>") {
+			t.Errorf("upload form is missing label %q", label)
+		}
+	}
+}
+
+func TestExportPrivateDepartment(t *testing.T) {
+	db := &examdb.Database{
+		Courses: map[string]*examdb.Course{
+			"cpsc 110": {Code: "cpsc 110"},
+			"law 100":  {Code: "law 100"},
+		},
+	}
+	g, cleanup := testGenerator(t, db)
+	defer cleanup()
+	g.cfg.Department("LAW").Public = false
+
+	dir := path.Join(g.examsDir, "export")
+	if err := g.Export(dir, ExportOptions{BaseURL: "https://example.com/"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(dir, "cpsc 110", "index.html")); err != nil {
+		t.Errorf("public course wasn't exported: %s", err)
+	}
+	if _, err := os.Stat(path.Join(dir, "law 100")); !os.IsNotExist(err) {
+		t.Errorf("course of a private department was exported: %v", err)
+	}
+	sitemap, err := ioutil.ReadFile(path.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(sitemap), "https://example.com/cpsc%20110/") || strings.Contains(string(sitemap), "law") {
+		t.Errorf("sitemap should only list public courses:\n%s", sitemap)
+	}
+}
+
+func TestExportBaseURLWithoutSlash(t *testing.T) {
+	db := &examdb.Database{
+		Courses: map[string]*examdb.Course{
+			"cpsc 110": {Code: "cpsc 110"},
+		},
+	}
+	g, cleanup := testGenerator(t, db)
+	defer cleanup()
+
+	dir := path.Join(g.examsDir, "export")
+	if err := g.Export(dir, ExportOptions{BaseURL: "https://example.com/exams"}); err != nil {
+		t.Fatal(err)
+	}
+	sitemap, err := ioutil.ReadFile(path.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(sitemap), "https://example.com/exams/cpsc%20110/") {
+		t.Errorf("sitemap URLs should be under the base URL:\n%s", sitemap)
+	}
+}
+
+func TestPageHashesPersisted(t *testing.T) {
+	db := &examdb.Database{
+		Courses: map[string]*examdb.Course{
+			"cpsc 110": {Code: "cpsc 110"},
+		},
+	}
+	g, cleanup := testGenerator(t, db)
+	defer cleanup()
+
+	if err := g.Courses("cpsc 110"); err != nil {
+		t.Fatal(err)
+	}
+	fp := path.Join(g.examsDir, "cpsc 110", "index.html")
+	if err := ioutil.WriteFile(fp, []byte("unchanged"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new generator, like one of a later run, doesn't rewrite the page.
+	g2, err := MakeGenerator(db, g.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g2.Courses("cpsc 110"); err != nil {
+		t.Fatal(err)
+	}
+	if page, err := ioutil.ReadFile(fp); err != nil || string(page) != "unchanged" {
+		t.Errorf("expected the unchanged page not to be rewritten; got %q, %v", page, err)
+	}
+}
@@ -8,37 +8,45 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/howeyc/fsnotify"
+	"github.com/pkg/errors"
 	"github.com/russross/blackfriday"
-	"github.com/ubccsss/exams/config"
 )
 
 // Templates are all of the HTML templates needed.
 var Templates *template.Template
 
 var templateFuncs = template.FuncMap{
-	"pathToURL": func(fp string) string {
-		base := path.Base(fp)
-		rest := path.Dir(fp)
-		return path.Join("/", rest, url.PathEscape(base))
-	},
+	"pathToURL": pathToURL,
+	"join":      strings.Join,
 }
 
-func updateTemplates() {
-	log.Printf("%s/: changed! Loading templates...", config.TemplateDir)
+// pathToURL converts the path of a file to an absolute URL path.
+func pathToURL(fp string) string {
+	base := path.Base(fp)
+	rest := path.Dir(fp)
+	return path.Join("/", rest, url.PathEscape(base))
+}
+
+func updateTemplates(dir string) error {
 	t := template.New("templates")
 	t.Funcs(templateFuncs)
-	template.Must(t.ParseGlob(config.TemplateGlob))
+	if _, err := t.ParseGlob(path.Join(dir, "*")); err != nil {
+		return err
+	}
 	Templates = t
+	return nil
 }
 
-func updateTemplatesDebounced() chan struct{} {
+func updateTemplatesDebounced(dir string) chan struct{} {
 	ch := make(chan struct{})
 	var timer *time.Timer
 
@@ -47,20 +55,40 @@ func updateTemplatesDebounced() chan struct{} {
 			if timer != nil {
 				timer.Stop()
 			}
-			timer = time.AfterFunc(300*time.Millisecond, updateTemplates)
+			timer = time.AfterFunc(300*time.Millisecond, func() {
+				log.Printf("%s/: changed! Loading templates...", dir)
+				if err := updateTemplates(dir); err != nil {
+					log.Printf("Failed to load templates: %s", err)
+				}
+			})
 		}
 	}()
 
 	return ch
 }
 
-func init() {
-	updateTemplates()
-	update := updateTemplatesDebounced()
+var loadTemplatesOnce sync.Once
+
+// LoadTemplates parses the templates in dir and reloads them whenever they
+// change. Only the first call has any effect.
+func LoadTemplates(dir string) error {
+	var err error
+	loadTemplatesOnce.Do(func() {
+		err = watchTemplates(dir)
+	})
+	return err
+}
+
+func watchTemplates(dir string) error {
+	log.Printf("%s/: Loading templates...", dir)
+	if err := updateTemplates(dir); err != nil {
+		return errors.Wrapf(err, "templates %q", dir)
+	}
+	update := updateTemplatesDebounced(dir)
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	go func() {
@@ -74,14 +102,12 @@ func init() {
 		}
 	}()
 
-	if err := watcher.Watch(config.TemplateDir); err != nil {
-		log.Fatal(err)
-	}
+	return watcher.Watch(dir)
 }
 
-func (g *Generator) renderTemplateExam(title, content string) string {
+func (g *Generator) renderTemplateExam(root, title, content string) (string, error) {
 	title = strings.ToUpper(title)
-	return g.renderTemplate(title, fmt.Sprintf(
+	return g.renderTemplate(root, title, fmt.Sprintf(
 		`<ol class="breadcrumb"><li><a href="..">Exams Database</a></li>
 		<li class="active">%s</li>
 		</ol>
@@ -93,150 +119,252 @@ func (g *Generator) renderTemplateExam(title, content string) string {
 		</div>`, title, content))
 }
 
-func (g *Generator) renderTemplate(title, content string) string {
-	if err := g.fetchLayout(); err != nil {
-		log.Println(err)
+// renderTemplate wraps the content in the layout. root is the relative path
+// from the page to the root of the site and is only used for static sites.
+func (g *Generator) renderTemplate(root, title, content string) (string, error) {
+	layout, err := g.loadLayout()
+	if err != nil {
+		return "", err
+	}
+	if g.static {
+		layout = relativeLayout(layout, root)
 	}
-	return fmt.Sprintf(g.layout, title, content)
+	return fmt.Sprintf(layout, title, content), nil
 }
 
-const templateURL = "https://ubccsss.org/services/"
+// layoutAssets are the files the layout links to at the root of the site.
+var layoutAssets = []string{"style.css", "scripts.js"}
 
-var importRegexp = regexp.MustCompile("@import .*;")
+// relativeLayout rewrites the absolute links to the layout assets to be
+// relative to root.
+func relativeLayout(layout, root string) string {
+	for _, asset := range layoutAssets {
+		layout = strings.Replace(layout, `"/`+asset+`"`, `"`+root+`/`+asset+`"`, -1)
+	}
+	return layout
+}
 
-func (g *Generator) fetchLayout() error {
-	var err error
+// loadLayout returns the layout used to wrap all generated pages. The local
+// layout is reloaded whenever it changes on disk, the remote layout is only
+// fetched once.
+func (g *Generator) loadLayout() (string, error) {
+	g.layoutMu.Lock()
+	defer g.layoutMu.Unlock()
 
-	g.layoutOnce.Do(func() {
-		start := time.Now()
-		log.Printf("Fetching layout template from %q", templateURL)
-		base, err2 := url.Parse(templateURL)
-		if err2 != nil {
-			err = err2
-			return
-		}
-		doc, err2 := goquery.NewDocument(templateURL)
-		if err2 != nil {
-			err = err2
-			return
+	if g.cfg.RemoteLayout {
+		if len(g.layout) == 0 {
+			layout, err := g.fetchLayoutOrCached()
+			if err != nil {
+				return "", err
+			}
+			g.layout = layout
 		}
+		return g.layout, nil
+	}
 
-		// Clean metadata.
-		doc.Find(`link[rel="shortlink"], link[rel="canonical"], meta[name="Generator"]`).Remove()
+	fi, err := os.Stat(g.cfg.LayoutFile)
+	if err != nil {
+		return "", err
+	}
+	if len(g.layout) > 0 && fi.ModTime().Equal(g.layoutModTime) {
+		return g.layout, nil
+	}
+	raw, err := ioutil.ReadFile(g.cfg.LayoutFile)
+	if err != nil {
+		return "", err
+	}
+	layout := string(raw)
+	if err := validateLayout(layout); err != nil {
+		return "", errors.Wrapf(err, "layout %q", g.cfg.LayoutFile)
+	}
+	g.layout = layout
+	g.layoutModTime = fi.ModTime()
+	return g.layout, nil
+}
 
-		// Resolve URLs.
-		doc.Find("a[href], link[href]").Each(func(_ int, s *goquery.Selection) {
-			raw := s.AttrOr("href", "")
-			// Don't resolve cloud flare email protection.
-			if strings.HasPrefix(raw, "/cdn-cgi/l/email-protection") {
-				return
-			}
-			url, err := url.Parse(raw)
-			if err != nil {
-				return
-			}
-			resolved := base.ResolveReference(url)
-			s.SetAttr("href", resolved.String())
-		})
-
-		doc.Find("script[src], img[src]").Each(func(_ int, s *goquery.Selection) {
-			url, err2 := url.Parse(s.AttrOr("src", ""))
-			if err2 != nil {
-				err = err2
-				return
-			}
-			resolved := base.ResolveReference(url)
-			s.SetAttr("src", resolved.String())
-		})
-
-		var importBuf bytes.Buffer
-		var buf bytes.Buffer
-
-		// Package all CSS and scripts into one file.
-		stylesheets := doc.Find(`link[href][rel="stylesheet"]`)
-		stylesheets.Each(func(_ int, s *goquery.Selection) {
-			resp, err2 := http.Get(s.AttrOr("href", ""))
-			if err2 != nil {
-				err = err2
-				return
-			}
-			defer resp.Body.Close()
-			body, _ := ioutil.ReadAll(resp.Body)
-			lastIdx := 0
-			for _, match := range importRegexp.FindAllIndex(body, -1) {
-				buf.Write(body[lastIdx:match[0]])
-				importBuf.Write(body[match[0]:match[1]])
-				lastIdx = match[1]
-			}
-			buf.Write(body[lastIdx:len(body)])
-			buf.WriteRune('\n')
-		})
-		if err != nil {
-			return
+// validateLayout checks that the layout has exactly the two %s verbs for the
+// title and content and no other formatting verbs.
+func validateLayout(layout string) error {
+	if n := strings.Count(strings.Replace(layout, "%%", "", -1), "%s"); n != 2 {
+		return errors.Errorf("expected 2 %%s placeholders for the title and content; found %d", n)
+	}
+	if rendered := fmt.Sprintf(layout, "", ""); strings.Contains(rendered, "%!") {
+		return errors.New("layout contains invalid formatting verbs, literal %'s should be escaped as %%")
+	}
+	return nil
+}
+
+// fetchLayoutOrCached scrapes the layout from the layout URL and caches it
+// to disk. If that fails, the last good cached layout is used.
+func (g *Generator) fetchLayoutOrCached() (string, error) {
+	layout, err := g.fetchLayout()
+	if err == nil {
+		err = validateLayout(layout)
+	}
+	if err == nil {
+		if err := os.MkdirAll(path.Dir(g.cfg.LayoutCacheFile), 0755); err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(g.cfg.LayoutCacheFile, []byte(layout), 0644); err != nil {
+			return "", err
 		}
+		return layout, nil
+	}
+
+	log.Printf("Failed to fetch layout from %q, falling back to %q: %s", g.cfg.LayoutURL, g.cfg.LayoutCacheFile, err)
+	raw, err2 := ioutil.ReadFile(g.cfg.LayoutCacheFile)
+	if err2 != nil {
+		return "", errors.Wrapf(err, "no cached layout: %s", err2)
+	}
+	layout = string(raw)
+	if err := validateLayout(layout); err != nil {
+		return "", errors.Wrapf(err, "layout %q", g.cfg.LayoutCacheFile)
+	}
+	return layout, nil
+}
+
+var importRegexp = regexp.MustCompile("@import .*;")
 
-		stylesheet := stylesheets.First()
-		stylesheet.SetAttr("href", "/style.css")
-		stylesheet.RemoveAttr("integrity")
-		stylesheets.Slice(1, stylesheets.Length()).Remove()
+// Markers for where the title and content go in the scraped layout. They're
+// swapped for %s after any literal %'s have been escaped.
+const (
+	layoutTitleMarker   = "EXAMS_LAYOUT_TITLE"
+	layoutContentMarker = "EXAMS_LAYOUT_CONTENT"
+)
+
+// fetchLayout scrapes the layout from the layout URL and packages all CSS
+// and scripts into style.css and scripts.js.
+func (g *Generator) fetchLayout() (string, error) {
+	start := time.Now()
+	log.Printf("Fetching layout template from %q", g.cfg.LayoutURL)
+	base, err := url.Parse(g.cfg.LayoutURL)
+	if err != nil {
+		return "", err
+	}
+	doc, err := goquery.NewDocument(g.cfg.LayoutURL)
+	if err != nil {
+		return "", err
+	}
 
-		if _, err = buf.WriteTo(&importBuf); err != nil {
+	// Clean metadata.
+	doc.Find(`link[rel="shortlink"], link[rel="canonical"], meta[name="Generator"]`).Remove()
+
+	// Resolve URLs.
+	doc.Find("a[href], link[href]").Each(func(_ int, s *goquery.Selection) {
+		raw := s.AttrOr("href", "")
+		// Don't resolve cloud flare email protection.
+		if strings.HasPrefix(raw, "/cdn-cgi/l/email-protection") {
 			return
 		}
-
-		if err = ioutil.WriteFile(path.Join(g.examsDir, "style.css"), importBuf.Bytes(), 0755); err != nil {
+		url, err := url.Parse(raw)
+		if err != nil {
 			return
 		}
+		resolved := base.ResolveReference(url)
+		s.SetAttr("href", resolved.String())
+	})
 
-		importBuf.Reset()
-		buf.Reset()
-
-		scripts := doc.Find(`script[src]`)
-		scripts.Each(func(_ int, s *goquery.Selection) {
-			resp, err2 := http.Get(s.AttrOr("src", ""))
-			if err2 != nil {
-				err = err2
-				return
-			}
-			defer resp.Body.Close()
-			buf.ReadFrom(resp.Body)
-			buf.WriteRune('\n')
-		})
-		if err != nil {
+	doc.Find("script[src], img[src]").Each(func(_ int, s *goquery.Selection) {
+		url, err2 := url.Parse(s.AttrOr("src", ""))
+		if err2 != nil {
+			err = err2
 			return
 		}
+		resolved := base.ResolveReference(url)
+		s.SetAttr("src", resolved.String())
+	})
+	if err != nil {
+		return "", err
+	}
 
-		scripts.First().SetAttr("src", "/scripts.js")
-		scripts.Slice(1, scripts.Length()).Remove()
+	var importBuf bytes.Buffer
+	var buf bytes.Buffer
 
-		if err = ioutil.WriteFile(path.Join(g.examsDir, "scripts.js"), buf.Bytes(), 0755); err != nil {
+	// Package all CSS and scripts into one file.
+	stylesheets := doc.Find(`link[href][rel="stylesheet"]`)
+	stylesheets.Each(func(_ int, s *goquery.Selection) {
+		resp, err2 := http.Get(s.AttrOr("href", ""))
+		if err2 != nil {
+			err = err2
 			return
 		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		lastIdx := 0
+		for _, match := range importRegexp.FindAllIndex(body, -1) {
+			buf.Write(body[lastIdx:match[0]])
+			importBuf.Write(body[match[0]:match[1]])
+			lastIdx = match[1]
+		}
+		buf.Write(body[lastIdx:len(body)])
+		buf.WriteRune('\n')
+	})
+	if err != nil {
+		return "", err
+	}
+
+	stylesheet := stylesheets.First()
+	stylesheet.SetAttr("href", "/style.css")
+	stylesheet.RemoveAttr("integrity")
+	stylesheets.Slice(1, stylesheets.Length()).Remove()
+
+	if _, err := buf.WriteTo(&importBuf); err != nil {
+		return "", err
+	}
 
-		title := doc.Find("title")
-		parts := strings.Split(title.Text(), "|")
-		title.ReplaceWithHtml("<title>%s |" + parts[len(parts)-1] + "</title>")
+	if err := ioutil.WriteFile(path.Join(g.examsDir, "style.css"), importBuf.Bytes(), 0755); err != nil {
+		return "", err
+	}
 
-		section := doc.Find("body > .container .row")
-		children := section.Children()
-		children.First().ReplaceWithHtml(`<div>%s</div>`)
-		children.Remove()
+	importBuf.Reset()
+	buf.Reset()
 
-		layout, err2 := doc.Html()
+	scripts := doc.Find(`script[src]`)
+	scripts.Each(func(_ int, s *goquery.Selection) {
+		resp, err2 := http.Get(s.AttrOr("src", ""))
 		if err2 != nil {
 			err = err2
 			return
 		}
+		defer resp.Body.Close()
+		buf.ReadFrom(resp.Body)
+		buf.WriteRune('\n')
+	})
+	if err != nil {
+		return "", err
+	}
 
-		// This replaces the ubccsss.org Google Analytics code with the one for
-		// exams.ubccsss.org.
-		layout = strings.Replace(layout, "UA-88004303-1", "UA-88004303-3", -1)
+	scripts.First().SetAttr("src", "/scripts.js")
+	scripts.Slice(1, scripts.Length()).Remove()
 
-		g.layout = layout
-		log.Printf("Fetched layout template. Took %s", time.Since(start))
-	})
+	if err := ioutil.WriteFile(path.Join(g.examsDir, "scripts.js"), buf.Bytes(), 0755); err != nil {
+		return "", err
+	}
 
-	return err
+	title := doc.Find("title")
+	parts := strings.Split(title.Text(), "|")
+	title.ReplaceWithHtml("<title>" + layoutTitleMarker + " |" + parts[len(parts)-1] + "</title>")
+
+	section := doc.Find("body > .container .row")
+	children := section.Children()
+	children.First().ReplaceWithHtml(`<div>` + layoutContentMarker + `</div>`)
+	children.Remove()
+
+	layout, err := doc.Html()
+	if err != nil {
+		return "", err
+	}
+
+	// This replaces the ubccsss.org Google Analytics code with the one for
+	// exams.ubccsss.org.
+	layout = strings.Replace(layout, "UA-88004303-1", "UA-88004303-3", -1)
+
+	layout = strings.Replace(layout, "%", "%%", -1)
+	layout = strings.Replace(layout, layoutTitleMarker, "%s", 1)
+	layout = strings.Replace(layout, layoutContentMarker, "%s", 1)
+
+	log.Printf("Fetched layout template. Took %s", time.Since(start))
+	return layout, nil
 }
 
 // ExecuteTemplate runs a template and writes it to w.
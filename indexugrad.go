@@ -15,9 +15,16 @@ import (
 const SSHTimeout = 60
 
 func indexUGrad(c *cli.Context) error {
+	user, server := cfg.Ugrad.User, cfg.Ugrad.IndexHost
+	if c.IsSet("user") {
+		user = c.String("user")
+	}
+	if c.IsSet("server") {
+		server = c.String("server")
+	}
 	ssh := &easyssh.MakeConfig{
-		User:   c.String("user"),
-		Server: c.String("server"),
+		User:   user,
+		Server: server,
 		// Optional key or Password without either we try to contact your agent SOCKET
 		Key:  "/.ssh/id_rsa",
 		Port: "22",
@@ -20,9 +20,9 @@ import (
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/ubccsss/exams/archive.org"
+	"github.com/ubccsss/exams/catalog"
 	"github.com/ubccsss/exams/config"
 	"github.com/ubccsss/exams/examdb"
-	"github.com/ubccsss/exams/ml"
 	"github.com/ubccsss/exams/workers"
 	"github.com/urfave/cli"
 )
@@ -32,7 +32,7 @@ func ingressDeptCourses(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Fprintf(w, "Fetching from ugrad servers...\n")
 
-	resp, err := exec.Command("ssh", "q7w9a@remote.ugrad.cs.ubc.ca", "-C", "ls /home/c").Output()
+	resp, err := exec.Command("ssh", cfg.Ugrad.User+"@"+cfg.Ugrad.SSHHost, "-C", "ls /home/c").Output()
 	if err != nil {
 		fmt.Fprintf(w, "%+v\n", err)
 	} else {
@@ -46,7 +46,8 @@ func ingressDeptCourses(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	for _, dept := range config.Departments {
+	for _, d := range cfg.Departments {
+		dept := d.Code
 		fmt.Fprintf(w, "Fetching courses for: %s\n", dept)
 		fmt.Fprintf(w, "Fetching from courses.students.ubc.ca...\n")
 
@@ -60,28 +61,19 @@ func ingressDeptCourses(w http.ResponseWriter, r *http.Request) {
 				link := tds.Find("a")
 				linkTitle := strings.ToLower(strings.TrimSpace(link.Text()))
 				if strings.HasPrefix(linkTitle, strings.ToLower(dept)+" ") {
-					course := linkTitle
-					// TODO(d4l3k): Remove special case for CS.
-					if dept == config.ComputerScience {
-						course = "cs" + linkTitle[5:]
-					}
 					desc := strings.TrimSpace(tds.Eq(1).Text())
-					db.AddCourse(w, course, desc)
+					db.AddCourse(w, linkTitle, desc)
 				}
 			})
 		}
 
 		fmt.Fprintf(w, "Fetching from http://www.calendar.ubc.ca/archive/vancouver/...\n")
 
-		coursesRegexp, err := regexp.Compile(fmt.Sprintf(`^%s\s+(\d{3})\s+\(.+\)\s+(\w)?\s+(.+)$`, dept))
-		if err != nil {
-			fmt.Fprintf(w, "%+v\n", err)
-			return
-		}
-
 		currentYear := time.Now().Year()
 		lastTwoYear := currentYear - (currentYear/100)*100
 		for i := lastTwoYear; i >= 2; i-- {
+			// The calendar of the 2016/17 session is in 1617.
+			year := 2000 + i
 			url := fmt.Sprintf("http://www.calendar.ubc.ca/archive/vancouver/%.2d%.2d/courses.html", i, i+1)
 			doc, err := goquery.NewDocument(url)
 			if err != nil {
@@ -113,22 +105,17 @@ func ingressDeptCourses(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			coursesDoc.Find("dl > dt").Each(func(_ int, s *goquery.Selection) {
-				text := s.Text()
-				matches := coursesRegexp.FindStringSubmatch(text)
-				if len(matches) != 4 {
-					return
-				}
-				courseNumber := matches[1] + matches[2]
-				course := dept + " " + courseNumber
-				// TODO(d4l3k): Remove special case for CS.
-				if dept == config.ComputerScience {
-					course = "cs" + courseNumber
-				}
-				desc := matches[3]
-				fmt.Fprintf(w, "%s: %s\n", course, desc)
-				db.AddCourse(w, course, desc)
-			})
+			listings, err := catalog.ParseCalendar(coursesDoc, dept)
+			if err != nil {
+				fmt.Fprintf(w, "%+v\n", err)
+				continue
+			}
+			for _, l := range listings {
+				course := examdb.CanonicalCourseCode(cfg, dept+" "+l.Number)
+				l.Year = year
+				fmt.Fprintf(w, "%s (%d): %s\n", course, year, l.Title)
+				db.AddCatalogEntry(w, course, l.CatalogEntry)
+			}
 		}
 	}
 
@@ -163,7 +150,7 @@ func getURLForLink(doc *goquery.Document, text string) string {
 // ingressDeptFiles talks to the exams.cgi binary running on the ugrad servers and
 // returns potential file matches.
 func ingressDeptFiles(w http.ResponseWriter, r *http.Request) {
-	req, err := http.Get("https://www.ugrad.cs.ubc.ca/~q7w9a/exams.cgi/")
+	req, err := http.Get(cfg.Ugrad.ExamsCGIURL)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
@@ -174,7 +161,7 @@ func ingressDeptFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	for _, f := range files {
-		strippedPath := strings.TrimPrefix(f.Path, "https://www.ugrad.cs.ubc.ca/~q7w9a/exams.cgi")
+		strippedPath := strings.TrimPrefix(f.Path, strings.TrimSuffix(cfg.Ugrad.ExamsCGIURL, "/"))
 		url, ok := ugradPathToHTTP(strippedPath)
 		if ok {
 			f.Source = url
@@ -224,7 +211,7 @@ func ingressUBCCSSS(w http.ResponseWriter, r *http.Request) {
 	})
 
 	for _, page := range examPages {
-		courseCode := strings.ToLower("cs" + path.Base(page)[4:])
+		courseCode := examdb.CanonicalCourseCode(cfg, path.Base(page))
 		fmt.Fprintf(w, "Loading %s: %s ...\n", courseCode, page)
 		doc, err := goquery.NewDocument(page)
 		if err != nil {
@@ -316,17 +303,10 @@ func ingressUBCMath(w http.ResponseWriter, r *http.Request) {
 			if len(matches) < 3 {
 				return
 			}
-			year, _ := ml.ExtractYearFromWords(matches)
-			var term string
-			switch matches[2] {
-			case "WT1":
-				term = examdb.TermW1
-			case "WT2":
-				term = examdb.TermW2
-			case "S":
-				term = examdb.TermS
-			default:
-				log.Fatalf("invalid term: %#v", matches)
+			session, err := examdb.ParseSession(matches[1] + matches[2])
+			if err != nil {
+				fmt.Fprintf(w, "%+v\n", err)
+				return
 			}
 
 			u, err := url.Parse(s.AttrOr("href", ""))
@@ -338,12 +318,12 @@ func ingressUBCMath(w http.ResponseWriter, r *http.Request) {
 
 			f := examdb.File{
 				Course:         code,
-				Year:           year,
-				Term:           term,
 				Name:           "Final",
 				Source:         absURL,
 				HandClassified: true,
+				Classified:     time.Now(),
 			}
+			f.SetSession(session)
 
 			fmt.Fprintf(w, "%#v\n", f)
 			filesChan <- &f
@@ -404,12 +384,11 @@ func ingressUBCLaw(w http.ResponseWriter, r *http.Request) {
 		}
 
 		date := strings.Split(doc.Find("h1").Text(), " – ")[0]
-		t, err := time.Parse("2006 January", date)
+		session, err := examdb.ParseSession(date)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
-		year, term := ml.ConvertDateToYearTerm(t)
 
 		doc.Find(".entry-content table a[href]").Each(func(_ int, s *goquery.Selection) {
 			code := strings.TrimSpace(strings.ToLower(strings.Split(s.Text(), ":")[0]))
@@ -422,12 +401,12 @@ func ingressUBCLaw(w http.ResponseWriter, r *http.Request) {
 
 			f := examdb.File{
 				Course:         code,
-				Year:           year,
-				Term:           term,
 				Name:           "Final",
 				Source:         absURL,
 				HandClassified: true,
+				Classified:     time.Now(),
 			}
+			f.SetSession(session)
 
 			fmt.Fprintf(w, "%#v\n", f)
 			filesChan <- &f
@@ -484,6 +463,56 @@ func ingressPotentialFile(c *cli.Context) {
 	log.Println("Done.")
 }
 
+// ingressExambotFiles imports the potential files printed by
+// `exambot exams -json`, one JSON file per line, keeping the page each was
+// linked from and the text around the link.
+func ingressExambotFiles(c *cli.Context) {
+	file := c.String("file")
+
+	if len(file) == 0 {
+		log.Fatal("need to provide file")
+	}
+
+	var reader io.ReadCloser
+	var err error
+	if file == "-" {
+		reader = os.Stdin
+	} else {
+		reader, err = os.Open(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	defer reader.Close()
+
+	var files []*examdb.File
+	dec := json.NewDecoder(reader)
+	for {
+		var f examdb.File
+		if err := dec.Decode(&f); err == io.EOF {
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		if len(f.Source) == 0 {
+			continue
+		}
+		files = append(files, &examdb.File{
+			Source:     f.Source,
+			Referrer:   f.Referrer,
+			AnchorText: f.AnchorText,
+			Heading:    f.Heading,
+			RowText:    f.RowText,
+		})
+	}
+
+	db.AddPotentialFiles(os.Stderr, files)
+	if err := saveAndGenerate(); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Done.")
+}
+
 func setupIngressCommands() cli.Command {
 	return cli.Command{
 		Name:    "ingress",
@@ -500,6 +529,17 @@ func setupIngressCommands() cli.Command {
 					},
 				},
 			},
+			{
+				Name:   "exambot",
+				Usage:  "import the exams found by exambot via `exambot exams -json`",
+				Action: ingressExambotFiles,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "file, f",
+						Usage: "Load exambot exams from `FILE`",
+					},
+				},
+			},
 		},
 	}
 }
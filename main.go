@@ -7,9 +7,7 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"regexp"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -24,8 +22,7 @@ import (
 )
 
 var (
-	templates = generators.Templates
-
+	cfg       *config.Config
 	db        examdb.Database
 	generator *generators.Generator
 )
@@ -49,7 +46,7 @@ func unprocessedSourceWorker() {
 			continue
 		}
 
-		if err := f.ComputeHash(); err != nil {
+		if err := f.ComputeHash(cfg); err != nil {
 			log.Printf("error processing source: %+v: %s", f, err)
 			continue
 		}
@@ -65,127 +62,98 @@ func unprocessedSourceWorker() {
 	}
 }
 
+// migrationBackup is the database on disk before it was migrated in memory.
+// It's backed up when the migrated database is first saved so commands that
+// don't save it have no side effects.
+var migrationBackup struct {
+	sync.Mutex
+	raw     []byte
+	version int
+}
+
 func loadDatabase() error {
-	raw, err := ioutil.ReadFile(config.DBFile)
+	raw, err := ioutil.ReadFile(cfg.DBFile)
 	if err != nil {
 		return err
 	}
 
 	db.Mu.Lock()
 	err = json.Unmarshal(raw, &db)
+	version := db.SchemaVersion
 	db.Mu.Unlock()
 
 	if err != nil {
 		return err
 	}
+	// Saving a newer database would drop the fields this version doesn't know
+	// about.
+	if version > examdb.SchemaVersion {
+		return errors.Errorf("database schema version %d is newer than supported version %d", version, examdb.SchemaVersion)
+	}
+
+	if db.NeedsMigration() {
+		migrationBackup.Lock()
+		migrationBackup.raw = raw
+		migrationBackup.version = db.SchemaVersion
+		migrationBackup.Unlock()
+
+		results, err := db.Migrate(ioutil.Discard)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			log.Printf("Applied migration %d (%s): %d changes.", r.Version, r.Desc, r.Changes)
+		}
+	}
+	return nil
+}
+
+// backupBeforeMigrating backs up the database from before it was migrated if
+// it hasn't been backed up yet.
+func backupBeforeMigrating() error {
+	migrationBackup.Lock()
+	defer migrationBackup.Unlock()
+
+	if migrationBackup.raw == nil {
+		return nil
+	}
+	fp, err := backupDatabaseFile(migrationBackup.raw, migrationBackup.version)
+	if err != nil {
+		return errors.Wrap(err, "backing up database before migrating")
+	}
+	log.Printf("Backed up database to %q before saving it migrated.", fp)
+	migrationBackup.raw = nil
 	return nil
 }
 
 func saveDatabase() error {
+	if err := backupBeforeMigrating(); err != nil {
+		return err
+	}
+
 	db.Mu.RLock()
 	defer db.Mu.RUnlock()
 
 	start := time.Now()
-	raw, err := json.MarshalIndent(db, "", "  ")
+	raw, err := json.MarshalIndent(&db, "", "  ")
 	if err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(config.DBFile, raw, 0755); err != nil {
+	// Write to a temporary file first so a crash can't leave a partially
+	// written database.
+	tmp := cfg.DBFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, cfg.DBFile); err != nil {
 		return err
 	}
 	log.Printf("Saved database in %s.", time.Since(start))
 	return nil
 }
 
-var whitespaceRegexp = regexp.MustCompile("  +")
-
-func removeDuplicateWhitespace(str string) string {
-	return whitespaceRegexp.ReplaceAllString(strings.TrimSpace(str), " ")
-}
-
-var nameToTermMapping = map[string]string{
-	"(Term 1)": "W1",
-	"(Term 2)": "W2",
-	"(Summer)": "S",
-}
-
-var nameReplacements = map[string]string{
-	"Practice Midterm":  "Sample Midterm",
-	"Practice Final":    "Sample Final",
-	"Final Sample":      "Sample Final",
-	"Midterm 1 Sample":  "Sample Midterm 1",
-	"Midterm Sample":    "Sample Midterm",
-	"Midterm 2 Sample":  "Sample Midterm 2",
-	"Midterm I Sample":  "Sample Midterm 1",
-	"Midterm II Sample": "Sample Midterm 2",
-	"Practice Quiz":     "Sample Quiz",
-}
-
 func verifyConsistency() error {
 	log.Println("Verifying consistency of data and doing house keeping...")
-	/*
-		for code, course := range db.Courses {
-			for yearnum, year := range course.Years {
-				for _, f := range year.Files {
-					f.Year = yearnum
-					f.Course = code
-					for pattern, term := range nameToTermMapping {
-						if !strings.Contains(f.Name, pattern) {
-							continue
-						}
-
-						log.Printf("Fixing %+v", f)
-						f.Name = removeDuplicateWhitespace(strings.Replace(f.Name, pattern, "", -1))
-						f.Term = term
-						log.Printf("Fixed %+v", f)
-					}
-					for pattern, term := range nameReplacements {
-						if !strings.Contains(f.Name, pattern) {
-							continue
-						}
-
-						log.Printf("Fixing %+v", f)
-						f.Name = removeDuplicateWhitespace(strings.Replace(f.Name, pattern, term, -1))
-						log.Printf("Fixed %+v", f)
-					}
-					f.Path = strings.TrimPrefix(f.Path, "static/exams/")
-					f.Path = strings.TrimPrefix(f.Path, "static/")
-					if len(f.Hash) == 0 {
-						if err := f.ComputeHash(); err != nil {
-							return err
-						}
-					}
-
-					f.HandClassified = true
-				}
-				db.Files = append(db.Files, year.Files...)
-				year.Files = nil
-			}
-			course.Years = nil
-		}
-
-		for _, f := range db.PotentialFiles {
-			f.ComputeScore(&db)
-			f.Path = strings.TrimSpace(f.Path)
-			f.Source = strings.TrimSpace(f.Source)
-
-			const prefix = "https://www.ugrad.cs.ubc.ca/~q7w9a/exams.cgi/exams.cgi"
-			if strings.HasPrefix(f.Source, prefix) {
-				stripped := strings.TrimPrefix(f.Source, prefix)
-				if url, ok := ugradPathToHTTP(stripped); ok {
-					f.Source = url
-				}
-			}
-		}
-
-		db.Files = append(db.Files, db.PotentialFiles...)
-		db.PotentialFiles = nil
-
-		db.Mu.Lock()
-		defer db.Mu.Unlock()
-		sort.Sort(examdb.FileSlice(db.PotentialFiles))
-	*/
-
 	fetched := struct {
 		sync.Mutex
 		count int
@@ -249,8 +217,38 @@ func saveAndGenerate() error {
 	return nil
 }
 
+// saveAndGenerateCourses saves the database and regenerates the index and the
+// specified courses. The database is saved even if generating fails.
+func saveAndGenerateCourses(codes ...string) error {
+	if err := saveDatabase(); err != nil {
+		return errors.Wrap(err, "err saving database")
+	}
+	if err := generator.Courses(codes...); err != nil {
+		return errors.Wrap(err, "error generating courses")
+	}
+	return nil
+}
+
+func generateSite(c *cli.Context) error {
+	if courses := c.StringSlice("course"); len(courses) > 0 {
+		return generator.Courses(courses...)
+	}
+	return generator.All()
+}
+
+func exportSite(c *cli.Context) error {
+	baseURL := cfg.SiteURL
+	if c.IsSet("base-url") {
+		baseURL = c.String("base-url")
+	}
+	return generator.Export(c.String("out"), generators.ExportOptions{
+		BaseURL:          baseURL,
+		ExcludePotential: c.Bool("exclude-potential"),
+	})
+}
+
 func serveSite(c *cli.Context) error {
-	if err := ml.LoadOrTrainClassifier(&db, config.ClassifierDir); err != nil {
+	if err := ml.LoadOrTrainClassifier(&db, cfg); err != nil {
 		log.Printf("Failed to load classifier. Classification tasks will not work.: %s", err)
 	}
 
@@ -264,7 +262,7 @@ func serveSite(c *cli.Context) error {
 	}
 
 	http.HandleFunc("/upload", handleFileUpload)
-	http.Handle("/", http.FileServer(http.Dir("static")))
+	http.Handle("/", http.FileServer(http.Dir(cfg.StaticDir)))
 
 	// Launch 4 source workers
 	for i := 0; i < workers.Count; i++ {
@@ -276,22 +274,41 @@ func serveSite(c *cli.Context) error {
 	return http.ListenAndServe(bindAddr, nil)
 }
 
-func main() {
-	log.SetFlags(log.Flags() | log.Lshortfile)
+// setupSite loads the configuration, the database and the generator before any
+// command is run.
+func setupSite(c *cli.Context) error {
+	var err error
+	cfg, err = loadConfig(c)
+	if err != nil {
+		return err
+	}
+	// The config commands only need the configuration.
+	if c.Args().First() == "config" {
+		return nil
+	}
 
-	if err := loadDatabase(); err != nil {
+	db.Config = cfg
+	if err := loadDatabase(); os.IsNotExist(err) {
 		log.Printf("tried to load database: %s", err)
+	} else if err != nil {
+		return errors.Wrap(err, "loading database")
 	}
 
-	var err error
-	generator, err = generators.MakeGenerator(&db, config.ExamsDir)
+	generator, err = generators.MakeGenerator(&db, cfg)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	if err := verifyConsistency(); err != nil {
-		log.Fatal(err)
+	// The db commands only save the database when asked to, e.g. not when
+	// migrating with --dry-run.
+	if c.Args().First() == "db" {
+		return nil
 	}
+	return verifyConsistency()
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
 
 	app := setupCommands()
 	if err := app.Run(os.Args); err != nil {
@@ -16,6 +16,7 @@ import (
 	"golang.org/x/time/rate"
 	prediction "google.golang.org/api/prediction/v1.6"
 
+	"github.com/ubccsss/exams/config"
 	"github.com/ubccsss/exams/examdb"
 	"github.com/ubccsss/exams/workers"
 
@@ -39,20 +40,20 @@ var fileClassifiers = map[string]fileClassLabeler{
 		if f.NotAnExam {
 			return "", false
 		}
-		class := typeClassFromLabel(f.Name)
+		class := typeClassFromFile(f)
 		return string(class), len(class) > 0
 	},
 	"solution": func(f *examdb.File) (string, bool) {
 		if f.NotAnExam {
 			return "", false
 		}
-		return string(solutionClassFromLabel(f.Name)), true
+		return string(solutionClassFromFile(f)), true
 	},
 	"sample": func(f *examdb.File) (string, bool) {
 		if f.NotAnExam {
 			return "", false
 		}
-		return string(sampleClassFromLabel(f.Name)), true
+		return string(sampleClassFromFile(f)), true
 	},
 	"term": func(f *examdb.File) (string, bool) {
 		if f.NotAnExam {
@@ -72,10 +73,12 @@ var fileClassifiers = map[string]fileClassLabeler{
 type GoogleClassifier struct {
 	Trainedmodels *prediction.TrainedmodelsService
 	Limiter       *rate.Limiter
+
+	cfg *config.Config
 }
 
 // MakeGoogleClassifier creates a new classifier.
-func MakeGoogleClassifier() (*GoogleClassifier, error) {
+func MakeGoogleClassifier(cfg *config.Config) (*GoogleClassifier, error) {
 	httpClient, err := google.DefaultClient(context.Background())
 	if err != nil {
 		return nil, err
@@ -87,16 +90,18 @@ func MakeGoogleClassifier() (*GoogleClassifier, error) {
 		// Google Prediction API allows for 100 requests every 100 seconds.
 		// *it's been increased to 1000/100s
 		Limiter: rate.NewLimiter(rate.Limit(1000/100), 1000),
+
+		cfg: cfg,
 	}, nil
 }
 
-func fileFeatures(f *examdb.File) ([]string, error) {
+func fileFeatures(cfg *config.Config, f *examdb.File) ([]string, error) {
 	source := f.Source
 	if len(source) == 0 {
 		source = f.Path
 	}
 	source = strings.Join(urlToWords(source), " ")
-	wordBag, meta, err := fileToWordBagMeta(f)
+	wordBag, meta, err := fileToWordBagMeta(cfg, f)
 	if err != nil {
 		return nil, err
 	}
@@ -190,7 +195,7 @@ func (c *GoogleClassifier) Train(db *examdb.Database) error {
 				if len(classes) == 0 {
 					continue
 				}
-				features, err := fileFeatures(f)
+				features, err := fileFeatures(c.cfg, f)
 				if err != nil {
 					log.Println(err)
 					continue
@@ -257,7 +262,7 @@ func (c *GoogleClassifier) Classify(f *examdb.File, lazy bool) (map[string]strin
 		return nil, errors.New("classifier has not been loaded")
 	}
 
-	features, err := fileFeatures(f)
+	features, err := fileFeatures(c.cfg, f)
 	if err != nil {
 		return nil, err
 	}
@@ -13,6 +13,8 @@ import (
 
 	"github.com/d4l3k/docconv"
 	"github.com/jbrukh/bayesian"
+	"github.com/pkg/errors"
+	"github.com/ubccsss/exams/config"
 	"github.com/ubccsss/exams/examdb"
 	"github.com/ubccsss/exams/util"
 )
@@ -85,6 +87,41 @@ func solutionClassFromLabel(label string) bayesian.Class {
 	return Blank
 }
 
+// typeClassFromFile returns the type class of the file from its label,
+// falling back to its name if it doesn't have one.
+func typeClassFromFile(f *examdb.File) bayesian.Class {
+	if len(f.Kind) == 0 {
+		return typeClassFromLabel(f.Name)
+	}
+	name := examdb.Label{Kind: f.Kind, Number: f.Number}.String()
+	for _, t := range TypeClasses {
+		if string(t) == name {
+			return t
+		}
+	}
+	return ""
+}
+
+func sampleClassFromFile(f *examdb.File) bayesian.Class {
+	if len(f.Kind) == 0 {
+		return sampleClassFromLabel(f.Name)
+	}
+	if f.IsSample {
+		return Sample
+	}
+	return Real
+}
+
+func solutionClassFromFile(f *examdb.File) bayesian.Class {
+	if len(f.Kind) == 0 {
+		return solutionClassFromLabel(f.Name)
+	}
+	if f.IsSolution {
+		return Solution
+	}
+	return Blank
+}
+
 func termClassFromTerm(term string) bayesian.Class {
 	term = strings.ToLower(term)
 	for _, t := range TermClasses {
@@ -103,13 +140,15 @@ type BayesianClassifier struct {
 	SolutionClassifier *bayesian.Classifier
 	TermClassifier     *bayesian.Classifier
 
+	cfg      *config.Config
 	dir      string
 	loadOnce sync.Once
 }
 
 // MakeDocumentClassifier trains a document classifier with all files in the DB.
-func MakeDocumentClassifier() *BayesianClassifier {
+func MakeDocumentClassifier(cfg *config.Config) *BayesianClassifier {
 	d := &BayesianClassifier{
+		cfg:                cfg,
 		TypeClassifier:     bayesian.NewClassifier(TypeClasses...),
 		SampleClassifier:   bayesian.NewClassifier(SampleClasses...),
 		SolutionClassifier: bayesian.NewClassifier(SolutionClasses...),
@@ -178,7 +217,7 @@ type fileWords struct {
 	words []string
 }
 
-func filesToWordBags(files []*examdb.File) (<-chan fileWords, <-chan error) {
+func filesToWordBags(cfg *config.Config, files []*examdb.File) (<-chan fileWords, <-chan error) {
 	const workers = 8
 	var wg sync.WaitGroup
 
@@ -197,7 +236,7 @@ func filesToWordBags(files []*examdb.File) (<-chan fileWords, <-chan error) {
 		wg.Add(1)
 		go func() {
 			for f := range fChan {
-				words, err := fileToWordBag(f)
+				words, err := fileToWordBag(cfg, f)
 				if err != nil {
 					errChan <- err
 					close(bagChan)
@@ -249,15 +288,15 @@ func (d BayesianClassifier) Train(db *examdb.Database) error {
 	trainFiles := files[:numTest]
 	testFiles := files[numTest:]
 
-	fileWordsChan, errChan := filesToWordBags(trainFiles)
+	fileWordsChan, errChan := filesToWordBags(d.cfg, trainFiles)
 	for f := range fileWordsChan {
 		words := f.words
-		if typeClass := typeClassFromLabel(f.Name); typeClass != "" {
+		if typeClass := typeClassFromFile(f.File); typeClass != "" {
 			d.TypeClassifier.Learn(words, typeClass)
 		}
-		sampleClass := sampleClassFromLabel(f.Name)
+		sampleClass := sampleClassFromFile(f.File)
 		d.SampleClassifier.Learn(words, sampleClass)
-		solutionClass := solutionClassFromLabel(f.Name)
+		solutionClass := solutionClassFromFile(f.File)
 		d.SolutionClassifier.Learn(words, solutionClass)
 		if termClass := termClassFromTerm(f.Term); termClass != "" {
 			d.TermClassifier.Learn(words, termClass)
@@ -294,7 +333,7 @@ func (d BayesianClassifier) Classify(f *examdb.File) (map[string]string, error)
 		return nil, err
 	}
 
-	words, err := fileToWordBag(f)
+	words, err := fileToWordBag(d.cfg, f)
 	if err != nil {
 		return nil, err
 	}
@@ -336,25 +375,25 @@ func (d BayesianClassifier) runTest(label string, files []*examdb.File, db *exam
 	documents := 0
 	var typeRight, typeTotal, sampleRight, sampleTotal, solutionRight, solutionTotal, termRight, termTotal int
 
-	fileWordsChan, errChan := filesToWordBags(files)
+	fileWordsChan, errChan := filesToWordBags(d.cfg, files)
 	for f := range fileWordsChan {
 		words := f.words
 		predType, predSample, predSolution, predTerm := d.classifyWords(words)
 
-		if typeClass := typeClassFromLabel(f.Name); typeClass != "" {
+		if typeClass := typeClassFromFile(f.File); typeClass != "" {
 			typeTotal++
 			if predType == typeClass {
 				typeRight++
 			}
 		}
 
-		sampleClass := sampleClassFromLabel(f.Name)
+		sampleClass := sampleClassFromFile(f.File)
 		sampleTotal++
 		if predSample == sampleClass {
 			sampleRight++
 		}
 
-		solutionClass := solutionClassFromLabel(f.Name)
+		solutionClass := solutionClassFromFile(f.File)
 		solutionTotal++
 		if predSolution == solutionClass {
 			solutionRight++
@@ -391,8 +430,8 @@ func (d BayesianClassifier) runTest(label string, files []*examdb.File, db *exam
 	return nil
 }
 
-func fileContentWords(f *examdb.File) ([]string, map[string]string, error) {
-	in, err := f.Reader()
+func fileContentWords(cfg *config.Config, f *examdb.File) ([]string, map[string]string, error) {
+	in, err := f.Reader(cfg)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -404,8 +443,8 @@ func fileContentWords(f *examdb.File) ([]string, map[string]string, error) {
 	return urlToWords(txt), meta, nil
 }
 
-func fileToWordBagMeta(f *examdb.File) ([]string, map[string]string, error) {
-	words, meta, err := fileContentWords(f)
+func fileToWordBagMeta(cfg *config.Config, f *examdb.File) ([]string, map[string]string, error) {
+	words, meta, err := fileContentWords(cfg, f)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -414,6 +453,9 @@ func fileToWordBagMeta(f *examdb.File) ([]string, map[string]string, error) {
 	} else if len(f.Path) > 0 {
 		words = append(words, urlToWords(strings.ToLower(path.Base(f.Path)))...)
 	}
+	for _, text := range []string{f.AnchorText, f.RowText, f.Heading} {
+		words = append(words, urlToWords(text)...)
+	}
 
 	var independentWords []string
 	// Add n-grams
@@ -428,8 +470,8 @@ func fileToWordBagMeta(f *examdb.File) ([]string, map[string]string, error) {
 	return words, meta, nil
 }
 
-func fileToWordBag(f *examdb.File) ([]string, error) {
-	words, _, err := fileToWordBagMeta(f)
+func fileToWordBag(cfg *config.Config, f *examdb.File) ([]string, error) {
+	words, _, err := fileToWordBagMeta(cfg, f)
 	return words, err
 }
 
@@ -484,10 +526,11 @@ var (
 	DefaultGoogleClassifier *GoogleClassifier
 )
 
-// LoadOrTrainClassifier loads or trains the classifier.
-func LoadOrTrainClassifier(db *examdb.Database, classifierDir string) error {
+// LoadOrTrainClassifier loads or trains the classifier from
+// cfg.ClassifierDir.
+func LoadOrTrainClassifier(db *examdb.Database, cfg *config.Config) error {
 	var err error
-	DefaultGoogleClassifier, err = MakeGoogleClassifier()
+	DefaultGoogleClassifier, err = MakeGoogleClassifier(cfg)
 	if err != nil {
 		return err
 	}
@@ -498,10 +541,10 @@ func LoadOrTrainClassifier(db *examdb.Database, classifierDir string) error {
 	go func() {
 		start := time.Now()
 		log.Println("Loading classifier...")
-		DefaultClassifier = MakeDocumentClassifier()
-		if err := DefaultClassifier.Load(classifierDir); err != nil {
+		DefaultClassifier = MakeDocumentClassifier(cfg)
+		if err := DefaultClassifier.Load(cfg.ClassifierDir); err != nil {
 			log.Printf("Failed to load classifier: %s", err)
-			if err := RetrainClassifier(db, classifierDir); err != nil {
+			if err := RetrainClassifier(db, cfg); err != nil {
 				log.Printf("Failed to retrain classifier: %s", err)
 			}
 		}
@@ -511,29 +554,47 @@ func LoadOrTrainClassifier(db *examdb.Database, classifierDir string) error {
 	return nil
 }
 
-// RetrainClassifier retrains classifier from db and saves it to disk.
-func RetrainClassifier(db *examdb.Database, classifierDir string) error {
-	c := MakeDocumentClassifier()
+// RetrainClassifier retrains classifier from db and saves it to
+// cfg.ClassifierDir.
+func RetrainClassifier(db *examdb.Database, cfg *config.Config) error {
+	c := MakeDocumentClassifier(cfg)
 	if err := c.Train(db); err != nil {
 		return err
 	}
-	if err := os.MkdirAll(classifierDir, 0755); err != nil {
+	if err := os.MkdirAll(cfg.ClassifierDir, 0755); err != nil {
 		return err
 	}
-	if err := c.Save(classifierDir); err != nil {
+	if err := c.Save(cfg.ClassifierDir); err != nil {
 		return err
 	}
 	DefaultClassifier = c
 	return nil
 }
 
-// ExtractCourse returns the predicted courseID from the file source.
+// ExtractCourse returns the predicted courseID from the file source. Matches of
+// a course's aliases resolve to the course. If the source doesn't mention a
+// course the text around the link to the file and then its referrer are used.
 func ExtractCourse(db *examdb.Database, f *examdb.File) string {
-	lowerPath := strings.ToLower(f.Source)
+	for _, text := range []string{f.Source, f.AnchorText, f.RowText, f.Heading, f.Referrer} {
+		if course := extractCourse(db, text); len(course) > 0 {
+			return course
+		}
+	}
+	return ""
+}
+
+func extractCourse(db *examdb.Database, text string) string {
+	lowerPath := strings.ToLower(text)
 	var bestMatch string
 	var bestMatchScore int
 	for _, c := range db.Courses {
-		for _, id := range c.AlternateIDs() {
+		ids := c.AlternateIDs(db.Config.Department(c.Department()))
+		for _, code := range c.Aliases {
+			alias := examdb.Course{Code: code}
+			ids = append(ids, code, strings.Replace(code, " ", "", -1))
+			ids = append(ids, alias.AlternateIDs(db.Config.Department(alias.Department()))...)
+		}
+		for _, id := range ids {
 			if !strings.Contains(lowerPath, id) {
 				continue
 			}
@@ -547,8 +608,23 @@ func ExtractCourse(db *examdb.Database, f *examdb.File) string {
 	return bestMatch
 }
 
+// CheckCourseYear returns an error if the course didn't exist yet in the year
+// according to its catalog history.
+func CheckCourseYear(db *examdb.Database, course string, year int) error {
+	since := db.OldestCatalogYear()
+
+	db.Mu.RLock()
+	defer db.Mu.RUnlock()
+
+	c, ok := db.Courses[course]
+	if !ok || !c.Predates(year, since) {
+		return nil
+	}
+	return errors.Errorf("%s was first listed in the calendar in %d, not by %d", strings.ToUpper(course), c.Catalog[0].Year, year)
+}
+
 // ExtractYear uses the text content of a file to infer the year it was from.
-func ExtractYear(f *examdb.File) (int, string) {
+func ExtractYear(cfg *config.Config, f *examdb.File) (int, string) {
 	if f.Year > 0 {
 		return f.Year, f.Term
 	}
@@ -561,7 +637,7 @@ func ExtractYear(f *examdb.File) (int, string) {
 			return n
 		}
 	*/
-	words, err := fileToWordBag(f)
+	words, err := fileToWordBag(cfg, f)
 	if err != nil {
 		log.Println(err)
 		return 0, examdb.TermUnknown
@@ -686,24 +762,11 @@ func mostFrequentString(arr []string, fallback string) string {
 }
 
 // convertDateToYear returns the year from t, unless the template hasMonth, in
-// which it checks if it's during January to April and subtracts 1 from the year
-// if that's the case.
+// which case it returns the session t is during. See examdb.SessionFromDate.
 func convertDateToYear(tmpl dateTemplate, t time.Time) (int, string) {
 	if tmpl.hasMonth {
-		var term string
-		m := t.Month()
-		if m >= time.January && m <= time.April {
-			term = examdb.TermW2
-		} else if m >= time.May && m <= time.August {
-			term = examdb.TermS
-		} else if m >= time.September && m <= time.December {
-			term = examdb.TermW1
-		}
-
-		if term == examdb.TermW2 {
-			return t.Year() - 1, term
-		}
-		return t.Year(), term
+		s := examdb.SessionFromDate(t)
+		return s.Year, s.Term
 	}
 	return t.Year(), examdb.TermUnknown
 }
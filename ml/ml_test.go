@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/jbrukh/bayesian"
+	"github.com/ubccsss/exams/config"
 	"github.com/ubccsss/exams/examdb"
 )
 
@@ -74,6 +75,28 @@ func TestClassLabelExtractor(t *testing.T) {
 	}
 }
 
+func TestClassFileExtractor(t *testing.T) {
+	cases := []struct {
+		file      *examdb.File
+		want      bayesian.Class
+		extractor func(*examdb.File) bayesian.Class
+	}{
+		{&examdb.File{Label: examdb.Label{Kind: examdb.KindQuiz, Number: 3}}, Quiz3, typeClassFromFile},
+		{&examdb.File{Label: examdb.Label{Kind: examdb.KindMidterm, IsSample: true}}, Midterm, typeClassFromFile},
+		{&examdb.File{Label: examdb.Label{Kind: examdb.KindAssignment, Number: 1}}, "", typeClassFromFile},
+		{&examdb.File{Label: examdb.Label{Kind: examdb.KindFinal, IsSample: true}}, Sample, sampleClassFromFile},
+		{&examdb.File{Label: examdb.Label{Kind: examdb.KindFinal, IsSolution: true}}, Solution, solutionClassFromFile},
+		{&examdb.File{Name: "Sample Midterm 2"}, Midterm2, typeClassFromFile},
+		{&examdb.File{Name: "Sample Midterm 2"}, Sample, sampleClassFromFile},
+	}
+	for i, c := range cases {
+		out := c.extractor(c.file)
+		if out != c.want {
+			t.Errorf("%d. extractor(%+v) = %+v; not %+v", i, c.file, out, c.want)
+		}
+	}
+}
+
 func TestGenerateNGrams(t *testing.T) {
 	cases := []struct {
 		words []string
@@ -168,3 +191,78 @@ func TestConvertDateToYearTerm(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractCourse(t *testing.T) {
+	db := &examdb.Database{
+		Config: config.Default(),
+		Courses: map[string]*examdb.Course{
+			"cpsc 259": {Code: "cpsc 259", Aliases: []string{"eece 259"}},
+			"cpsc 110": {Code: "cpsc 110"},
+			"math 307": {Code: "math 307", Aliases: []string{"eece 307"}},
+		},
+	}
+	cases := []struct {
+		source, anchorText, referrer, want string
+	}{
+		{"http://www.ugrad.cs.ubc.ca/~cs110/exams/final.pdf", "", "", "cpsc 110"},
+		{"http://example.com/eece259/midterm.pdf", "", "", "cpsc 259"},
+		{"http://example.com/cpsc-259/midterm.pdf", "", "", "cpsc 259"},
+		{"http://example.com/eece307/midterm.pdf", "", "", "math 307"},
+		{"http://example.com/phys101/midterm.pdf", "", "", ""},
+		{"http://example.com/files/mt.pdf", "CPSC 110 Midterm", "http://example.com/cpsc259/", "cpsc 110"},
+		{"http://example.com/files/mt.pdf", "Midterm", "http://example.com/cpsc259/", "cpsc 259"},
+		{"http://example.com/cs110/mt.pdf", "CPSC 259 Midterm", "", "cpsc 110"},
+	}
+	for i, c := range cases {
+		f := &examdb.File{Source: c.source, AnchorText: c.anchorText, Referrer: c.referrer}
+		out := ExtractCourse(db, f)
+		if out != c.want {
+			t.Errorf("%d. ExtractCourse(%+v) = %q; not %q", i, f, out, c.want)
+		}
+	}
+
+	contextCases := []struct {
+		f    examdb.File
+		want string
+	}{
+		{examdb.File{Source: "http://example.com/mt.pdf", AnchorText: "Solutions", RowText: "2015W1 CPSC 110 Solutions"}, "cpsc 110"},
+		{examdb.File{Source: "http://example.com/mt.pdf", AnchorText: "Solutions", Heading: "CPSC 259 Midterms", Referrer: "http://example.com/cs110/"}, "cpsc 259"},
+	}
+	for i, c := range contextCases {
+		f := &c.f
+		out := ExtractCourse(db, f)
+		if out != c.want {
+			t.Errorf("%d. ExtractCourse(%+v) = %q; not %q", i, f, out, c.want)
+		}
+	}
+}
+
+func TestCheckCourseYear(t *testing.T) {
+	db := &examdb.Database{
+		Courses: map[string]*examdb.Course{
+			"cpsc 110": {Code: "cpsc 110", Catalog: []examdb.CatalogEntry{{Year: 2015}}},
+			"cpsc 121": {Code: "cpsc 121"},
+			"cpsc 210": {Code: "cpsc 210", Catalog: []examdb.CatalogEntry{{Year: 2002}}},
+		},
+	}
+	cases := []struct {
+		course  string
+		year    int
+		wantErr bool
+	}{
+		{"cpsc 110", 2014, true},
+		{"cpsc 110", 2015, false},
+		{"cpsc 110", 0, false},
+		{"cpsc 110", 1998, false},
+		{"cpsc 210", 1998, false},
+		{"cpsc 210", 2002, false},
+		{"cpsc 121", 1990, false},
+		{"cpsc 999", 1990, false},
+	}
+	for i, c := range cases {
+		err := CheckCourseYear(db, c.course, c.year)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%d. CheckCourseYear(%q, %d) = %v", i, c.course, c.year, err)
+		}
+	}
+}
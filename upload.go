@@ -9,7 +9,6 @@ import (
 	"path"
 	"strconv"
 
-	"github.com/ubccsss/exams/config"
 	"github.com/ubccsss/exams/examdb"
 )
 
@@ -18,11 +17,11 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		handleErr(w, errors.New("POST required"))
 		return
 	}
-	if r.ContentLength > config.MaxFileSize {
+	if r.ContentLength > cfg.MaxFileSize {
 		http.Error(w, "request too large", http.StatusExpectationFailed)
 		return
 	}
-	r.Body = http.MaxBytesReader(w, r.Body, config.MaxFileSize)
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxFileSize)
 	if err := r.ParseMultipartForm(1024); err != nil {
 		handleErr(w, err)
 		return
@@ -33,8 +32,8 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	course := r.URL.Query().Get("course")
-	if _, ok := db.Courses[course]; !ok {
+	course, ok := db.ResolveCourse(r.URL.Query().Get("course"))
+	if !ok {
 		http.Error(w, "invalid course ID", http.StatusBadRequest)
 		return
 	}
@@ -44,6 +43,14 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "name required", http.StatusBadRequest)
 		return
 	}
+	label, err := examdb.ParseLabel(name)
+	if err == nil {
+		err = label.Validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	year := r.FormValue("year")
 	if year == "" {
 		http.Error(w, "year required", http.StatusBadRequest)
@@ -60,6 +67,11 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "term required", http.StatusBadRequest)
 		return
 	}
+	term, ok = examdb.NormalizeTerm(term)
+	if !ok {
+		http.Error(w, "invalid term", http.StatusBadRequest)
+		return
+	}
 
 	file, handler, err := r.FormFile("exam")
 	if err != nil {
@@ -67,12 +79,12 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer file.Close()
-	fpath := path.Join(config.UploadedExamsDir, handler.Filename)
-	if err := os.MkdirAll(path.Join(config.ExamsDir, config.UploadedExamsDir), 0755); err != nil {
+	fpath := path.Join(cfg.UploadedExamsDir, handler.Filename)
+	if err := os.MkdirAll(path.Join(cfg.ExamsDir, cfg.UploadedExamsDir), 0755); err != nil {
 		handleErr(w, err)
 		return
 	}
-	f, err := os.OpenFile(path.Join(config.ExamsDir, fpath), os.O_WRONLY|os.O_CREATE, 0755)
+	f, err := os.OpenFile(path.Join(cfg.ExamsDir, fpath), os.O_WRONLY|os.O_CREATE, 0755)
 	if err != nil {
 		handleErr(w, err)
 		return
@@ -83,13 +95,14 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db.AddPotentialFiles(os.Stderr, []*examdb.File{{
-		Name:   name,
+	uploaded := &examdb.File{
 		Course: course,
 		Year:   yeari,
 		Term:   term,
 		Path:   fpath,
-	}})
+	}
+	uploaded.SetLabel(label)
+	db.AddPotentialFiles(os.Stderr, []*examdb.File{uploaded})
 
 	fmt.Fprintf(w, `<h1>Uploaded Successful</h1>
 	<p>Thank you for your contribution!</p>